@@ -0,0 +1,152 @@
+package postgrid
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// AdaptiveDecrease is the factor the rate limit of an adaptive client is multiplied by when postgrid
+// throttles it.
+const AdaptiveDecrease = 0.5
+
+// Rate limit headers read by an adaptive client. The RateLimit-* headers are read when the X-RateLimit-*
+// headers are missing. Reset headers hold the number of seconds until the limit resets, or a unix time.
+const (
+	HeaderRateLimitRemaining = "X-RateLimit-Remaining"
+	HeaderRateLimitReset     = "X-RateLimit-Reset"
+	HeaderRetryAfter         = "Retry-After"
+)
+
+// adaptiveLimiter adjusts the rate of a rate.Limiter from the responses of postgrid: it is multiplied by
+// AdaptiveDecrease on a 429 or when no requests remain, and increased by rampUp per second otherwise, up to
+// the initial rate of the limiter.
+type adaptiveLimiter struct {
+	limiter *rate.Limiter
+	min     rate.Limit
+	max     rate.Limit
+	rampUp  rate.Limit
+	now     func() time.Time
+
+	mu          sync.Mutex
+	updated     time.Time
+	pausedUntil time.Time
+}
+
+func newAdaptiveLimiter(limiter *rate.Limiter, min, rampUp rate.Limit) *adaptiveLimiter {
+	return &adaptiveLimiter{
+		limiter: limiter,
+		min:     min,
+		max:     limiter.Limit(),
+		rampUp:  rampUp,
+		now:     time.Now,
+	}
+}
+
+// observe adjusts the rate from the response. It reports whether the rate was reduced and how long requests
+// are paused for.
+func (a *adaptiveLimiter) observe(resp *http.Response) (bool, time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	pause, throttled := throttle(resp, now)
+	if !throttled {
+		if a.max != rate.Inf && !a.updated.IsZero() {
+			limit := a.limiter.Limit() + a.rampUp*rate.Limit(now.Sub(a.updated).Seconds())
+			a.limiter.SetLimitAt(now, min(limit, a.max))
+		}
+		a.updated = now
+		return false, 0
+	}
+
+	if a.max != rate.Inf {
+		a.limiter.SetLimitAt(now, max(a.limiter.Limit()*AdaptiveDecrease, a.min))
+	}
+	a.updated = now
+	if until := now.Add(pause); until.After(a.pausedUntil) {
+		a.pausedUntil = until
+	}
+
+	return true, pause
+}
+
+// pause returns how long requests must wait before being sent, following a Retry-After or rate limit
+// reset.
+func (a *adaptiveLimiter) pause() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return max(a.pausedUntil.Sub(a.now()), 0)
+}
+
+// throttle reports whether the response throttles the client, from its status code or rate limit headers,
+// and how long postgrid asked to wait.
+func throttle(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if d, ok := parseRetryAfter(resp.Header.Get(HeaderRetryAfter), now); ok {
+			return d, true
+		}
+		d, _ := parseReset(rateLimitHeader(resp.Header, HeaderRateLimitReset), now)
+		return d, true
+	}
+
+	remaining, err := strconv.Atoi(rateLimitHeader(resp.Header, HeaderRateLimitRemaining))
+	if err != nil || remaining > 0 {
+		return 0, false
+	}
+	d, _ := parseReset(rateLimitHeader(resp.Header, HeaderRateLimitReset), now)
+
+	return d, true
+}
+
+// rateLimitHeader returns the X-RateLimit-* header, or the corresponding RateLimit-* header.
+func rateLimitHeader(h http.Header, name string) string {
+	if v := h.Get(name); v != "" {
+		return v
+	}
+
+	return h.Get(strings.TrimPrefix(name, "X-"))
+}
+
+// parseRetryAfter parses a Retry-After header holding either seconds or an http date.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0), true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+
+	return max(t.Sub(now), 0), true
+}
+
+// resetUnixThreshold tells apart reset headers holding a unix time from those holding seconds.
+const resetUnixThreshold = 1_000_000_000
+
+// parseReset parses a rate limit reset header holding either seconds or a unix time.
+func parseReset(v string, now time.Time) (time.Duration, bool) {
+	seconds, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	if seconds >= resetUnixThreshold {
+		return max(time.Unix(seconds, 0).Sub(now), 0), true
+	}
+
+	return max(time.Duration(seconds)*time.Second, 0), true
+}
+
+// RateLimit returns the current rate of the client's rate limiter in requests per second. It changes over
+// time when the client is configured WithAdaptiveRateLimit.
+func (c *Client) RateLimit() rate.Limit {
+	return c.rateLimiter.Limit()
+}
@@ -0,0 +1,182 @@
+package postgrid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func testResponse(status int, header map[string]string) *http.Response {
+	resp := &http.Response{StatusCode: status, Header: http.Header{}}
+	for k, v := range header {
+		resp.Header.Set(k, v)
+	}
+
+	return resp
+}
+
+func TestAdaptiveLimiter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		responses     []*http.Response
+		step          time.Duration
+		wantLimit     rate.Limit
+		wantPause     time.Duration
+		wantThrottled bool
+	}{
+		{
+			name:          "429 with retry after seconds",
+			responses:     []*http.Response{testResponse(http.StatusTooManyRequests, map[string]string{"Retry-After": "3"})},
+			wantLimit:     4,
+			wantPause:     3 * time.Second,
+			wantThrottled: true,
+		},
+		{
+			name: "429 with retry after date",
+			responses: []*http.Response{testResponse(http.StatusTooManyRequests, map[string]string{
+				"Retry-After": now.Add(2 * time.Second).Format(http.TimeFormat),
+			})},
+			wantLimit:     4,
+			wantPause:     2 * time.Second,
+			wantThrottled: true,
+		},
+		{
+			name:          "no remaining requests until reset",
+			responses:     []*http.Response{testResponse(http.StatusOK, map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "5"})},
+			wantLimit:     4,
+			wantPause:     5 * time.Second,
+			wantThrottled: true,
+		},
+		{
+			name: "ietf headers with unix reset",
+			responses: []*http.Response{testResponse(http.StatusOK, map[string]string{
+				"RateLimit-Remaining": "0", "RateLimit-Reset": strconv.FormatInt(now.Add(time.Second).Unix(), 10),
+			})},
+			wantLimit:     4,
+			wantPause:     time.Second,
+			wantThrottled: true,
+		},
+		{
+			name:      "remaining requests",
+			responses: []*http.Response{testResponse(http.StatusOK, map[string]string{"X-RateLimit-Remaining": "3"})},
+			wantLimit: 8,
+		},
+		{
+			name: "decrease stops at min",
+			responses: []*http.Response{
+				testResponse(http.StatusTooManyRequests, nil),
+				testResponse(http.StatusTooManyRequests, nil),
+				testResponse(http.StatusTooManyRequests, nil),
+			},
+			wantLimit:     1.5,
+			wantThrottled: true,
+		},
+		{
+			name: "ramps up after throttling",
+			responses: []*http.Response{
+				testResponse(http.StatusTooManyRequests, nil),
+				testResponse(http.StatusOK, nil),
+				testResponse(http.StatusOK, nil),
+			},
+			step:      time.Second,
+			wantLimit: 6,
+		},
+		{
+			name: "ramp up stops at initial rate",
+			responses: []*http.Response{
+				testResponse(http.StatusTooManyRequests, nil),
+				testResponse(http.StatusOK, nil),
+				testResponse(http.StatusOK, nil),
+			},
+			step:      10 * time.Second,
+			wantLimit: 8,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := now
+			a := newAdaptiveLimiter(rate.NewLimiter(8, 1), 1.5, 1)
+			a.now = func() time.Time { return clock }
+
+			var throttled bool
+			for _, resp := range tt.responses {
+				throttled, _ = a.observe(resp)
+				clock = clock.Add(tt.step)
+			}
+
+			assert.Equal(t, tt.wantThrottled, throttled)
+			assert.InDelta(t, float64(tt.wantLimit), float64(a.limiter.Limit()), 1e-9)
+			assert.Equal(t, tt.wantPause, a.pause())
+		})
+	}
+}
+
+func TestClient_WithAdaptiveRateLimit(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"status":"error","message":"too many requests"}`))
+			return
+		}
+		writeTestResponse(t, w, VerifiedAddress{})
+	}))
+	t.Cleanup(srv.Close)
+
+	hook, events := recordEvents()
+	limiter := rate.NewLimiter(1000, 10)
+	client := NewClient("", srv.URL, WithHTTPClient(srv.Client()), WithRateLimiter(limiter),
+		WithRetry(2, time.Millisecond), WithAdaptiveRateLimit(1, 1), WithProgressHook(hook))
+	assert.Equal(t, rate.Limit(1000), client.RateLimit())
+
+	_, err := client.VerifyAddress(context.Background(), VerifyAddressRequest{Address: Address{Line1: "A"}})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), calls.Load())
+	assert.Less(t, float64(client.RateLimit()), 1000.0)
+	assert.Equal(t, rate.Limit(1000), limiter.Limit(), "the configured limiter is not adjusted")
+
+	var reduced []ProgressEvent
+	for _, ev := range events() {
+		if ev.Type == ProgressRateLimitReduced {
+			reduced = append(reduced, ev)
+		}
+	}
+	require.Len(t, reduced, 1)
+	assert.Equal(t, rate.Limit(500), reduced[0].Limit)
+	assert.Equal(t, EndpointVerify, reduced[0].Endpoint)
+}
+
+func TestClient_WithAdaptiveRateLimit_Deadline(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"status":"error","message":"too many requests"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	client := NewClient("", srv.URL, WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(1000, 10)),
+		WithAdaptiveRateLimit(1, 1))
+	_, err := client.VerifyAddress(context.Background(), VerifyAddressRequest{Address: Address{Line1: "A"}})
+	require.Error(t, err)
+
+	// The pause requested by postgrid outlasts the deadline, so the request is not sent.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err = client.VerifyAddress(ctx, VerifyAddressRequest{Address: Address{Line1: "A"}})
+	assert.ErrorIs(t, err, ErrRateLimitDeadline)
+	assert.Less(t, time.Since(start), 500*time.Millisecond, "the request fails without waiting for the deadline")
+	assert.Equal(t, int32(1), calls.Load())
+}
@@ -0,0 +1,126 @@
+package addressparser
+
+// directionals maps every accepted spelling of a directional to its USPS abbreviation.
+var directionals = map[string]string{
+	"N": "N", "NORTH": "N",
+	"S": "S", "SOUTH": "S",
+	"E": "E", "EAST": "E",
+	"W": "W", "WEST": "W",
+	"NE": "NE", "NORTHEAST": "NE",
+	"NW": "NW", "NORTHWEST": "NW",
+	"SE": "SE", "SOUTHEAST": "SE",
+	"SW": "SW", "SOUTHWEST": "SW",
+}
+
+// streetTypes maps common street suffixes (USPS Publication 28 and Canada Post) to their abbreviation.
+var streetTypes = map[string]string{
+	"ALLEY": "ALY", "ALY": "ALY",
+	"AVENUE": "AVE", "AVE": "AVE", "AV": "AVE",
+	"BEND": "BND", "BND": "BND",
+	"BOULEVARD": "BLVD", "BLVD": "BLVD",
+	"CIRCLE": "CIR", "CIR": "CIR",
+	"COURT": "CT", "CT": "CT",
+	"COVE": "CV", "CV": "CV",
+	"CRESCENT": "CRES", "CRES": "CRES",
+	"DRIVE": "DR", "DR": "DR",
+	"EXPRESSWAY": "EXPY", "EXPY": "EXPY",
+	"FREEWAY": "FWY", "FWY": "FWY",
+	"GROVE": "GRV", "GRV": "GRV",
+	"HEIGHTS": "HTS", "HTS": "HTS",
+	"HIGHWAY": "HWY", "HWY": "HWY",
+	"LANE": "LN", "LN": "LN",
+	"LOOP":    "LOOP",
+	"PARKWAY": "PKWY", "PKWY": "PKWY",
+	"PATH":  "PATH",
+	"PIKE":  "PIKE",
+	"PLACE": "PL", "PL": "PL",
+	"PLAZA": "PLZ", "PLZ": "PLZ",
+	"POINT": "PT", "PT": "PT",
+	"ROAD": "RD", "RD": "RD",
+	"ROW":    "ROW",
+	"RUN":    "RUN",
+	"SQUARE": "SQ", "SQ": "SQ",
+	"STREET": "ST", "ST": "ST",
+	"TERRACE": "TER", "TER": "TER",
+	"TRAIL": "TRL", "TRL": "TRL",
+	"TURNPIKE": "TPKE", "TPKE": "TPKE",
+	"WALK":     "WALK",
+	"WAY":      "WAY",
+	"CROSSING": "XING", "XING": "XING",
+}
+
+// numberedRoutes are street name prefixes that are followed by a route number instead of a suffix,
+// e.g. "HIGHWAY 101" or "COUNTY ROAD 5".
+var numberedRoutes = map[string]bool{
+	"HIGHWAY": true, "HWY": true,
+	"ROUTE": true, "RTE": true,
+	"SR": true, "CR": true,
+	"FM": true, "RANCH ROAD": true,
+	"COUNTY ROAD": true, "STATE ROAD": true, "STATE ROUTE": true,
+	"STATE HIGHWAY": true, "US HIGHWAY": true, "US HWY": true,
+	"INTERSTATE": true,
+}
+
+// unitDesignators maps secondary unit designators to their abbreviation. The value of requiresID reports
+// whether the designator must be followed by an identifier.
+var unitDesignators = map[string]struct {
+	abbr       string
+	requiresID bool
+}{
+	"APARTMENT": {"APT", true}, "APT": {"APT", true},
+	"BASEMENT": {"BSMT", false}, "BSMT": {"BSMT", false},
+	"BUILDING": {"BLDG", true}, "BLDG": {"BLDG", true},
+	"DEPARTMENT": {"DEPT", true}, "DEPT": {"DEPT", true},
+	"FLOOR": {"FL", true}, "FL": {"FL", true},
+	"FRONT": {"FRNT", false}, "FRNT": {"FRNT", false},
+	"HANGAR": {"HNGR", true}, "HNGR": {"HNGR", true},
+	"LOBBY": {"LBBY", false}, "LBBY": {"LBBY", false},
+	"LOT":   {"LOT", true},
+	"LOWER": {"LOWR", false}, "LOWR": {"LOWR", false},
+	"OFFICE": {"OFC", false}, "OFC": {"OFC", false},
+	"PENTHOUSE": {"PH", false}, "PH": {"PH", false},
+	"PIER": {"PIER", true},
+	"REAR": {"REAR", false},
+	"ROOM": {"RM", true}, "RM": {"RM", true},
+	"SIDE":  {"SIDE", false},
+	"SLIP":  {"SLIP", true},
+	"SPACE": {"SPC", true}, "SPC": {"SPC", true},
+	"STOP":  {"STOP", true},
+	"SUITE": {"STE", true}, "STE": {"STE", true},
+	"TRAILER": {"TRLR", true}, "TRLR": {"TRLR", true},
+	"UNIT":  {"UNIT", true},
+	"UPPER": {"UPPR", false}, "UPPR": {"UPPR", false},
+	"#": {"#", true},
+}
+
+// usStates maps US state, territory and military state codes to their full names.
+var usStates = map[string]string{
+	"AL": "ALABAMA", "AK": "ALASKA", "AZ": "ARIZONA", "AR": "ARKANSAS", "CA": "CALIFORNIA",
+	"CO": "COLORADO", "CT": "CONNECTICUT", "DE": "DELAWARE", "DC": "DISTRICT OF COLUMBIA",
+	"FL": "FLORIDA", "GA": "GEORGIA", "HI": "HAWAII", "ID": "IDAHO", "IL": "ILLINOIS",
+	"IN": "INDIANA", "IA": "IOWA", "KS": "KANSAS", "KY": "KENTUCKY", "LA": "LOUISIANA",
+	"ME": "MAINE", "MD": "MARYLAND", "MA": "MASSACHUSETTS", "MI": "MICHIGAN", "MN": "MINNESOTA",
+	"MS": "MISSISSIPPI", "MO": "MISSOURI", "MT": "MONTANA", "NE": "NEBRASKA", "NV": "NEVADA",
+	"NH": "NEW HAMPSHIRE", "NJ": "NEW JERSEY", "NM": "NEW MEXICO", "NY": "NEW YORK",
+	"NC": "NORTH CAROLINA", "ND": "NORTH DAKOTA", "OH": "OHIO", "OK": "OKLAHOMA", "OR": "OREGON",
+	"PA": "PENNSYLVANIA", "RI": "RHODE ISLAND", "SC": "SOUTH CAROLINA", "SD": "SOUTH DAKOTA",
+	"TN": "TENNESSEE", "TX": "TEXAS", "UT": "UTAH", "VT": "VERMONT", "VA": "VIRGINIA",
+	"WA": "WASHINGTON", "WV": "WEST VIRGINIA", "WI": "WISCONSIN", "WY": "WYOMING",
+	"AS": "AMERICAN SAMOA", "GU": "GUAM", "MP": "NORTHERN MARIANA ISLANDS", "PR": "PUERTO RICO",
+	"VI": "VIRGIN ISLANDS",
+	"AA": "ARMED FORCES AMERICAS", "AE": "ARMED FORCES EUROPE", "AP": "ARMED FORCES PACIFIC",
+}
+
+// caProvinces maps Canadian province and territory codes to their full names.
+var caProvinces = map[string]string{
+	"AB": "ALBERTA", "BC": "BRITISH COLUMBIA", "MB": "MANITOBA", "NB": "NEW BRUNSWICK",
+	"NL": "NEWFOUNDLAND AND LABRADOR", "NS": "NOVA SCOTIA", "NT": "NORTHWEST TERRITORIES",
+	"NU": "NUNAVUT", "ON": "ONTARIO", "PE": "PRINCE EDWARD ISLAND", "QC": "QUEBEC",
+	"SK": "SASKATCHEWAN", "YT": "YUKON",
+}
+
+// countries maps accepted country spellings to ISO 3166-1 alpha-2 codes.
+var countries = map[string]string{
+	"US": "US", "USA": "US", "U S A": "US", "UNITED STATES": "US", "UNITED STATES OF AMERICA": "US",
+	"CANADA": "CA", "CAN": "CA",
+}
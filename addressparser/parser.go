@@ -0,0 +1,711 @@
+// Package addressparser splits single-line US and Canadian addresses into postgrid.Address components
+// without calling the postgrid api.
+//
+// The parser is heuristic. Every parsed field carries a confidence score between 0 and 1 so callers can
+// decide whether to send the structured components or fall back to the freeform Address.String form.
+package addressparser
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	postgrid "github.com/bloomcredit/bloomcredit-postgrid-sdk"
+)
+
+// Field identifies a postgrid.Address field that the parser scored.
+type Field string
+
+// All possible values for Field.
+const (
+	FieldLine1           Field = "line1"
+	FieldLine2           Field = "line2"
+	FieldCity            Field = "city"
+	FieldProvinceOrState Field = "provinceOrState"
+	FieldPostalOrZip     Field = "postalOrZip"
+	FieldCountry         Field = "country"
+)
+
+// ErrEmptyAddress is returned when the input contains no address tokens.
+var ErrEmptyAddress = errors.New("addressparser: empty address")
+
+// Components holds the individual parts recognised in an address. Values are upper cased and use
+// USPS / Canada Post abbreviations.
+type Components struct {
+	// Recipient holds leading comma separated segments that precede the delivery line, e.g. a firm name.
+	Recipient     string
+	StreetNumber  string
+	PreDirection  string
+	StreetName    string
+	StreetType    string
+	PostDirection string
+	UnitType      string
+	UnitID        string
+	POBox         string
+	Station       string
+	// MilitaryUnit holds the PSC, CMR or UNIT number of an APO/FPO/DPO address, e.g. "PSC 1234".
+	MilitaryUnit     string
+	RuralRouteType   string
+	RuralRouteNumber string
+	GeneralDelivery  bool
+	City             string
+	ProvinceOrState  string
+	PostalOrZip      string
+	ZipPlus4         string
+	Country          string
+}
+
+// Result is the outcome of parsing a single-line address.
+type Result struct {
+	// Address is ready to be sent to the postgrid api in structured mode.
+	Address    postgrid.Address
+	Components Components
+	// Confidence is the mean confidence of Line1, City, ProvinceOrState and PostalOrZip.
+	Confidence      float64
+	FieldConfidence map[Field]float64
+}
+
+var (
+	zipRegex          = regexp.MustCompile(`^(\d{5})(?:-?(\d{4}))?$`)
+	postalCodeRegex   = regexp.MustCompile(`^([A-Z]\d[A-Z])-?(\d[A-Z]\d)$`)
+	postalFSARegex    = regexp.MustCompile(`^[A-Z]\d[A-Z]$`)
+	postalLDURegex    = regexp.MustCompile(`^\d[A-Z]\d$`)
+	streetNumberRegex = regexp.MustCompile(`^(\d+[A-Z]?|\d+-\d+[A-Z]?|[NSEW]\d+[NSEW]\d+)$`)
+	fractionRegex     = regexp.MustCompile(`^\d/\d$`)
+	hasDigitRegex     = regexp.MustCompile(`\d`)
+)
+
+type token struct {
+	raw     string
+	up      string
+	segment int
+}
+
+// Parse splits a single-line address into its components.
+func Parse(s string) (Result, error) {
+	toks := tokenize(s)
+	if len(toks) == 0 {
+		return Result{}, ErrEmptyAddress
+	}
+
+	p := parser{
+		conf: map[Field]float64{},
+	}
+	rest := p.parseTail(toks)
+	p.parseHead(rest)
+
+	return p.result(), nil
+}
+
+// ToAddress parses s and returns the structured address when the overall confidence is at least
+// minConfidence. Otherwise the freeform form of s is returned so postgrid can parse it instead.
+func ToAddress(s string, minConfidence float64) postgrid.Address {
+	res, err := Parse(s)
+	if err != nil || res.Confidence < minConfidence {
+		return postgrid.Address{String: strings.TrimSpace(s)}
+	}
+
+	return res.Address
+}
+
+func tokenize(s string) []token {
+	s = strings.ReplaceAll(s, "#", " # ")
+	s = strings.ReplaceAll(s, ";", ",")
+
+	var toks []token
+	for i, segment := range strings.Split(s, ",") {
+		for _, raw := range strings.Fields(segment) {
+			up := strings.ToUpper(strings.ReplaceAll(raw, ".", ""))
+			if up == "" {
+				continue
+			}
+			toks = append(toks, token{raw: raw, up: up, segment: i})
+		}
+	}
+
+	return toks
+}
+
+type parser struct {
+	comps Components
+	conf  map[Field]float64
+
+	line1 []token
+	line2 []token
+	city  []token
+}
+
+// parseTail consumes country, postal code and state from the end of the token list and returns the
+// remaining tokens.
+func (p *parser) parseTail(toks []token) []token {
+	end := len(toks)
+
+	if n, code := matchSuffix(toks[:end], countries, 5); n > 0 && n < end {
+		p.comps.Country = code
+		p.conf[FieldCountry] = 1
+		end -= n
+	}
+
+	hasPostal := false
+	if end > 1 && !isBoxOrUnit(toks[end-2].up) {
+		last := toks[end-1].up
+		if m := zipRegex.FindStringSubmatch(last); m != nil {
+			p.comps.PostalOrZip = m[1]
+			p.comps.ZipPlus4 = m[2]
+			hasPostal = true
+			end--
+		} else if m := postalCodeRegex.FindStringSubmatch(last); m != nil {
+			p.comps.PostalOrZip = m[1] + " " + m[2]
+			hasPostal = true
+			end--
+		} else if end > 2 && postalLDURegex.MatchString(last) && postalFSARegex.MatchString(toks[end-2].up) {
+			p.comps.PostalOrZip = toks[end-2].up + " " + last
+			hasPostal = true
+			end -= 2
+		}
+	}
+	if hasPostal {
+		p.conf[FieldPostalOrZip] = 1
+	}
+
+	if end > 1 {
+		last := toks[end-1]
+		boundary := toks[end-2].segment != last.segment
+		_, us := usStates[last.up]
+		_, ca := caProvinces[last.up]
+		if (us || ca) && (hasPostal || boundary || (end > 2 && !isAmbiguousState(last.up))) {
+			p.comps.ProvinceOrState = last.up
+			p.conf[FieldProvinceOrState] = 1
+			end--
+		} else if n, code := matchStateName(toks[:end]); n > 0 && n < end && (hasPostal || toks[end-n-1].segment != toks[end-n].segment) {
+			p.comps.ProvinceOrState = code
+			p.conf[FieldProvinceOrState] = 0.9
+			end -= n
+		}
+	}
+
+	p.inferCountry()
+
+	return toks[:end]
+}
+
+// isAmbiguousState reports whether a state code is also a common street type, unit designator or
+// directional, e.g. "CT" (court) or "FL" (floor).
+func isAmbiguousState(code string) bool {
+	_, street := streetTypes[code]
+	_, unit := unitDesignators[code]
+	_, dir := directionals[code]
+
+	return street || unit || dir
+}
+
+// isBoxOrUnit reports whether s introduces a number that must not be mistaken for a postal code, e.g.
+// "BOX 12345".
+func isBoxOrUnit(s string) bool {
+	_, unit := unitDesignators[s]
+
+	return unit || s == "BOX"
+}
+
+func (p *parser) inferCountry() {
+	postalCA := postalCodeRegex.MatchString(strings.ReplaceAll(p.comps.PostalOrZip, " ", ""))
+	postalUS := zipRegex.MatchString(p.comps.PostalOrZip)
+	_, stateCA := caProvinces[p.comps.ProvinceOrState]
+	_, stateUS := usStates[p.comps.ProvinceOrState]
+
+	if p.comps.Country == "" {
+		switch {
+		case postalCA || (stateCA && !postalUS):
+			p.comps.Country = "CA"
+			p.conf[FieldCountry] = 0.9
+		case postalUS || stateUS:
+			p.comps.Country = "US"
+			p.conf[FieldCountry] = 0.9
+		}
+	}
+
+	// Penalise components that contradict each other, e.g. a Canadian postal code with a US state.
+	if (postalCA && stateUS && !stateCA) || (postalUS && stateCA && !stateUS) {
+		p.conf[FieldPostalOrZip] /= 2
+		p.conf[FieldProvinceOrState] /= 2
+	}
+}
+
+// parseHead parses the delivery line(s) and city from the tokens left over by parseTail.
+func (p *parser) parseHead(toks []token) {
+	segments := splitSegments(toks)
+	if len(segments) == 0 {
+		return
+	}
+
+	// With comma separated segments, the last one is the city unless it looks like a unit.
+	if len(segments) > 1 && !isUnitStart(segments[len(segments)-1], 0) {
+		p.city = segments[len(segments)-1]
+		p.conf[FieldCity] = 0.95
+		segments = segments[:len(segments)-1]
+	}
+
+	start := -1
+	for i, segment := range segments {
+		if matchesDeliveryLine(segment) {
+			start = i
+			break
+		}
+	}
+	if start < 0 && len(segments) == 1 && p.city == nil && (p.comps.ProvinceOrState != "" || p.comps.PostalOrZip != "") {
+		// Only a city precedes the state or postal code, e.g. "Springfield, IL 62704".
+		p.city = segments[0]
+		p.conf[FieldCity] = 0.7
+		return
+	}
+	if start < 0 {
+		p.line1 = segments[0]
+		p.conf[FieldLine1] = 0.3
+		for _, segment := range segments[1:] {
+			p.line2 = append(p.line2, segment...)
+			p.conf[FieldLine2] = 0.5
+		}
+		return
+	}
+
+	var recipient []string
+	for _, segment := range segments[:start] {
+		recipient = append(recipient, joinUpper(segment))
+	}
+	p.comps.Recipient = strings.Join(recipient, ", ")
+
+	bounded := p.city != nil || len(segments) > 1
+	n := p.parseDeliveryLine(segments[start], bounded)
+	n += p.parseUnits(segments[start][n:], bounded)
+	leftover := segments[start][n:]
+	if len(leftover) > 0 {
+		if bounded {
+			p.line1 = append(p.line1, leftover...)
+			p.conf[FieldLine1] *= 0.9
+		} else {
+			p.city = leftover
+			p.comps.City = joinUpper(leftover)
+		}
+	}
+
+	for _, segment := range segments[start+1:] {
+		if p.comps.Station == "" && p.comps.StreetNumber == "" && p.parseStation(segment) == len(segment) {
+			p.line2 = append(p.line2, segment...)
+			p.conf[FieldLine2] = 1
+			continue
+		}
+		if n, _, _ := scanUnits(segment, true); n == len(segment) {
+			p.parseUnits(segment, true)
+			continue
+		}
+		p.line2 = append(p.line2, segment...)
+		p.conf[FieldLine2] = 0.6
+	}
+}
+
+func splitSegments(toks []token) [][]token {
+	var segments [][]token
+	for i, tok := range toks {
+		if i == 0 || tok.segment != toks[i-1].segment {
+			segments = append(segments, nil)
+		}
+		segments[len(segments)-1] = append(segments[len(segments)-1], tok)
+	}
+
+	return segments
+}
+
+func matchesDeliveryLine(toks []token) bool {
+	return streetNumberRegex.MatchString(toks[0].up) ||
+		matchMilitary(toks) > 0 ||
+		matchGeneralDelivery(toks) > 0 ||
+		matchPOBox(toks) > 0 ||
+		matchRuralRoute(toks) > 0
+}
+
+// parseDeliveryLine parses a street, PO box, rural route or general delivery line and returns the number
+// of tokens consumed. bounded reports whether toks ends at a segment boundary rather than running into the
+// city name.
+func (p *parser) parseDeliveryLine(toks []token, bounded bool) int {
+	if n := matchMilitary(toks); n > 0 {
+		p.comps.MilitaryUnit = toks[0].up + " " + toks[1].up
+		if m := matchPOBox(toks[n:]); m > 0 {
+			p.comps.POBox = toks[n+m].up
+			n += m + 1
+		}
+		return p.setLine1(toks[:n], 0.95)
+	}
+
+	if n := matchGeneralDelivery(toks); n > 0 {
+		p.comps.GeneralDelivery = true
+		n += p.parseStation(toks[n:])
+		return p.setLine1(toks[:n], 0.95)
+	}
+
+	if n := matchPOBox(toks); n > 0 {
+		p.comps.POBox = toks[n].up
+		n++
+		n += p.parseStation(toks[n:])
+		return p.setLine1(toks[:n], 0.95)
+	}
+
+	if n := matchRuralRoute(toks); n > 0 {
+		p.comps.RuralRouteType = ruralRouteType(toks[:n])
+		p.comps.RuralRouteNumber = toks[n].up
+		n++
+		if m := matchPOBox(toks[n:]); m > 0 {
+			p.comps.POBox = toks[n+m].up
+			n += m + 1
+		}
+		n += p.parseStation(toks[n:])
+		return p.setLine1(toks[:n], 0.95)
+	}
+
+	return p.parseStreet(toks, bounded)
+}
+
+func (p *parser) parseStreet(toks []token, bounded bool) int {
+	i := 0
+	number := toks[i].up
+	i++
+	if i < len(toks) && fractionRegex.MatchString(toks[i].up) {
+		number += " " + toks[i].up
+		i++
+	}
+	// Canadian addresses write the unit before the civic number, e.g. "12-345 Main St".
+	if p.comps.Country == "CA" && strings.Contains(number, "-") {
+		unit, civic, _ := strings.Cut(number, "-")
+		p.comps.UnitID = unit
+		number = civic
+	}
+	p.comps.StreetNumber = number
+
+	if i+1 < len(toks) {
+		if dir, ok := directionals[toks[i].up]; ok && !isStreetType(toks[i+1].up) && !isUnitStart(toks, i+1) {
+			p.comps.PreDirection = dir
+			i++
+		}
+	}
+
+	if n := matchPrefix(toks[i:], numberedRoutes, 2); n > 0 && i+n < len(toks) && hasDigitRegex.MatchString(toks[i+n].up) {
+		p.comps.StreetName = joinUpper(toks[i : i+n+1])
+		i += n + 1
+		i += p.parsePostDirection(toks, i, bounded)
+		return p.setLine1(toks[:i], 0.9)
+	}
+
+	nameStart := i
+	for j := i; j < len(toks); j++ {
+		if j > nameStart && isUnitStart(toks, j) {
+			break
+		}
+		if j == nameStart || !isStreetType(toks[j].up) {
+			continue
+		}
+		// Prefer the last of consecutive street types, e.g. "PARK PLACE DR".
+		for j+1 < len(toks) && isStreetType(toks[j+1].up) {
+			j++
+		}
+		p.comps.StreetName = joinUpper(toks[nameStart:j])
+		p.comps.StreetType = streetTypes[toks[j].up]
+		i = j + 1
+		i += p.parsePostDirection(toks, i, bounded)
+		return p.setLine1(toks[:i], 0.95)
+	}
+
+	// No street type; the name runs up to the first unit designator. When the line is not bounded by a
+	// comma, the name is assumed to be a single word with the city following it.
+	end := len(toks)
+	for j := nameStart + 1; j < len(toks); j++ {
+		if isUnitStart(toks, j) {
+			end = j
+			break
+		}
+	}
+	conf := 0.8
+	if !bounded && end == len(toks) {
+		end = min(nameStart+1, len(toks))
+		conf = 0.5
+	}
+	p.comps.StreetName = joinUpper(toks[nameStart:end])
+
+	return p.setLine1(toks[:end], conf)
+}
+
+func (p *parser) parsePostDirection(toks []token, i int, bounded bool) int {
+	if i >= len(toks) {
+		return 0
+	}
+	dir, ok := directionals[toks[i].up]
+	if !ok {
+		return 0
+	}
+	// A spelled out directional after the street type could be the start of the city, e.g. "EAST LANSING".
+	if len(toks[i].up) > 2 && !bounded && i+1 < len(toks) && !isUnitStart(toks, i+1) {
+		return 0
+	}
+	p.comps.PostDirection = dir
+
+	return 1
+}
+
+// parseStation consumes a Canada Post station qualifier, e.g. "STN MAIN" or "RPO WESTDALE".
+func (p *parser) parseStation(toks []token) int {
+	if len(toks) < 2 {
+		return 0
+	}
+	switch toks[0].up {
+	case "STN", "STATION", "RPO", "SUCC", "PDF":
+		p.comps.Station = toks[0].up + " " + toks[1].up
+		return 2
+	}
+
+	return 0
+}
+
+// parseUnits consumes secondary unit designators at the start of toks and returns the number of tokens
+// consumed.
+func (p *parser) parseUnits(toks []token, bounded bool) int {
+	n, unitType, unitID := scanUnits(toks, bounded)
+	if n == 0 {
+		return 0
+	}
+	if p.comps.UnitType == "" {
+		p.comps.UnitType = unitType
+		if p.comps.UnitID == "" {
+			p.comps.UnitID = unitID
+		}
+	}
+	p.line2 = append(p.line2, toks[:n]...)
+	p.conf[FieldLine2] = 1
+
+	return n
+}
+
+// scanUnits returns the number of tokens taken by secondary unit designators at the start of toks along
+// with the first designator and its identifier.
+func scanUnits(toks []token, bounded bool) (n int, unitType, unitID string) {
+	for n < len(toks) {
+		unit, ok := unitDesignators[toks[n].up]
+		if !ok {
+			break
+		}
+		width := 1
+		id := ""
+		switch {
+		case n+2 < len(toks) && toks[n+1].up == "#" && unit.abbr != "#":
+			// "APT # 4"
+			width, id = 3, toks[n+2].up
+		case n+1 < len(toks) && (unit.requiresID || bounded || isUnitID(toks[n+1].up)):
+			width, id = 2, toks[n+1].up
+		case unit.requiresID:
+			return n, unitType, unitID
+		}
+		if unitType == "" {
+			unitType, unitID = unit.abbr, id
+		}
+		n += width
+	}
+
+	return n, unitType, unitID
+}
+
+func (p *parser) setLine1(toks []token, conf float64) int {
+	p.line1 = toks
+	p.conf[FieldLine1] = conf
+
+	return len(toks)
+}
+
+func (p *parser) result() Result {
+	if p.city != nil {
+		p.comps.City = joinUpper(p.city)
+		if _, ok := p.conf[FieldCity]; !ok {
+			p.conf[FieldCity] = 0.75
+			if p.conf[FieldLine1] < 0.8 {
+				p.conf[FieldCity] = 0.45
+			}
+		}
+	}
+
+	postal := p.comps.PostalOrZip
+	if p.comps.ZipPlus4 != "" {
+		postal += "-" + p.comps.ZipPlus4
+	}
+
+	res := Result{
+		Address: postgrid.Address{
+			Line1:           joinRaw(p.line1),
+			Line2:           joinRaw(p.line2),
+			City:            joinRaw(p.city),
+			ProvinceOrState: p.comps.ProvinceOrState,
+			PostalOrZip:     postal,
+			Country:         p.comps.Country,
+		},
+		Components:      p.comps,
+		FieldConfidence: p.conf,
+	}
+	for _, f := range []Field{FieldLine1, FieldCity, FieldProvinceOrState, FieldPostalOrZip} {
+		res.Confidence += p.conf[f] / 4
+	}
+
+	return res
+}
+
+func isStreetType(s string) bool {
+	_, ok := streetTypes[s]
+	return ok
+}
+
+// isUnitStart reports whether toks[i] starts a secondary unit, e.g. "APT 4" or "# 4".
+func isUnitStart(toks []token, i int) bool {
+	if i >= len(toks) {
+		return false
+	}
+	unit, ok := unitDesignators[toks[i].up]
+	if !ok {
+		return false
+	}
+
+	return !unit.requiresID || i+1 < len(toks)
+}
+
+// isUnitID reports whether s looks like a unit identifier rather than a word, e.g. "4B" or "A".
+func isUnitID(s string) bool {
+	return len(s) == 1 || hasDigitRegex.MatchString(s)
+}
+
+// matchMilitary returns the number of tokens in a military unit designator at the start of toks, e.g.
+// "PSC 1234" or "UNIT 2050".
+func matchMilitary(toks []token) int {
+	if len(toks) < 2 || !hasDigitRegex.MatchString(toks[1].up) {
+		return 0
+	}
+	switch toks[0].up {
+	case "PSC", "CMR":
+		return 2
+	case "UNIT":
+		// "UNIT 2050 BOX 4190"; without a box, UNIT is a secondary unit designator.
+		if matchPOBox(toks[2:]) > 0 {
+			return 2
+		}
+	}
+
+	return 0
+}
+
+func matchGeneralDelivery(toks []token) int {
+	if len(toks) >= 2 && toks[0].up == "GENERAL" && toks[1].up == "DELIVERY" {
+		return 2
+	}
+	if len(toks) >= 1 && toks[0].up == "GD" {
+		return 1
+	}
+
+	return 0
+}
+
+var poBoxPhrases = map[string]bool{
+	"PO BOX": true, "P O BOX": true, "POST OFFICE BOX": true, "POBOX": true, "POB": true, "BOX": true,
+	"CP": true, "C P": true, "CASE POSTALE": true,
+}
+
+// matchPOBox returns the number of tokens in a PO box designator at the start of toks, provided that it
+// is followed by a box number.
+func matchPOBox(toks []token) int {
+	n := matchPrefix(toks, poBoxPhrases, 3)
+	if n == 0 || n >= len(toks) || !hasDigitRegex.MatchString(toks[n].up) {
+		return 0
+	}
+
+	return n
+}
+
+var ruralRoutePhrases = map[string]bool{
+	"RR": true, "R R": true, "RURAL ROUTE": true, "RFD": true, "RURAL DELIVERY": true,
+	"HC": true, "HCR": true, "HIGHWAY CONTRACT": true, "HIGHWAY CONTRACT ROUTE": true, "STAR ROUTE": true,
+}
+
+// matchRuralRoute returns the number of tokens in a rural route designator at the start of toks,
+// provided that it is followed by a route number.
+func matchRuralRoute(toks []token) int {
+	n := matchPrefix(toks, ruralRoutePhrases, 3)
+	if n == 0 || n >= len(toks) || !hasDigitRegex.MatchString(toks[n].up) {
+		return 0
+	}
+
+	return n
+}
+
+func ruralRouteType(toks []token) string {
+	switch toks[0].up {
+	case "HC", "HCR", "HIGHWAY", "STAR":
+		return "HC"
+	}
+
+	return "RR"
+}
+
+// matchPrefix returns the length of the longest phrase of up to maxLen tokens at the start of toks that
+// is present in phrases.
+func matchPrefix(toks []token, phrases map[string]bool, maxLen int) int {
+	for n := min(maxLen, len(toks)); n > 0; n-- {
+		if phrases[joinUpper(toks[:n])] {
+			return n
+		}
+	}
+
+	return 0
+}
+
+// matchSuffix returns the length and value of the longest phrase of up to maxLen tokens at the end of
+// toks that is present in phrases.
+func matchSuffix(toks []token, phrases map[string]string, maxLen int) (int, string) {
+	for n := min(maxLen, len(toks)); n > 0; n-- {
+		if v, ok := phrases[joinUpper(toks[len(toks)-n:])]; ok {
+			return n, v
+		}
+	}
+
+	return 0, ""
+}
+
+// matchStateName matches a spelled out state or province name at the end of toks and returns its code.
+func matchStateName(toks []token) (int, string) {
+	for n := min(4, len(toks)); n > 0; n-- {
+		name := joinUpper(toks[len(toks)-n:])
+		for code, full := range usStates {
+			if full == name {
+				return n, code
+			}
+		}
+		for code, full := range caProvinces {
+			if full == name {
+				return n, code
+			}
+		}
+	}
+
+	return 0, ""
+}
+
+func joinUpper(toks []token) string {
+	parts := make([]string, len(toks))
+	for i, tok := range toks {
+		parts[i] = tok.up
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func joinRaw(toks []token) string {
+	var b strings.Builder
+	for i, tok := range toks {
+		if i > 0 && toks[i-1].raw != "#" {
+			b.WriteByte(' ')
+		}
+		b.WriteString(tok.raw)
+	}
+
+	return b.String()
+}
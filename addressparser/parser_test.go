@@ -0,0 +1,628 @@
+package addressparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	postgrid "github.com/bloomcredit/bloomcredit-postgrid-sdk"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          string
+		want           postgrid.Address
+		wantComponents Components
+		wantConfidence float64
+		wantErr        assert.ErrorAssertionFunc
+	}{
+		{
+			name:  "comma separated with unit",
+			input: "251 e 13th st frnt a, New York, NY 10003",
+			want: postgrid.Address{
+				Line1:           "251 e 13th st",
+				Line2:           "frnt a",
+				City:            "New York",
+				ProvinceOrState: "NY",
+				PostalOrZip:     "10003",
+				Country:         "US",
+			},
+			wantComponents: Components{
+				StreetNumber:    "251",
+				PreDirection:    "E",
+				StreetName:      "13TH",
+				StreetType:      "ST",
+				UnitType:        "FRNT",
+				UnitID:          "A",
+				City:            "NEW YORK",
+				ProvinceOrState: "NY",
+				PostalOrZip:     "10003",
+				Country:         "US",
+			},
+			wantConfidence: 0.95,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:  "no commas with zip+4",
+			input: "123 Main Street Apt 4B Springfield IL 62704-1234",
+			want: postgrid.Address{
+				Line1:           "123 Main Street",
+				Line2:           "Apt 4B",
+				City:            "Springfield",
+				ProvinceOrState: "IL",
+				PostalOrZip:     "62704-1234",
+				Country:         "US",
+			},
+			wantComponents: Components{
+				StreetNumber:    "123",
+				StreetName:      "MAIN",
+				StreetType:      "ST",
+				UnitType:        "APT",
+				UnitID:          "4B",
+				City:            "SPRINGFIELD",
+				ProvinceOrState: "IL",
+				PostalOrZip:     "62704",
+				ZipPlus4:        "1234",
+				Country:         "US",
+			},
+			wantConfidence: 0.9,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:  "zip+4 without hyphen",
+			input: "1 Infinite Loop, Cupertino, CA 950141234",
+			want: postgrid.Address{
+				Line1:           "1 Infinite Loop",
+				City:            "Cupertino",
+				ProvinceOrState: "CA",
+				PostalOrZip:     "95014-1234",
+				Country:         "US",
+			},
+			wantComponents: Components{
+				StreetNumber:    "1",
+				StreetName:      "INFINITE",
+				StreetType:      "LOOP",
+				City:            "CUPERTINO",
+				ProvinceOrState: "CA",
+				PostalOrZip:     "95014",
+				ZipPlus4:        "1234",
+				Country:         "US",
+			},
+			wantConfidence: 0.95,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:  "hash unit designator",
+			input: "Acme Corp, 500 Market St #300, San Francisco, CA 94105",
+			want: postgrid.Address{
+				Line1:           "500 Market St",
+				Line2:           "#300",
+				City:            "San Francisco",
+				ProvinceOrState: "CA",
+				PostalOrZip:     "94105",
+				Country:         "US",
+			},
+			wantComponents: Components{
+				Recipient:       "ACME CORP",
+				StreetNumber:    "500",
+				StreetName:      "MARKET",
+				StreetType:      "ST",
+				UnitType:        "#",
+				UnitID:          "300",
+				City:            "SAN FRANCISCO",
+				ProvinceOrState: "CA",
+				PostalOrZip:     "94105",
+				Country:         "US",
+			},
+			wantConfidence: 0.95,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:  "designator followed by hash",
+			input: "123 Main St Apt#4 Springfield IL",
+			want: postgrid.Address{
+				Line1:           "123 Main St",
+				Line2:           "Apt #4",
+				City:            "Springfield",
+				ProvinceOrState: "IL",
+				Country:         "US",
+			},
+			wantComponents: Components{
+				StreetNumber:    "123",
+				StreetName:      "MAIN",
+				StreetType:      "ST",
+				UnitType:        "APT",
+				UnitID:          "4",
+				City:            "SPRINGFIELD",
+				ProvinceOrState: "IL",
+				Country:         "US",
+			},
+			wantConfidence: 0.6,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:  "unit in its own segment",
+			input: "100 N Main St, Suite 200, Ann Arbor, MI 48104",
+			want: postgrid.Address{
+				Line1:           "100 N Main St",
+				Line2:           "Suite 200",
+				City:            "Ann Arbor",
+				ProvinceOrState: "MI",
+				PostalOrZip:     "48104",
+				Country:         "US",
+			},
+			wantComponents: Components{
+				StreetNumber:    "100",
+				PreDirection:    "N",
+				StreetName:      "MAIN",
+				StreetType:      "ST",
+				UnitType:        "STE",
+				UnitID:          "200",
+				City:            "ANN ARBOR",
+				ProvinceOrState: "MI",
+				PostalOrZip:     "48104",
+				Country:         "US",
+			},
+			wantConfidence: 0.95,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:  "post directional",
+			input: "1600 Pennsylvania Avenue NW Washington DC 20500",
+			want: postgrid.Address{
+				Line1:           "1600 Pennsylvania Avenue NW",
+				City:            "Washington",
+				ProvinceOrState: "DC",
+				PostalOrZip:     "20500",
+				Country:         "US",
+			},
+			wantComponents: Components{
+				StreetNumber:    "1600",
+				StreetName:      "PENNSYLVANIA",
+				StreetType:      "AVE",
+				PostDirection:   "NW",
+				City:            "WASHINGTON",
+				ProvinceOrState: "DC",
+				PostalOrZip:     "20500",
+				Country:         "US",
+			},
+			wantConfidence: 0.9,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:  "directional used as street name",
+			input: "123 North St, Springfield, IL 62704",
+			want: postgrid.Address{
+				Line1:           "123 North St",
+				City:            "Springfield",
+				ProvinceOrState: "IL",
+				PostalOrZip:     "62704",
+				Country:         "US",
+			},
+			wantComponents: Components{
+				StreetNumber:    "123",
+				StreetName:      "NORTH",
+				StreetType:      "ST",
+				City:            "SPRINGFIELD",
+				ProvinceOrState: "IL",
+				PostalOrZip:     "62704",
+				Country:         "US",
+			},
+			wantConfidence: 0.95,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:  "street type used as street name",
+			input: "123 Court St Brooklyn NY 11201",
+			want: postgrid.Address{
+				Line1:           "123 Court St",
+				City:            "Brooklyn",
+				ProvinceOrState: "NY",
+				PostalOrZip:     "11201",
+				Country:         "US",
+			},
+			wantComponents: Components{
+				StreetNumber:    "123",
+				StreetName:      "COURT",
+				StreetType:      "ST",
+				City:            "BROOKLYN",
+				ProvinceOrState: "NY",
+				PostalOrZip:     "11201",
+				Country:         "US",
+			},
+			wantConfidence: 0.9,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:  "fractional street number",
+			input: "123 1/2 Elm St, Springfield, IL",
+			want: postgrid.Address{
+				Line1:           "123 1/2 Elm St",
+				City:            "Springfield",
+				ProvinceOrState: "IL",
+				Country:         "US",
+			},
+			wantComponents: Components{
+				StreetNumber:    "123 1/2",
+				StreetName:      "ELM",
+				StreetType:      "ST",
+				City:            "SPRINGFIELD",
+				ProvinceOrState: "IL",
+				Country:         "US",
+			},
+			wantConfidence: 0.7,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:  "grid street number",
+			input: "W156N8480 Pilgrim Rd, Menomonee Falls, WI 53051",
+			want: postgrid.Address{
+				Line1:           "W156N8480 Pilgrim Rd",
+				City:            "Menomonee Falls",
+				ProvinceOrState: "WI",
+				PostalOrZip:     "53051",
+				Country:         "US",
+			},
+			wantComponents: Components{
+				StreetNumber:    "W156N8480",
+				StreetName:      "PILGRIM",
+				StreetType:      "RD",
+				City:            "MENOMONEE FALLS",
+				ProvinceOrState: "WI",
+				PostalOrZip:     "53051",
+				Country:         "US",
+			},
+			wantConfidence: 0.95,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:  "numbered highway",
+			input: "4500 Highway 101 N, Santa Rosa, CA 95403",
+			want: postgrid.Address{
+				Line1:           "4500 Highway 101 N",
+				City:            "Santa Rosa",
+				ProvinceOrState: "CA",
+				PostalOrZip:     "95403",
+				Country:         "US",
+			},
+			wantComponents: Components{
+				StreetNumber:    "4500",
+				StreetName:      "HIGHWAY 101",
+				PostDirection:   "N",
+				City:            "SANTA ROSA",
+				ProvinceOrState: "CA",
+				PostalOrZip:     "95403",
+				Country:         "US",
+			},
+			wantConfidence: 0.95,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:  "no street type without commas",
+			input: "42 Broadway New York NY 10004",
+			want: postgrid.Address{
+				Line1:           "42 Broadway",
+				City:            "New York",
+				ProvinceOrState: "NY",
+				PostalOrZip:     "10004",
+				Country:         "US",
+			},
+			wantComponents: Components{
+				StreetNumber:    "42",
+				StreetName:      "BROADWAY",
+				City:            "NEW YORK",
+				ProvinceOrState: "NY",
+				PostalOrZip:     "10004",
+				Country:         "US",
+			},
+			wantConfidence: 0.7,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:  "spelled out state",
+			input: "1 Infinite Loop Cupertino California 95014",
+			want: postgrid.Address{
+				Line1:           "1 Infinite Loop",
+				City:            "Cupertino",
+				ProvinceOrState: "CA",
+				PostalOrZip:     "95014",
+				Country:         "US",
+			},
+			wantComponents: Components{
+				StreetNumber:    "1",
+				StreetName:      "INFINITE",
+				StreetType:      "LOOP",
+				City:            "CUPERTINO",
+				ProvinceOrState: "CA",
+				PostalOrZip:     "95014",
+				Country:         "US",
+			},
+			wantConfidence: 0.85,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:  "PO box",
+			input: "P.O. Box 4567, Austin, TX 78701",
+			want: postgrid.Address{
+				Line1:           "P.O. Box 4567",
+				City:            "Austin",
+				ProvinceOrState: "TX",
+				PostalOrZip:     "78701",
+				Country:         "US",
+			},
+			wantComponents: Components{
+				POBox:           "4567",
+				City:            "AUSTIN",
+				ProvinceOrState: "TX",
+				PostalOrZip:     "78701",
+				Country:         "US",
+			},
+			wantConfidence: 0.95,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:  "PO box number is not a zip",
+			input: "PO Box 12345",
+			want: postgrid.Address{
+				Line1: "PO Box 12345",
+			},
+			wantComponents: Components{
+				POBox: "12345",
+			},
+			wantConfidence: 0.2,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:  "canadian PO box with station",
+			input: "PO Box 12, Stn Main, Toronto, ON M5W 1A1",
+			want: postgrid.Address{
+				Line1:           "PO Box 12",
+				Line2:           "Stn Main",
+				City:            "Toronto",
+				ProvinceOrState: "ON",
+				PostalOrZip:     "M5W 1A1",
+				Country:         "CA",
+			},
+			wantComponents: Components{
+				POBox:           "12",
+				Station:         "STN MAIN",
+				City:            "TORONTO",
+				ProvinceOrState: "ON",
+				PostalOrZip:     "M5W 1A1",
+				Country:         "CA",
+			},
+			wantConfidence: 0.95,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:  "rural route with box",
+			input: "RR 2 Box 15, Mountain View, AR 72560",
+			want: postgrid.Address{
+				Line1:           "RR 2 Box 15",
+				City:            "Mountain View",
+				ProvinceOrState: "AR",
+				PostalOrZip:     "72560",
+				Country:         "US",
+			},
+			wantComponents: Components{
+				RuralRouteType:   "RR",
+				RuralRouteNumber: "2",
+				POBox:            "15",
+				City:             "MOUNTAIN VIEW",
+				ProvinceOrState:  "AR",
+				PostalOrZip:      "72560",
+				Country:          "US",
+			},
+			wantConfidence: 0.95,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:  "highway contract route",
+			input: "HC 1 Box 50 Big Sky MT 59716",
+			want: postgrid.Address{
+				Line1:           "HC 1 Box 50",
+				City:            "Big Sky",
+				ProvinceOrState: "MT",
+				PostalOrZip:     "59716",
+				Country:         "US",
+			},
+			wantComponents: Components{
+				RuralRouteType:   "HC",
+				RuralRouteNumber: "1",
+				POBox:            "50",
+				City:             "BIG SKY",
+				ProvinceOrState:  "MT",
+				PostalOrZip:      "59716",
+				Country:          "US",
+			},
+			wantConfidence: 0.9,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:  "military",
+			input: "PSC 1234 Box 5678, APO AE 09001",
+			want: postgrid.Address{
+				Line1:           "PSC 1234 Box 5678",
+				City:            "APO",
+				ProvinceOrState: "AE",
+				PostalOrZip:     "09001",
+				Country:         "US",
+			},
+			wantComponents: Components{
+				MilitaryUnit:    "PSC 1234",
+				POBox:           "5678",
+				City:            "APO",
+				ProvinceOrState: "AE",
+				PostalOrZip:     "09001",
+				Country:         "US",
+			},
+			wantConfidence: 0.95,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:  "general delivery",
+			input: "General Delivery, Whitehorse, YT Y1A 1A1",
+			want: postgrid.Address{
+				Line1:           "General Delivery",
+				City:            "Whitehorse",
+				ProvinceOrState: "YT",
+				PostalOrZip:     "Y1A 1A1",
+				Country:         "CA",
+			},
+			wantComponents: Components{
+				GeneralDelivery: true,
+				City:            "WHITEHORSE",
+				ProvinceOrState: "YT",
+				PostalOrZip:     "Y1A 1A1",
+				Country:         "CA",
+			},
+			wantConfidence: 0.95,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:  "canadian unit-civic number and country",
+			input: "12-345 Queen St W, Toronto, ON M5V2A4, Canada",
+			want: postgrid.Address{
+				Line1:           "12-345 Queen St W",
+				City:            "Toronto",
+				ProvinceOrState: "ON",
+				PostalOrZip:     "M5V 2A4",
+				Country:         "CA",
+			},
+			wantComponents: Components{
+				StreetNumber:    "345",
+				StreetName:      "QUEEN",
+				StreetType:      "ST",
+				PostDirection:   "W",
+				UnitID:          "12",
+				City:            "TORONTO",
+				ProvinceOrState: "ON",
+				PostalOrZip:     "M5V 2A4",
+				Country:         "CA",
+			},
+			wantConfidence: 0.95,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:  "queens hyphenated number",
+			input: "108-15 Queens Blvd, Forest Hills, NY 11375",
+			want: postgrid.Address{
+				Line1:           "108-15 Queens Blvd",
+				City:            "Forest Hills",
+				ProvinceOrState: "NY",
+				PostalOrZip:     "11375",
+				Country:         "US",
+			},
+			wantComponents: Components{
+				StreetNumber:    "108-15",
+				StreetName:      "QUEENS",
+				StreetType:      "BLVD",
+				City:            "FOREST HILLS",
+				ProvinceOrState: "NY",
+				PostalOrZip:     "11375",
+				Country:         "US",
+			},
+			wantConfidence: 0.95,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:  "ambiguous state code is a street type",
+			input: "123 Main Ct",
+			want: postgrid.Address{
+				Line1: "123 Main Ct",
+			},
+			wantComponents: Components{
+				StreetNumber: "123",
+				StreetName:   "MAIN",
+				StreetType:   "CT",
+			},
+			wantConfidence: 0.2,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:  "city only",
+			input: "Springfield, IL 62704",
+			want: postgrid.Address{
+				City:            "Springfield",
+				ProvinceOrState: "IL",
+				PostalOrZip:     "62704",
+				Country:         "US",
+			},
+			wantComponents: Components{
+				City:            "SPRINGFIELD",
+				ProvinceOrState: "IL",
+				PostalOrZip:     "62704",
+				Country:         "US",
+			},
+			wantConfidence: 0.6,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:    "empty",
+			input:   " , ",
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if !tt.wantErr(t, err, "Parse(%v)", tt.input) {
+				return
+			}
+			assert.Equal(t, tt.want, got.Address)
+			assert.Equal(t, tt.wantComponents, got.Components)
+			assert.GreaterOrEqual(t, got.Confidence, tt.wantConfidence)
+		})
+	}
+}
+
+func TestParse_ConflictingCountryLowersConfidence(t *testing.T) {
+	consistent, err := Parse("123 Main St, Toronto, ON M5V 2A4")
+	assert.NoError(t, err)
+	conflicting, err := Parse("123 Main St, Toronto, NY M5V 2A4")
+	assert.NoError(t, err)
+
+	assert.Less(t, conflicting.Confidence, consistent.Confidence)
+}
+
+func TestToAddress(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		minConfidence float64
+		want          postgrid.Address
+	}{
+		{
+			name:          "structured when confident",
+			input:         "100 N Main St, Ann Arbor, MI 48104",
+			minConfidence: 0.9,
+			want: postgrid.Address{
+				Line1:           "100 N Main St",
+				City:            "Ann Arbor",
+				ProvinceOrState: "MI",
+				PostalOrZip:     "48104",
+				Country:         "US",
+			},
+		},
+		{
+			name:          "freeform when not confident",
+			input:         " 42 Broadway New York NY 10004 ",
+			minConfidence: 0.9,
+			want: postgrid.Address{
+				String: "42 Broadway New York NY 10004",
+			},
+		},
+		{
+			name:          "freeform when empty",
+			input:         "",
+			minConfidence: 0,
+			want:          postgrid.Address{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ToAddress(tt.input, tt.minConfidence))
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package addressparser
+
+import (
+	"strings"
+
+	postgrid "github.com/bloomcredit/bloomcredit-postgrid-sdk"
+)
+
+// Weights of the components compared by Similarity. Components that are missing from both addresses are
+// left out and the remaining weights are rescaled.
+const (
+	weightNumber = 0.25
+	weightStreet = 0.25
+	weightUnit   = 0.1
+	weightCity   = 0.1
+	weightState  = 0.1
+	weightPostal = 0.2
+)
+
+// Similarity returns a score between 0 and 1 describing how likely it is that two unverified addresses
+// refer to the same place. Structured and freeform addresses can be compared with each other. Scores of
+// 0.9 and above indicate a likely duplicate.
+//
+// Verified addresses should be compared with postgrid.VerifiedAddress.Fingerprint instead.
+func Similarity(a, b postgrid.Address) float64 {
+	ca, cb := componentsOf(a), componentsOf(b)
+
+	var score, total float64
+	add := func(weight, s float64) {
+		score += weight * s
+		total += weight
+	}
+
+	switch {
+	case ca.POBox != "" && cb.POBox != "":
+		add(weightNumber, equalScore(ca.POBox, cb.POBox))
+		add(weightStreet, equalScore(ca.RuralRouteNumber+ca.MilitaryUnit, cb.RuralRouteNumber+cb.MilitaryUnit))
+	case ca.POBox != "" || cb.POBox != "":
+		// A box is never the same place as a street address.
+		add(weightNumber+weightStreet, 0)
+	case ca.StreetNumber != "" || cb.StreetNumber != "":
+		add(weightNumber, equalScore(ca.StreetNumber, cb.StreetNumber))
+		add(weightStreet, stringSimilarity(street(ca), street(cb)))
+	case firstLine(a) != "" || firstLine(b) != "":
+		add(weightNumber+weightStreet, stringSimilarity(normalizeLine(firstLine(a)), normalizeLine(firstLine(b))))
+	}
+
+	switch {
+	case ca.UnitID == "" && cb.UnitID == "":
+	case ca.UnitID == "" || cb.UnitID == "":
+		// One side omitted the unit; this is common and only weakly suggests a different place.
+		add(weightUnit, 0.5)
+	default:
+		add(weightUnit, equalScore(ca.UnitID, cb.UnitID))
+	}
+
+	if ca.City != "" || cb.City != "" {
+		add(weightCity, stringSimilarity(ca.City, cb.City))
+	}
+	if ca.ProvinceOrState != "" || cb.ProvinceOrState != "" {
+		add(weightState, equalScore(ca.ProvinceOrState, cb.ProvinceOrState))
+	}
+	if ca.PostalOrZip != "" || cb.PostalOrZip != "" {
+		add(weightPostal, postalScore(ca.PostalOrZip, cb.PostalOrZip))
+	}
+
+	if total == 0 {
+		return 0
+	}
+
+	return score / total
+}
+
+// firstLine returns the first line of a. For a freeform address it is the first line parsed from the
+// string, or the whole string when none is found.
+func firstLine(a postgrid.Address) string {
+	if a.Line1 != "" || a.String == "" {
+		return a.Line1
+	}
+
+	if res, err := Parse(a.String); err == nil && res.Address.Line1 != "" {
+		return res.Address.Line1
+	}
+
+	return a.String
+}
+
+// componentsOf parses an address in either freeform or structured form.
+func componentsOf(a postgrid.Address) Components {
+	s := a.String
+	if s == "" {
+		s = strings.Join([]string{
+			strings.ReplaceAll(a.Line1, ",", " "),
+			strings.ReplaceAll(a.Line2, ",", " "),
+			a.City,
+			a.ProvinceOrState + " " + a.PostalOrZip,
+			a.Country,
+		}, ", ")
+	}
+
+	res, err := Parse(s)
+	if err != nil {
+		return Components{}
+	}
+
+	return res.Components
+}
+
+func street(c Components) string {
+	return strings.Join(strings.Fields(strings.Join([]string{c.PreDirection, c.StreetName, c.StreetType, c.PostDirection}, " ")), " ")
+}
+
+// normalizeLine upper cases a line and abbreviates street types, directionals and unit designators.
+func normalizeLine(s string) string {
+	toks := tokenize(strings.ReplaceAll(s, ",", " "))
+	parts := make([]string, len(toks))
+	for i, tok := range toks {
+		parts[i] = tok.up
+		if abbr, ok := streetTypes[tok.up]; ok {
+			parts[i] = abbr
+		} else if abbr, ok := directionals[tok.up]; ok {
+			parts[i] = abbr
+		} else if unit, ok := unitDesignators[tok.up]; ok {
+			parts[i] = unit.abbr
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func equalScore(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+
+	return 0
+}
+
+// postalScore gives partial credit to postal codes that share a prefix, i.e. the same sectional center or
+// forward sortation area.
+func postalScore(a, b string) float64 {
+	switch {
+	case a == b:
+		return 1
+	case len(a) >= 3 && len(b) >= 3 && a[:3] == b[:3]:
+		return 0.5
+	}
+
+	return 0
+}
+
+// stringSimilarity returns 1 minus the normalized Levenshtein distance between a and b.
+func stringSimilarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
@@ -0,0 +1,114 @@
+package addressparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	postgrid "github.com/bloomcredit/bloomcredit-postgrid-sdk"
+)
+
+func TestSimilarity(t *testing.T) {
+	structured := postgrid.Address{
+		Line1:           "100 North Main Street",
+		Line2:           "Suite 200",
+		City:            "Ann Arbor",
+		ProvinceOrState: "MI",
+		PostalOrZip:     "48104",
+		Country:         "US",
+	}
+
+	tests := []struct {
+		name    string
+		a       postgrid.Address
+		b       postgrid.Address
+		wantMin float64
+		wantMax float64
+	}{
+		{
+			name:    "identical",
+			a:       structured,
+			b:       structured,
+			wantMin: 1,
+			wantMax: 1,
+		},
+		{
+			name:    "freeform with abbreviations",
+			a:       structured,
+			b:       postgrid.Address{String: "100 N. Main St. Ste 200, Ann Arbor, MI 48104-1234"},
+			wantMin: 1,
+			wantMax: 1,
+		},
+		{
+			name:    "missing unit",
+			a:       structured,
+			b:       postgrid.Address{String: "100 N Main St, Ann Arbor, MI 48104"},
+			wantMin: 0.9,
+			wantMax: 0.99,
+		},
+		{
+			name:    "typo in street and city",
+			a:       structured,
+			b:       postgrid.Address{String: "100 N Mian St Suite 200, Ann Arbour, MI 48104"},
+			wantMin: 0.9,
+			wantMax: 0.99,
+		},
+		{
+			name:    "different house number",
+			a:       structured,
+			b:       postgrid.Address{String: "102 N Main St Suite 200, Ann Arbor, MI 48104"},
+			wantMin: 0.5,
+			wantMax: 0.8,
+		},
+		{
+			name:    "different unit",
+			a:       structured,
+			b:       postgrid.Address{String: "100 N Main St Suite 300, Ann Arbor, MI 48104"},
+			wantMin: 0.8,
+			wantMax: 0.9,
+		},
+		{
+			name:    "different PO box",
+			a:       postgrid.Address{String: "PO Box 12, Austin, TX 78701"},
+			b:       postgrid.Address{String: "PO Box 13, Austin, TX 78701"},
+			wantMin: 0.5,
+			wantMax: 0.8,
+		},
+		{
+			name:    "unrelated",
+			a:       structured,
+			b:       postgrid.Address{String: "PO Box 4567, Austin, TX 78701"},
+			wantMin: 0,
+			wantMax: 0.2,
+		},
+		{
+			name:    "identical freeform without house number",
+			a:       postgrid.Address{String: "Rural Route Road, Springfield, IL 62701"},
+			b:       postgrid.Address{String: "Rural Route Road, Springfield, IL 62701"},
+			wantMin: 1,
+			wantMax: 1,
+		},
+		{
+			name:    "different freeform streets without house number",
+			a:       postgrid.Address{String: "Old Mill Road, Springfield, IL 62701"},
+			b:       postgrid.Address{String: "Lakeshore Drive, Springfield, IL 62701"},
+			wantMin: 0.4,
+			wantMax: 0.8,
+		},
+		{
+			name:    "empty",
+			a:       postgrid.Address{},
+			b:       postgrid.Address{},
+			wantMin: 0,
+			wantMax: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Similarity(tt.a, tt.b)
+			assert.GreaterOrEqual(t, got, tt.wantMin)
+			assert.LessOrEqual(t, got, tt.wantMax)
+			assert.InDelta(t, got, Similarity(tt.b, tt.a), 1e-9, "Similarity must be symmetric")
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package postgrid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Default Batcher settings.
+const (
+	DefaultBatchWindow = 50 * time.Millisecond
+	DefaultBatchSize   = 100
+)
+
+// ErrBatcherClosed is returned when verifying through a closed Batcher.
+var ErrBatcherClosed = errors.New("postgrid: batcher is closed")
+
+type batcherOptions struct {
+	window  time.Duration
+	maxSize int
+}
+
+// BatcherOption represents optional arguments for constructing a Batcher.
+type BatcherOption interface {
+	apply(*batcherOptions)
+}
+
+type batchWindowOption struct {
+	window time.Duration
+}
+
+func (b batchWindowOption) apply(opts *batcherOptions) {
+	opts.window = b.window
+}
+
+// WithBatchWindow configures how long the Batcher waits for more addresses after the first address of a
+// batch arrives.
+func WithBatchWindow(window time.Duration) BatcherOption {
+	return batchWindowOption{window: window}
+}
+
+type batchSizeOption struct {
+	size int
+}
+
+func (b batchSizeOption) apply(opts *batcherOptions) {
+	opts.maxSize = b.size
+}
+
+// WithBatchSize configures the number of addresses that triggers a batch to be sent before the batch
+// window ends. It is capped at MaxBatchSize.
+func WithBatchSize(size int) BatcherOption {
+	return batchSizeOption{size: size}
+}
+
+// Batcher collects concurrent single address verifications and sends them together through the Batch
+// Verify Addresses endpoint, trading a little latency for far fewer rate limited requests.
+type Batcher struct {
+	client  AddressVerifier
+	window  time.Duration
+	maxSize int
+
+	calls     chan *batchCall
+	closing   chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+	inflight  sync.WaitGroup
+}
+
+type batchCall struct {
+	ctx     context.Context
+	address Address
+	result  chan batchResult
+}
+
+type batchResult struct {
+	address VerifiedAddress
+	err     error
+}
+
+// NewBatcher constructs a Batcher sending batches through the given verifier, usually a *Client. Close
+// must be called to release its resources.
+func NewBatcher(client AddressVerifier, opts ...BatcherOption) *Batcher {
+	options := batcherOptions{
+		window:  DefaultBatchWindow,
+		maxSize: DefaultBatchSize,
+	}
+
+	for _, opt := range opts {
+		opt.apply(&options)
+	}
+
+	b := &Batcher{
+		client:  client,
+		window:  options.window,
+		maxSize: min(max(options.maxSize, 1), MaxBatchSize),
+		calls:   make(chan *batchCall),
+		closing: make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go b.run()
+
+	return b
+}
+
+// VerifyAddress queues the address for the next batch and waits for its result. It returns early with the
+// context's error when ctx is done; an address whose caller gave up before its batch was sent is not sent.
+func (b *Batcher) VerifyAddress(ctx context.Context, req VerifyAddressRequest) (VerifiedAddress, error) {
+	call := &batchCall{
+		ctx:     ctx,
+		address: req.Address,
+		result:  make(chan batchResult, 1),
+	}
+
+	select {
+	case b.calls <- call:
+	case <-b.closing:
+		return VerifiedAddress{}, ErrBatcherClosed
+	case <-ctx.Done():
+		return VerifiedAddress{}, ctx.Err()
+	}
+
+	select {
+	case res := <-call.result:
+		return res.address, res.err
+	case <-ctx.Done():
+		return VerifiedAddress{}, ctx.Err()
+	}
+}
+
+// Close sends any queued addresses, waits for in-flight batches to complete and stops the Batcher.
+func (b *Batcher) Close() error {
+	b.closeOnce.Do(func() {
+		close(b.closing)
+	})
+	<-b.stopped
+
+	return nil
+}
+
+func (b *Batcher) run() {
+	defer close(b.stopped)
+
+	var pending []*batchCall
+	var timer *time.Timer
+	var timeout <-chan time.Time
+	flush := func() {
+		if timer != nil {
+			timer.Stop()
+			timer, timeout = nil, nil
+		}
+		if len(pending) == 0 {
+			return
+		}
+		b.inflight.Add(1)
+		go b.send(pending)
+		pending = nil
+	}
+
+	for {
+		select {
+		case call := <-b.calls:
+			pending = append(pending, call)
+			if len(pending) == 1 {
+				timer = time.NewTimer(b.window)
+				timeout = timer.C
+			}
+			if len(pending) >= b.maxSize {
+				flush()
+			}
+		case <-timeout:
+			flush()
+		case <-b.closing:
+			flush()
+			b.inflight.Wait()
+			return
+		}
+	}
+}
+
+// send verifies the pending calls in a single batch. Addresses are correlated with their results by a
+// generated InputID, falling back to the order of the results when postgrid does not echo the InputID.
+//
+// The batch is sent with the values of the context of its first caller, such as its trace, but is not
+// canceled with it as the batch is shared. It is scheduled with the highest priority of its callers.
+func (b *Batcher) send(pending []*batchCall) {
+	defer b.inflight.Done()
+
+	calls := make(map[string]*batchCall, len(pending))
+	var req BatchVerifyAddressesRequest
+	var ctx context.Context
+	p := PriorityBackground
+	for _, call := range pending {
+		if call.ctx.Err() != nil {
+			continue
+		}
+		if ctx == nil {
+			ctx = context.WithoutCancel(call.ctx)
+		}
+		p = min(p, priority(call.ctx, EndpointVerify))
+		address := call.address
+		address.InputID = strconv.Itoa(len(req.Addresses))
+		calls[address.InputID] = call
+		req.Addresses = append(req.Addresses, address)
+	}
+	if len(req.Addresses) == 0 {
+		return
+	}
+
+	resp, err := b.client.BatchVerifyAddresses(WithPriority(ctx, p), req)
+	if err == nil && len(resp.Results) != len(req.Addresses) {
+		err = fmt.Errorf("postgrid error: expected %d batch results, received %d", len(req.Addresses), len(resp.Results))
+	}
+	if err != nil {
+		for _, call := range calls {
+			call.result <- batchResult{err: err}
+		}
+		return
+	}
+
+	for i, result := range resp.Results {
+		id := result.InputID
+		if _, ok := calls[id]; !ok {
+			id = strconv.Itoa(i)
+		}
+		if call, ok := calls[id]; ok {
+			call.result <- batchResult{address: result.VerifiedAddress}
+			delete(calls, id)
+		}
+	}
+	for _, call := range calls {
+		call.result <- batchResult{err: errors.New("postgrid error: no batch result for address")}
+	}
+}
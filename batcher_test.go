@@ -0,0 +1,225 @@
+package postgrid
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+// newBatchTestServer returns a server answering batch verifications in reverse order, echoing the InputID
+// unless echo is false, and records the size of every batch.
+func newBatchTestServer(tb testing.TB, echo bool) (*httptest.Server, func() []int) {
+	var mu sync.Mutex
+	var sizes []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req BatchVerifyAddressesRequest
+		require.NoError(tb, json.NewDecoder(r.Body).Decode(&req))
+
+		mu.Lock()
+		sizes = append(sizes, len(req.Addresses))
+		mu.Unlock()
+
+		var resp BatchVerifyAddressesResponse
+		for i := range req.Addresses {
+			a := req.Addresses[i]
+			if echo {
+				a = req.Addresses[len(req.Addresses)-1-i]
+			}
+			result := VerifiedAddressResponse{VerifiedAddress: VerifiedAddress{Line1: a.Line1, Status: VerificationStatusVerified}}
+			if echo {
+				result.InputID = a.InputID
+			}
+			resp.Results = append(resp.Results, result)
+		}
+		writeTestResponse(tb, w, resp)
+	}))
+	tb.Cleanup(srv.Close)
+
+	return srv, func() []int {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]int(nil), sizes...)
+	}
+}
+
+func TestBatcher_VerifyAddress(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []BatcherOption
+		echo      bool
+		wantSizes []int
+	}{
+		{
+			name:      "window collects all calls",
+			opts:      []BatcherOption{WithBatchWindow(time.Hour), WithBatchSize(6)},
+			echo:      true,
+			wantSizes: []int{6},
+		},
+		{
+			name:      "batch size splits calls",
+			opts:      []BatcherOption{WithBatchWindow(time.Hour), WithBatchSize(2)},
+			echo:      true,
+			wantSizes: []int{2, 2, 2},
+		},
+		{
+			name:      "results correlated by order without input ids",
+			opts:      []BatcherOption{WithBatchWindow(time.Hour), WithBatchSize(3)},
+			wantSizes: []int{3, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, sizes := newBatchTestServer(t, tt.echo)
+			client := NewClient("", srv.URL, WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Inf, 0)))
+			batcher := NewBatcher(client, tt.opts...)
+			t.Cleanup(func() { batcher.Close() })
+
+			lines := []string{"A", "B", "C", "D", "E", "F"}
+			results := make([]VerifiedAddress, len(lines))
+			errs := make([]error, len(lines))
+			var wg sync.WaitGroup
+			for i, line := range lines {
+				wg.Add(1)
+				go func(i int, line string) {
+					defer wg.Done()
+					results[i], errs[i] = batcher.VerifyAddress(context.Background(), VerifyAddressRequest{Address: Address{Line1: line}})
+				}(i, line)
+			}
+			wg.Wait()
+
+			for i, line := range lines {
+				require.NoError(t, errs[i])
+				assert.Equal(t, line, results[i].Line1)
+			}
+			assert.Equal(t, tt.wantSizes, sizes())
+		})
+	}
+}
+
+func TestBatcher_Window(t *testing.T) {
+	srv, sizes := newBatchTestServer(t, true)
+	client := NewClient("", srv.URL, WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Inf, 0)))
+	batcher := NewBatcher(client, WithBatchWindow(10*time.Millisecond))
+	t.Cleanup(func() { batcher.Close() })
+
+	got, err := batcher.VerifyAddress(context.Background(), VerifyAddressRequest{Address: Address{Line1: "A"}})
+	require.NoError(t, err)
+	assert.Equal(t, "A", got.Line1)
+	assert.Equal(t, []int{1}, sizes())
+}
+
+func TestBatcher_Cancel(t *testing.T) {
+	srv, sizes := newBatchTestServer(t, true)
+	client := NewClient("", srv.URL, WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Inf, 0)))
+	batcher := NewBatcher(client, WithBatchWindow(time.Hour))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	queued := &queuedContext{Context: ctx, queued: make(chan struct{})}
+	done := make(chan error)
+	go func() {
+		_, err := batcher.VerifyAddress(queued, VerifyAddressRequest{Address: Address{Line1: "A"}})
+		done <- err
+	}()
+
+	// Wait until the call is queued before cancelling it.
+	<-queued.queued
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	// The cancelled address is dropped when the batch is flushed.
+	require.NoError(t, batcher.Close())
+	assert.Empty(t, sizes())
+
+	_, err := batcher.VerifyAddress(context.Background(), VerifyAddressRequest{Address: Address{Line1: "B"}})
+	assert.ErrorIs(t, err, ErrBatcherClosed)
+}
+
+// queuedContext closes queued when Done is called a second time, as Batcher.VerifyAddress does once the
+// call is queued and it waits for the result.
+type queuedContext struct {
+	context.Context
+	calls  atomic.Int32
+	queued chan struct{}
+}
+
+func (c *queuedContext) Done() <-chan struct{} {
+	if c.calls.Add(1) == 2 {
+		close(c.queued)
+	}
+
+	return c.Context.Done()
+}
+
+func TestBatcher_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"status":"error","message":"boom"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	client := NewClient("", srv.URL, WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Inf, 0)))
+	batcher := NewBatcher(client, WithBatchWindow(time.Millisecond))
+	t.Cleanup(func() { batcher.Close() })
+
+	_, err := batcher.VerifyAddress(context.Background(), VerifyAddressRequest{Address: Address{Line1: "A"}})
+	assert.ErrorContains(t, err, "boom")
+}
+
+func TestBatcher_Context(t *testing.T) {
+	tests := []struct {
+		name         string
+		priorities   []Priority
+		wantPriority Priority
+	}{
+		{name: "default", wantPriority: PriorityInteractive},
+		{name: "background", priorities: []Priority{PriorityBackground, PriorityBackground}, wantPriority: PriorityBackground},
+		{name: "highest", priorities: []Priority{PriorityBackground, PriorityInteractive}, wantPriority: PriorityInteractive},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := newBatchTestServer(t, true)
+			var mu sync.Mutex
+			var ctxs []context.Context
+			hook := func(ctx context.Context, _ RequestInfo) {
+				mu.Lock()
+				defer mu.Unlock()
+				ctxs = append(ctxs, ctx)
+			}
+			client := NewClient("", srv.URL, WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Inf, 0)),
+				WithRequestHook(hook))
+			n := max(len(tt.priorities), 1)
+			batcher := NewBatcher(client, WithBatchWindow(time.Hour), WithBatchSize(n))
+			t.Cleanup(func() { batcher.Close() })
+
+			var wg sync.WaitGroup
+			for i := 0; i < n; i++ {
+				ctx := context.WithValue(context.Background(), hookContextKey{}, "caller")
+				if tt.priorities != nil {
+					ctx = WithPriority(ctx, tt.priorities[i])
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := batcher.VerifyAddress(ctx, VerifyAddressRequest{Address: Address{Line1: "A"}})
+					assert.NoError(t, err)
+				}()
+			}
+			wg.Wait()
+
+			require.Len(t, ctxs, 1)
+			assert.Equal(t, "caller", ctxs[0].Value(hookContextKey{}))
+			p, ok := PriorityFromContext(ctxs[0])
+			assert.True(t, ok)
+			assert.Equal(t, tt.wantPriority, p)
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package postgrid
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default time to live of cached verification results.
+const (
+	DefaultCacheTTL         = 24 * time.Hour
+	DefaultCacheNegativeTTL = time.Hour
+)
+
+// Cache stores verification results keyed by a hash of the normalized address and the verification
+// options sent to postgrid. Implementations must be safe for concurrent use and must not return entries
+// past their ExpiresAt time.
+//
+// Cache errors never fail a verification: a failed Get is treated as a miss and a failed Set is ignored.
+type Cache interface {
+	Get(ctx context.Context, key string) (CacheEntry, bool, error)
+	Set(ctx context.Context, key string, entry CacheEntry) error
+}
+
+// CacheContainer is implemented by caches that report whether they hold an entry without side effects,
+// such as refreshing its recency or evicting it once expired. Estimates consult the cache with Contains
+// when it is implemented, and with Get otherwise.
+type CacheContainer interface {
+	Contains(ctx context.Context, key string) (bool, error)
+}
+
+// CacheEntry is a verification result stored in a Cache.
+type CacheEntry struct {
+	Address   VerifiedAddress
+	StoredAt  time.Time
+	ExpiresAt time.Time
+}
+
+// CacheInfo describes a verification result that was served from the cache.
+type CacheInfo struct {
+	StoredAt  time.Time
+	ExpiresAt time.Time
+}
+
+// CacheKey returns the key under which the verification result of the address is cached. Casing,
+// punctuation, whitespace and InputID do not affect the key.
+func CacheKey(a Address) string {
+	var parts []string
+	if a.String != "" {
+		parts = []string{normalizeFingerprintPart(a.String)}
+	} else {
+		parts = []string{
+			normalizeFingerprintPart(a.Line1),
+			normalizeFingerprintPart(a.Line2),
+			normalizeFingerprintPart(a.City),
+			normalizeFingerprintPart(a.ProvinceOrState),
+			normalizeFingerprintPart(a.PostalOrZip),
+			normalizeFingerprintPart(a.Country),
+		}
+	}
+	parts = append(parts, verifyParams().Encode())
+
+	sum := sha256.Sum256([]byte(strings.Join(parts, "|")))
+	return hex.EncodeToString(sum[:])
+}
+
+// cacheGet returns the cached result for key, marked with its cache info.
+func (c *Client) cacheGet(ctx context.Context, key string) (VerifiedAddress, bool) {
+	entry, ok, err := c.cache.Get(ctx, key)
+	if err != nil || !ok {
+		return VerifiedAddress{}, false
+	}
+
+	v := entry.Address
+	v.CacheInfo = &CacheInfo{StoredAt: entry.StoredAt, ExpiresAt: entry.ExpiresAt}
+
+	return v, true
+}
+
+// cacheContains reports whether the cache holds a result for key, without side effects when the cache is a
+// CacheContainer.
+func (c *Client) cacheContains(ctx context.Context, key string) bool {
+	if container, ok := c.cache.(CacheContainer); ok {
+		ok, err := container.Contains(ctx, key)
+		return err == nil && ok
+	}
+
+	_, ok := c.cacheGet(ctx, key)
+	return ok
+}
+
+// cacheSet stores v under key using the negative TTL for failed verifications.
+func (c *Client) cacheSet(ctx context.Context, key string, v VerifiedAddress) {
+	ttl := c.cacheTTL
+	if v.Status == VerificationStatusFailed {
+		ttl = c.cacheNegativeTTL
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	now := time.Now()
+	_ = c.cache.Set(ctx, key, CacheEntry{
+		Address:   v,
+		StoredAt:  now,
+		ExpiresAt: now.Add(ttl),
+	})
+}
+
+var (
+	_ Cache          = (*LRUCache)(nil)
+	_ CacheContainer = (*LRUCache)(nil)
+)
+
+// LRUCache is an in-memory Cache that evicts the least recently used entry once it holds capacity
+// entries.
+type LRUCache struct {
+	capacity int
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type lruItem struct {
+	key   string
+	entry CacheEntry
+}
+
+// NewLRUCache constructs an in-memory cache holding at most capacity entries. A capacity below 1 holds a
+// single entry.
+func NewLRUCache(capacity int) *LRUCache {
+	return &LRUCache{
+		capacity: max(capacity, 1),
+		now:      time.Now,
+		entries:  map[string]*list.Element{},
+		order:    list.New(),
+	}
+}
+
+// Get returns the entry stored under key unless it has expired.
+func (l *LRUCache) Get(_ context.Context, key string) (CacheEntry, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	el, ok := l.entries[key]
+	if !ok {
+		return CacheEntry{}, false, nil
+	}
+
+	item := el.Value.(*lruItem)
+	if !l.now().Before(item.entry.ExpiresAt) {
+		l.order.Remove(el)
+		delete(l.entries, key)
+		return CacheEntry{}, false, nil
+	}
+	l.order.MoveToFront(el)
+
+	return item.entry, true, nil
+}
+
+// Contains reports whether an unexpired entry is stored under key, without refreshing its recency.
+func (l *LRUCache) Contains(_ context.Context, key string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	el, ok := l.entries[key]
+	return ok && l.now().Before(el.Value.(*lruItem).entry.ExpiresAt), nil
+}
+
+// Set stores entry under key, evicting the least recently used entry when the cache is full.
+func (l *LRUCache) Set(_ context.Context, key string, entry CacheEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if el, ok := l.entries[key]; ok {
+		el.Value.(*lruItem).entry = entry
+		l.order.MoveToFront(el)
+		return nil
+	}
+
+	l.entries[key] = l.order.PushFront(&lruItem{key: key, entry: entry})
+	for l.order.Len() > l.capacity {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*lruItem).key)
+	}
+
+	return nil
+}
+
+// Len returns the number of entries in the cache, including expired entries that were not evicted yet.
+func (l *LRUCache) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.order.Len()
+}
@@ -0,0 +1,210 @@
+package postgrid
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheKey(t *testing.T) {
+	a := Address{Line1: "251 E 13th St.", City: "New York", ProvinceOrState: "NY", InputID: "1"}
+	b := Address{Line1: " 251 e 13th  st", City: "NEW YORK", ProvinceOrState: "ny", InputID: "2"}
+	c := Address{Line1: "253 E 13th St", City: "New York", ProvinceOrState: "NY"}
+	freeform := Address{String: "251 E 13th St, New York, NY"}
+
+	assert.Equal(t, CacheKey(a), CacheKey(b))
+	assert.NotEqual(t, CacheKey(a), CacheKey(c))
+	assert.NotEqual(t, CacheKey(a), CacheKey(freeform))
+}
+
+func TestLRUCache(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := NewLRUCache(2)
+	cache.now = func() time.Time { return now }
+
+	entry := func(line1 string, ttl time.Duration) CacheEntry {
+		return CacheEntry{Address: VerifiedAddress{Line1: line1}, StoredAt: now, ExpiresAt: now.Add(ttl)}
+	}
+
+	require.NoError(t, cache.Set(ctx, "a", entry("A", time.Hour)))
+	require.NoError(t, cache.Set(ctx, "b", entry("B", time.Minute)))
+
+	got, ok, err := cache.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "A", got.Address.Line1)
+
+	// Contains does not refresh the recency of "b".
+	ok, err = cache.Contains(ctx, "b")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// "b" is now the least recently used entry and is evicted.
+	require.NoError(t, cache.Set(ctx, "c", entry("C", time.Hour)))
+	_, ok, _ = cache.Get(ctx, "b")
+	assert.False(t, ok)
+	assert.Equal(t, 2, cache.Len())
+
+	// Entries are not returned once expired.
+	now = now.Add(2 * time.Hour)
+	ok, _ = cache.Contains(ctx, "a")
+	assert.False(t, ok)
+	assert.Equal(t, 2, cache.Len(), "Contains does not evict expired entries")
+	_, ok, _ = cache.Get(ctx, "a")
+	assert.False(t, ok)
+	assert.Equal(t, 1, cache.Len())
+}
+
+func TestLRUCache_Capacity(t *testing.T) {
+	ctx := context.Background()
+	for _, capacity := range []int{-1, 0, 1} {
+		cache := NewLRUCache(capacity)
+		for _, key := range []string{"a", "b"} {
+			require.NoError(t, cache.Set(ctx, key, CacheEntry{ExpiresAt: time.Now().Add(time.Hour)}))
+		}
+		assert.Equal(t, 1, cache.Len(), "capacity %d", capacity)
+		ok, err := cache.Contains(ctx, "b")
+		require.NoError(t, err)
+		assert.True(t, ok, "capacity %d", capacity)
+	}
+}
+
+func TestClient_VerifyAddress_Cache(t *testing.T) {
+	var calls atomic.Int32
+	status := VerificationStatusVerified
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		writeTestResponse(t, w, VerifiedAddress{Line1: "251 E 13TH ST", Status: status})
+	}))
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name      string
+		status    string
+		ttl       time.Duration
+		negative  time.Duration
+		wantCalls int32
+	}{
+		{
+			name:      "verified results are cached",
+			status:    VerificationStatusVerified,
+			ttl:       time.Hour,
+			negative:  time.Hour,
+			wantCalls: 1,
+		},
+		{
+			name:      "failed results use the negative ttl",
+			status:    VerificationStatusFailed,
+			ttl:       time.Hour,
+			negative:  0,
+			wantCalls: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls.Store(0)
+			status = tt.status
+			client := NewClient("", srv.URL,
+				WithHTTPClient(srv.Client()),
+				WithCache(NewLRUCache(10)),
+				WithCacheTTL(tt.ttl, tt.negative),
+			)
+			req := VerifyAddressRequest{Address: Address{String: "251 e 13th st, New York, NY"}}
+
+			first, err := client.VerifyAddress(context.Background(), req)
+			require.NoError(t, err)
+			assert.Nil(t, first.CacheInfo)
+
+			second, err := client.VerifyAddress(context.Background(), req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCalls, calls.Load())
+			assert.Equal(t, first.Line1, second.Line1)
+			if tt.wantCalls == 1 {
+				require.NotNil(t, second.CacheInfo)
+				assert.Equal(t, second.CacheInfo.StoredAt.Add(tt.ttl), second.CacheInfo.ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestClient_BatchVerifyAddresses_Cache(t *testing.T) {
+	var sent [][]Address
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/addver/verifications" {
+			require.NoError(t, r.ParseForm())
+			writeTestResponse(t, w, VerifiedAddress{Line1: r.PostForm.Get("address[line1]"), Status: VerificationStatusVerified})
+			return
+		}
+
+		var req struct {
+			Addresses []Address `json:"addresses"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		sent = append(sent, req.Addresses)
+
+		var resp BatchVerifyAddressesResponse
+		for _, a := range req.Addresses {
+			resp.Results = append(resp.Results, VerifiedAddressResponse{
+				VerifiedAddress: VerifiedAddress{Line1: a.Line1, Status: VerificationStatusVerified},
+				InputID:         a.InputID,
+			})
+		}
+		writeTestResponse(t, w, resp)
+	}))
+	t.Cleanup(srv.Close)
+
+	client := NewClient("", srv.URL, WithHTTPClient(srv.Client()), WithCache(NewLRUCache(10)))
+	ctx := context.Background()
+
+	_, err := client.VerifyAddress(ctx, VerifyAddressRequest{Address: Address{Line1: "B"}})
+	require.NoError(t, err)
+	sent = nil
+
+	got, err := client.BatchVerifyAddresses(ctx, BatchVerifyAddressesRequest{
+		Addresses: []Address{{Line1: "A", InputID: "1"}, {Line1: "B", InputID: "2"}, {Line1: "C", InputID: "3"}, {Line1: "a", InputID: "4"}},
+	})
+	require.NoError(t, err)
+
+	// Only distinct cache misses are sent.
+	assert.Equal(t, [][]Address{{{Line1: "A", InputID: "1"}, {Line1: "C", InputID: "3"}}}, sent)
+	require.Len(t, got.Results, 4)
+	for i, want := range []string{"A", "B", "C", "A"} {
+		assert.Equal(t, want, got.Results[i].VerifiedAddress.Line1)
+		assert.Equal(t, strconv.Itoa(i+1), got.Results[i].InputID)
+	}
+	assert.Nil(t, got.Results[0].VerifiedAddress.CacheInfo)
+	assert.NotNil(t, got.Results[1].VerifiedAddress.CacheInfo)
+
+	// Everything is cached now, so no request is made.
+	sent = nil
+	got, err = client.BatchVerifyAddresses(ctx, BatchVerifyAddressesRequest{
+		Addresses: []Address{{Line1: "C", InputID: "x"}, {Line1: "A", InputID: "y"}},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, sent)
+	assert.Equal(t, "C", got.Results[0].VerifiedAddress.Line1)
+	assert.Equal(t, "x", got.Results[0].InputID)
+	assert.Equal(t, "A", got.Results[1].VerifiedAddress.Line1)
+	assert.Equal(t, "y", got.Results[1].InputID)
+}
+
+func writeTestResponse(tb testing.TB, w http.ResponseWriter, data any) {
+	tb.Helper()
+
+	buf, err := json.Marshal(Response{
+		Status: ResponseStatusSuccess,
+		Data:   mustMarshalJSON(tb, data),
+	})
+	require.NoError(tb, err)
+	_, err = w.Write(buf)
+	require.NoError(tb, err)
+}
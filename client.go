@@ -5,9 +5,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"mime"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"golang.org/x/time/rate"
 )
@@ -17,124 +23,497 @@ const (
 	httpStatusPostgridTimeout = 524
 )
 
+// Api paths of the endpoints called by the client, relative to the base url. They name the endpoints of
+// WithEndpointRateLimiter and Metrics.
+const (
+	EndpointVerify      = "/addver/verifications"
+	EndpointBatchVerify = "/addver/verifications/batch"
+)
+
+// ErrRateLimitDeadline is returned when the context deadline of a request would pass before the rate
+// limiter allows it to be sent.
+var ErrRateLimitDeadline = errors.New("postgrid: rate limit wait would exceed context deadline")
+
 // Client allows for interacting with the postgrid api.
 type Client struct {
-	httpClient *http.Client
-	apiKey     string
-	baseURL    string
+	doer    Doer
+	apiKey  string
+	baseURL string
+
+	rateLimiter        *rate.Limiter
+	adaptive           *adaptiveLimiter
+	scheduler          *scheduler
+	endpointSchedulers map[string]*scheduler
+
+	cache            Cache
+	cacheTTL         time.Duration
+	cacheNegativeTTL time.Duration
+
+	coalescing bool
+	flights    flightGroup
+
+	retryAttempts int
+	retryBackoff  time.Duration
+
+	progressHooks []ProgressHook
+	requestHooks  []RequestHook
+	metrics       Metrics
 
-	rateLimiter *rate.Limiter
+	logger       *slog.Logger
+	logRedaction LogRedaction
 }
 
 // NewClient constructs a new client with the given api key.
 func NewClient(apiKey string, baseURL string, opts ...Option) *Client {
 	options := options{
-		httpClient:  &http.Client{},
-		rateLimiter: rate.NewLimiter(5, 5),
+		httpClient:       &http.Client{},
+		rateLimiter:      rate.NewLimiter(5, 5),
+		cacheTTL:         DefaultCacheTTL,
+		cacheNegativeTTL: DefaultCacheNegativeTTL,
+		retryAttempts:    1,
+		metrics:          NopMetrics{},
 	}
 
 	for _, opt := range opts {
 		opt.apply(&options)
 	}
+	if options.metrics == nil {
+		options.metrics = NopMetrics{}
+	}
+
+	var adaptive *adaptiveLimiter
+	if options.adaptive {
+		// Adjust a copy so that a limiter shared with other clients keeps its rate.
+		options.rateLimiter = rate.NewLimiter(options.rateLimiter.Limit(), options.rateLimiter.Burst())
+		adaptive = newAdaptiveLimiter(options.rateLimiter, options.adaptiveMin, options.adaptiveRampUp)
+	}
+
+	endpointSchedulers := map[string]*scheduler{}
+	for endpoint, limiter := range options.endpointRateLimiters {
+		endpointSchedulers[endpoint] = newScheduler(limiter)
+	}
 
 	return &Client{
-		apiKey:      apiKey,
-		baseURL:     baseURL,
-		httpClient:  options.httpClient,
-		rateLimiter: options.rateLimiter,
+		apiKey:             apiKey,
+		baseURL:            baseURL,
+		doer:               chain(options.httpClient, options.middlewares),
+		rateLimiter:        options.rateLimiter,
+		adaptive:           adaptive,
+		scheduler:          newScheduler(options.rateLimiter),
+		endpointSchedulers: endpointSchedulers,
+		cache:              options.cache,
+		cacheTTL:           options.cacheTTL,
+		cacheNegativeTTL:   options.cacheNegativeTTL,
+		coalescing:         options.coalescing,
+		retryAttempts:      max(options.retryAttempts, 1),
+		retryBackoff:       options.retryBackoff,
+		progressHooks:      options.progressHooks,
+		requestHooks:       options.requestHooks,
+		metrics:            options.metrics,
+		logger:             options.logger,
+		logRedaction:       options.logRedaction,
 	}
 }
 
 // VerifyAddress calls the Verify Address endpoint from the postgrid api.
 // https://avdocs.postgrid.com/#1061f2ea-00ee-4977-99da-a54872de28c2
+//
+// When the client is configured WithCache, cached results are returned without calling the api and have
+// their CacheInfo set. When the client is configured WithRequestCoalescing, concurrent calls for the same
+// address share a single api call.
 func (c *Client) VerifyAddress(ctx context.Context, req VerifyAddressRequest) (VerifiedAddress, error) {
-	r, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s%s", c.baseURL, "/addver/verifications"), req.Encode())
+	var key string
+	if c.cache != nil {
+		key = CacheKey(req.Address)
+		v, ok := c.cacheGet(ctx, key)
+		c.metrics.ObserveCacheLookup(ok)
+		if ok {
+			c.metrics.ObserveResult(EndpointVerify, v.Status)
+			return v, nil
+		}
+	}
+
+	resp, err := c.verifyAddress(ctx, req)
 	if err != nil {
 		return VerifiedAddress{}, err
 	}
-	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	params := r.URL.Query()
-	params.Set("includeDetails", "true")
-	params.Set("geocode", "true")
-	r.URL.RawQuery = params.Encode()
 
-	var resp VerifiedAddress
-	if err = c.send(r, &resp); err != nil {
-		return VerifiedAddress{}, err
+	if c.cache != nil {
+		c.cacheSet(ctx, key, resp)
 	}
+	c.metrics.ObserveResult(EndpointVerify, resp.Status)
 
 	return resp, nil
 }
 
+func (c *Client) verifyAddress(ctx context.Context, req VerifyAddressRequest) (VerifiedAddress, error) {
+	body, err := io.ReadAll(req.Encode())
+	if err != nil {
+		return VerifiedAddress{}, err
+	}
+	params := verifyParams().Encode()
+
+	resp, err := c.coalesce(ctx, EndpointVerify, params+"\n"+string(body), func(ctx context.Context) (any, error) {
+		r, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+EndpointVerify, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.URL.RawQuery = params
+
+		var resp VerifiedAddress
+		if err = c.send(EndpointVerify, r, &resp); err != nil {
+			return nil, err
+		}
+
+		return resp, nil
+	})
+	if err != nil {
+		return VerifiedAddress{}, err
+	}
+
+	return resp.(VerifiedAddress), nil
+}
+
 // BatchVerifyAddresses calls the Batch Verify Address endpoint from the postgrid api.
 // https://avdocs.postgrid.com/#94520412-5072-4f5a-a2e2-49981b66a347
+//
+// When the client is configured WithCache, only addresses missing from the cache are sent to the api,
+// once per distinct address. Results are returned in the order of req.Addresses.
+//
+// Every call is reported as a bulk operation of a single chunk, unless ctx is set up WithBulkProgress.
 func (c *Client) BatchVerifyAddresses(ctx context.Context, req BatchVerifyAddressesRequest) (BatchVerifyAddressesResponse, error) {
+	return c.verifyChunk(ctx, bulkProgressFromContext(ctx, len(req.Addresses)), req)
+}
+
+// batchVerify verifies a batch, serving cached results when the client is configured WithCache.
+func (c *Client) batchVerify(ctx context.Context, req BatchVerifyAddressesRequest) (BatchVerifyAddressesResponse, error) {
+	if c.cache == nil {
+		return c.batchVerifyAddresses(ctx, req)
+	}
+
+	results := make([]VerifiedAddressResponse, len(req.Addresses))
+	missIndexes := map[string][]int{}
+	var misses []Address
+	var missKeys []string
+	for i, address := range req.Addresses {
+		key := CacheKey(address)
+		v, ok := c.cacheGet(ctx, key)
+		c.metrics.ObserveCacheLookup(ok)
+		if ok {
+			results[i] = VerifiedAddressResponse{VerifiedAddress: v, InputID: address.InputID}
+			continue
+		}
+		if _, ok := missIndexes[key]; !ok {
+			misses = append(misses, address)
+			missKeys = append(missKeys, key)
+		}
+		missIndexes[key] = append(missIndexes[key], i)
+	}
+
+	if len(misses) == 0 {
+		return BatchVerifyAddressesResponse{Results: results}, nil
+	}
+
+	resp, err := c.batchVerifyAddresses(ctx, BatchVerifyAddressesRequest{Addresses: misses})
+	if err != nil {
+		return BatchVerifyAddressesResponse{}, err
+	}
+	if len(resp.Results) != len(misses) {
+		return BatchVerifyAddressesResponse{}, fmt.Errorf("postgrid error: expected %d batch results, received %d", len(misses), len(resp.Results))
+	}
+
+	for i, result := range resp.Results {
+		c.cacheSet(ctx, missKeys[i], result.VerifiedAddress)
+		// Addresses sharing a cache key may differ in InputID.
+		for _, j := range missIndexes[missKeys[i]] {
+			results[j] = result
+			results[j].InputID = req.Addresses[j].InputID
+		}
+	}
+
+	return BatchVerifyAddressesResponse{Results: results}, nil
+}
+
+func (c *Client) batchVerifyAddresses(ctx context.Context, req BatchVerifyAddressesRequest) (BatchVerifyAddressesResponse, error) {
 	reqJSON, err := json.Marshal(req)
 	if err != nil {
 		return BatchVerifyAddressesResponse{}, err
 	}
+	params := verifyParams().Encode()
+
+	resp, err := c.coalesce(ctx, EndpointBatchVerify, params+"\n"+string(reqJSON), func(ctx context.Context) (any, error) {
+		r, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+EndpointBatchVerify, bytes.NewBuffer(reqJSON))
+		if err != nil {
+			return nil, err
+		}
+		r.Header.Set("Content-Type", "application/json")
+		r.URL.RawQuery = params
+
+		var resp BatchVerifyAddressesResponse
+		if err = c.send(EndpointBatchVerify, r, &resp); err != nil {
+			return nil, err
+		}
 
-	r, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s%s", c.baseURL, "/addver/verifications/batch"), bytes.NewBuffer(reqJSON))
+		return resp, nil
+	})
 	if err != nil {
 		return BatchVerifyAddressesResponse{}, err
 	}
-	r.Header.Set("Content-Type", "application/json")
-	params := r.URL.Query()
+
+	return resp.(BatchVerifyAddressesResponse), nil
+}
+
+// verifyParams returns the query parameters sent with every verification request.
+func verifyParams() url.Values {
+	params := url.Values{}
 	params.Set("includeDetails", "true")
 	params.Set("geocode", "true")
-	r.URL.RawQuery = params.Encode()
 
-	var resp BatchVerifyAddressesResponse
-	if err = c.send(r, &resp); err != nil {
-		return BatchVerifyAddressesResponse{}, err
+	return params
+}
+
+// send initiates the http request to the endpoint, one of the Endpoint constants, and unmarshals the
+// response into the object passed in, retrying when the client is configured WithRetry. The endpoint is
+// passed rather than read from the request url, whose path also holds the path of the base url.
+func (c *Client) send(endpoint string, req *http.Request, v any) error {
+	for attempt := 1; ; attempt++ {
+		ex := exchange{endpoint: endpoint, attempt: attempt}
+		retry, err := c.sendOnce(req, v, &ex)
+		c.logExchange(req, ex, err)
+		retry = err != nil && retry && attempt < c.retryAttempts
+		c.reportRequest(req, ex, retry, err)
+		c.observeExchange(ex)
+		if !retry {
+			return err
+		}
+
+		wait := retryWait(c.retryBackoff, attempt)
+		c.emit(ProgressEvent{Type: ProgressRetryScheduled, Endpoint: endpoint, Attempt: attempt + 1, Wait: wait, Err: err})
+		if err := sleep(req.Context(), wait); err != nil {
+			return err
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
 	}
+}
 
-	return resp, nil
+// maxRetryBackoff caps the wait before a retry, unless the configured backoff is longer.
+const maxRetryBackoff = time.Minute
+
+// retryWait returns the wait before the retry following attempt: backoff doubled after every attempt, up to
+// maxRetryBackoff.
+func retryWait(backoff time.Duration, attempt int) time.Duration {
+	wait := backoff
+	for i := 1; i < attempt && wait < maxRetryBackoff; i++ {
+		wait *= 2
+	}
+
+	return min(wait, max(backoff, maxRetryBackoff))
 }
 
-// send initiates the http request and unmarshals the response into the object passed in.
-func (c *Client) send(req *http.Request, v any) error {
+// retryableStatus holds the http status codes worth retrying.
+var retryableStatus = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+	httpStatusPostgridTimeout:     true,
+}
+
+// exchange describes a single attempt of an http request, for logging and request hooks.
+type exchange struct {
+	endpoint string
+	attempt  int
+	sent     bool
+	wait     time.Duration
+	latency  time.Duration
+	status   int
+	message  string
+}
+
+// sendOnce makes a single attempt of the http request, reporting whether a failure may be retried.
+func (c *Client) sendOnce(req *http.Request, v any, ex *exchange) (bool, error) {
 	// Respect rate limit
-	if err := c.rateLimiter.Wait(req.Context()); err != nil {
-		return err
+	wait, err := c.waitRateLimit(req, ex.endpoint)
+	ex.wait = wait
+	if err != nil {
+		return false, err
 	}
 
 	// Set default headers
 	req.Header.Set("x-api-key", c.apiKey)
 
-	resp, err := c.httpClient.Do(req)
+	ex.sent = true
+	start := time.Now()
+	resp, err := c.doer.Do(req)
 	if err != nil {
-		return err
+		ex.latency = time.Since(start)
+		return req.Context().Err() == nil, err
+	}
+	defer resp.Body.Close()
+	ex.status = resp.StatusCode
+
+	if c.adaptive != nil {
+		if reduced, pause := c.adaptive.observe(resp); reduced {
+			c.emit(ProgressEvent{Type: ProgressRateLimitReduced, Endpoint: ex.endpoint, Limit: c.rateLimiter.Limit(), Wait: pause})
+		}
 	}
 
+	retry := retryableStatus[resp.StatusCode]
 	if resp.StatusCode == httpStatusPostgridTimeout {
-		return fmt.Errorf("postgrid error: received postgrid timeout status %d", httpStatusPostgridTimeout)
+		return retry, fmt.Errorf("postgrid error: received postgrid timeout status %d", httpStatusPostgridTimeout)
 	}
 
-	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	ex.latency = time.Since(start)
+	if err != nil {
+		return req.Context().Err() == nil, fmt.Errorf("error reading response body from postgrid: %w, response status code %d", err, resp.StatusCode)
+	}
 
 	var response Response
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+	if err := json.Unmarshal(body, &response); err != nil {
+		return retry, newDecodeError(resp.StatusCode, resp.Header, body, err)
+	}
+
+	ex.message = response.Message
+	if response.Status == ResponseStatusError {
+		return retry, fmt.Errorf("postgrid error: %s", response.Message)
+	}
+
+	if v == nil {
+		return false, nil
+	}
+
+	return false, json.Unmarshal(response.Data, v)
+}
+
+// waitRateLimit waits for the rate limiters of the request, emitting a ProgressRateLimitWait event when the
+// request was delayed. It returns the time the request was delayed.
+func (c *Client) waitRateLimit(req *http.Request, endpoint string) (time.Duration, error) {
+	ctx := req.Context()
+	start := time.Now()
+	wait, err := c.schedule(ctx, endpoint)
+	if !wait {
+		return 0, err
+	}
+
+	waited := time.Since(start)
+	if err == nil {
+		c.emit(ProgressEvent{Type: ProgressRateLimitWait, Endpoint: endpoint, Wait: waited})
+	}
 
-		// try parsing the response as string for more details
-		body, err1 := io.ReadAll(resp.Body)
-		if err1 != nil {
-			return fmt.Errorf("error decoding response envelope from postgrid as json or string. json error: %w, string error: %w, response status code %d",
-				err, err1, resp.StatusCode)
+	return waited, err
+}
+
+// schedule waits for the pause requested by postgrid when the client is adaptive, then for the limiter of
+// the endpoint and the limiter of the client by priority. It reports whether the request had to wait.
+func (c *Client) schedule(ctx context.Context, endpoint string) (bool, error) {
+	var waited bool
+	if c.adaptive != nil {
+		if pause := c.adaptive.pause(); pause > 0 {
+			if deadline, ok := requestDeadline(ctx); ok && time.Until(deadline) < pause {
+				return false, fmt.Errorf("%w: pause of %s requested by postgrid", ErrRateLimitDeadline, pause)
+			}
+			if err := sleep(ctx, pause); err != nil {
+				return true, err
+			}
+			waited = true
 		}
+	}
 
-		respString := string(body)
-		return fmt.Errorf("error decoding response envelope from postgrid as json: %w, received string response: %s, response status code %d",
-			err, respString, resp.StatusCode)
+	p := priority(ctx, endpoint)
+	var endpointToken token
+	if s, ok := c.endpointSchedulers[endpoint]; ok {
+		t, w, err := s.wait(ctx, p)
+		waited = waited || w
+		if err != nil {
+			return waited, err
+		}
+		endpointToken = t
 	}
 
-	if response.Status == ResponseStatusError {
-		return fmt.Errorf("postgrid error: %s", response.Message)
+	_, w, err := c.scheduler.wait(ctx, p)
+	if err != nil {
+		// The request is not sent, so it does not use up its token of the endpoint.
+		endpointToken.release()
 	}
+	return waited || w, err
+}
 
-	if v == nil {
-		return nil
+// maxErrorBody is the number of bytes of a response body kept in a DecodeError.
+const maxErrorBody = 256
+
+// DecodeError is returned when the response envelope of postgrid cannot be decoded.
+type DecodeError struct {
+	StatusCode int
+	// Body holds the first bytes of a response that is clearly not JSON, such as the html page of a
+	// gateway, for more details. It is empty for a JSON response, even a truncated one, which may hold
+	// verified addresses.
+	Body string
+	Err  error
+}
+
+func newDecodeError(status int, header http.Header, body []byte, err error) *DecodeError {
+	e := &DecodeError{StatusCode: status, Err: err}
+	if !isJSON(header.Get("Content-Type"), body) {
+		e.Body = string(body[:min(len(body), maxErrorBody)])
+	}
+
+	return e
+}
+
+// isJSON reports whether a response body is, or was meant to be, JSON by its content type or its first
+// character, so that a truncated JSON response is recognised too.
+func isJSON(contentType string, body []byte) bool {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+			return true
+		}
+	}
+
+	body = bytes.TrimLeft(body, " \t\r\n")
+	return len(body) > 0 && (body[0] == '{' || body[0] == '[') || json.Valid(body)
+}
+
+func (e *DecodeError) Error() string {
+	if isJSON("", []byte(e.Body)) {
+		return e.message("")
+	}
+
+	return e.message(e.Body)
+}
+
+// message returns the text of e with body as the response body.
+func (e *DecodeError) message(body string) string {
+	if body == "" {
+		return fmt.Sprintf("error decoding response envelope from postgrid as json: %v, response status code %d", e.Err, e.StatusCode)
 	}
 
-	return json.Unmarshal(response.Data, v)
+	return fmt.Sprintf("error decoding response envelope from postgrid as json: %v, received string response: %s, response status code %d",
+		e.Err, body, e.StatusCode)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -280,6 +281,58 @@ func TestClient_VerifyAddress(t *testing.T) {
 	}
 }
 
+func TestClient_DecodeError(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantBody    string
+	}{
+		{
+			name:     "JSON body is omitted",
+			body:     `[{"line1":"251 E 13TH ST FRNT A"}]`,
+			wantBody: "",
+		},
+		{
+			name:     "truncated JSON body is omitted",
+			body:     ` {"status":"success","data":{"line1":"251 E 13TH ST`,
+			wantBody: "",
+		},
+		{
+			name:        "body with a JSON content type is omitted",
+			contentType: "application/json; charset=utf-8",
+			body:        `"251 E 13TH ST`,
+			wantBody:    "",
+		},
+		{
+			name:     "non-JSON body is truncated",
+			body:     "<html>" + strings.Repeat("a", 2*maxErrorBody) + "</html>",
+			wantBody: "<html>" + strings.Repeat("a", maxErrorBody-len("<html>")),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				w.WriteHeader(http.StatusOK)
+				_, _ = io.WriteString(w, tt.body)
+			}))
+			t.Cleanup(srv.Close)
+			client := NewClient("", srv.URL, WithHTTPClient(srv.Client()))
+
+			_, err := client.VerifyAddress(context.Background(), VerifyAddressRequest{Address: Address{Line1: "A"}})
+			var decodeErr *DecodeError
+			require.ErrorAs(t, err, &decodeErr)
+			assert.Equal(t, http.StatusOK, decodeErr.StatusCode)
+			assert.Equal(t, tt.wantBody, decodeErr.Body)
+			assert.NotContains(t, err.Error(), "13TH")
+			assert.NotContains(t, err.Error(), "</html>")
+		})
+	}
+}
+
 type expectations struct {
 	Path    string
 	Method  string
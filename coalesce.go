@@ -0,0 +1,223 @@
+package postgrid
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// flightGroup coalesces concurrent calls with the same key into a single call whose result is shared by
+// every caller.
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+type flight struct {
+	done    chan struct{}
+	cancel  context.CancelCauseFunc
+	waiters []*flightWaiter
+
+	// The priority and deadline of the call, derived from the waiting callers.
+	priority      Priority
+	reprioritized chan struct{} // closed when priority changes
+	deadline      time.Time     // zero when a waiting caller has no deadline
+	timer         *time.Timer   // cancels the call at deadline
+
+	val any
+	err error
+}
+
+// flightWaiter is a caller waiting for the result of a flight.
+type flightWaiter struct {
+	priority Priority
+	deadline time.Time // zero for a caller without a deadline
+}
+
+// do runs fn once for all concurrent callers with the same key, each scheduled with priority p. fn runs
+// with a context that keeps the values of the first caller's ctx, such as its trace, but is only cancelled
+// once every waiting caller has given up or their latest deadline has passed, so one caller cancelling does
+// not fail the others. The client schedules fn with the highest priority and the latest deadline of the
+// waiting callers, see priority and requestDeadline, as it would the most urgent caller.
+func (g *flightGroup) do(ctx context.Context, key string, p Priority, fn func(context.Context) (any, error)) (any, error) {
+	deadline, _ := ctx.Deadline()
+	w := &flightWaiter{priority: p, deadline: deadline}
+
+	g.mu.Lock()
+	if g.flights == nil {
+		g.flights = map[string]*flight{}
+	}
+	f, ok := g.flights[key]
+	if !ok {
+		callCtx, cancel := context.WithCancelCause(context.WithoutCancel(ctx))
+		f = &flight{done: make(chan struct{}), cancel: cancel, priority: p, reprioritized: make(chan struct{})}
+		g.flights[key] = f
+		go g.run(flightContext{Context: callCtx, group: g, flight: f}, key, f, fn)
+	}
+	f.waiters = append(f.waiters, w)
+	f.update()
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.val, f.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		for i := range f.waiters {
+			if f.waiters[i] == w {
+				f.waiters = append(f.waiters[:i:i], f.waiters[i+1:]...)
+				break
+			}
+		}
+		if len(f.waiters) == 0 {
+			// The last caller gives up at its deadline or when it is canceled, so the call does too.
+			f.stop(ctx.Err())
+			g.forget(key, f)
+		} else {
+			f.update()
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+func (g *flightGroup) run(ctx context.Context, key string, f *flight, fn func(context.Context) (any, error)) {
+	f.val, f.err = fn(ctx)
+
+	g.mu.Lock()
+	f.stop(context.Canceled)
+	g.forget(key, f)
+	g.mu.Unlock()
+
+	close(f.done)
+}
+
+// update derives the priority and deadline of f from its waiting callers. g.mu must be held.
+func (f *flight) update() {
+	p := PriorityBackground
+	var latest time.Time
+	bounded := true
+	for _, w := range f.waiters {
+		p = min(p, w.priority)
+		bounded = bounded && !w.deadline.IsZero()
+		if w.deadline.After(latest) {
+			latest = w.deadline
+		}
+	}
+	if !bounded {
+		latest = time.Time{}
+	}
+
+	if p != f.priority {
+		f.priority = p
+		close(f.reprioritized)
+		f.reprioritized = make(chan struct{})
+	}
+
+	if latest.Equal(f.deadline) {
+		return
+	}
+	f.deadline = latest
+	if f.timer != nil {
+		f.timer.Stop()
+		f.timer = nil
+	}
+	if !latest.IsZero() {
+		f.timer = time.AfterFunc(time.Until(latest), func() { f.cancel(context.DeadlineExceeded) })
+	}
+}
+
+// stop cancels the context of f with cause. g.mu must be held.
+func (f *flight) stop(cause error) {
+	if f.timer != nil {
+		f.timer.Stop()
+	}
+	f.cancel(cause)
+}
+
+// flightContext is the context of a flight. Its values and deadline are those of the first caller's
+// context, without its deadline, as the priority and deadline of the flight change while callers join and
+// leave; the client schedules the flight by them through flightFromContext.
+type flightContext struct {
+	context.Context
+	group  *flightGroup
+	flight *flight
+}
+
+type flightKey struct{}
+
+// Err returns context.DeadlineExceeded once the latest deadline of the waiting callers has passed, or the
+// error of the last waiting caller once every waiting caller has given up.
+func (c flightContext) Err() error {
+	err := c.Context.Err()
+	if err != nil && context.Cause(c.Context) == context.DeadlineExceeded {
+		return context.DeadlineExceeded
+	}
+
+	return err
+}
+
+func (c flightContext) Value(key any) any {
+	if key == (flightKey{}) {
+		return c
+	}
+
+	return c.Context.Value(key)
+}
+
+// priority returns the highest priority of the waiting callers.
+func (c flightContext) priority() Priority {
+	c.group.mu.Lock()
+	defer c.group.mu.Unlock()
+
+	return c.flight.priority
+}
+
+// deadline returns the latest deadline of the waiting callers, or no deadline when one of them has none.
+func (c flightContext) deadline() (time.Time, bool) {
+	c.group.mu.Lock()
+	defer c.group.mu.Unlock()
+
+	return c.flight.deadline, !c.flight.deadline.IsZero()
+}
+
+// reprioritized returns a channel closed when the priority of the flight changes.
+func (c flightContext) reprioritized() <-chan struct{} {
+	c.group.mu.Lock()
+	defer c.group.mu.Unlock()
+
+	return c.flight.reprioritized
+}
+
+// flightFromContext returns the context of the flight running with ctx, if any.
+func flightFromContext(ctx context.Context) (flightContext, bool) {
+	c, ok := ctx.Value(flightKey{}).(flightContext)
+	return c, ok
+}
+
+// requestDeadline returns the deadline by which a request must be sent: the deadline of ctx, or the latest
+// deadline of the callers waiting for a coalesced request.
+func requestDeadline(ctx context.Context) (time.Time, bool) {
+	if c, ok := flightFromContext(ctx); ok {
+		return c.deadline()
+	}
+
+	return ctx.Deadline()
+}
+
+// forget removes f so that later callers start a new flight. g.mu must be held.
+func (g *flightGroup) forget(key string, f *flight) {
+	if g.flights[key] == f {
+		delete(g.flights, key)
+	}
+}
+
+// coalesce runs fn through the client's flight group when the client is configured
+// WithRequestCoalescing.
+func (c *Client) coalesce(ctx context.Context, endpoint, key string, fn func(context.Context) (any, error)) (any, error) {
+	if !c.coalescing {
+		return fn(ctx)
+	}
+
+	return c.flights.do(ctx, endpoint+"?"+key, priority(ctx, endpoint), fn)
+}
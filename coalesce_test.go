@@ -0,0 +1,255 @@
+package postgrid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestClient_VerifyAddress_Coalescing(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []Option
+		wantCalls  int32
+		cancelHalf bool
+	}{
+		{
+			name:      "concurrent duplicates share one call",
+			opts:      []Option{WithRequestCoalescing(true)},
+			wantCalls: 1,
+		},
+		{
+			name:       "cancelled callers do not fail the others",
+			opts:       []Option{WithRequestCoalescing(true)},
+			wantCalls:  1,
+			cancelHalf: true,
+		},
+		{
+			name:      "disabled by default",
+			wantCalls: 10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			release := make(chan struct{})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				<-release
+				writeTestResponse(t, w, VerifiedAddress{Line1: "251 E 13TH ST", Status: VerificationStatusVerified})
+			}))
+			t.Cleanup(srv.Close)
+
+			opts := append([]Option{WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Inf, 0))}, tt.opts...)
+			client := NewClient("", srv.URL, opts...)
+			req := VerifyAddressRequest{Address: Address{String: "251 e 13th st, New York, NY"}}
+
+			var wg sync.WaitGroup
+			errs := make([]error, 10)
+			results := make([]VerifiedAddress, 10)
+			for i := range errs {
+				ctx, cancel := context.WithCancel(context.Background())
+				if tt.cancelHalf && i%2 == 0 {
+					cancel()
+				} else {
+					defer cancel()
+				}
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					results[i], errs[i] = client.VerifyAddress(ctx, req)
+				}(i)
+			}
+
+			// The server responds once every caller still waiting has joined the in-flight call.
+			waiting := len(errs)
+			if tt.cancelHalf {
+				waiting /= 2
+			}
+			require.Eventually(t, func() bool {
+				if !client.coalescing {
+					return calls.Load() == int32(waiting)
+				}
+				client.flights.mu.Lock()
+				defer client.flights.mu.Unlock()
+				for _, f := range client.flights.flights {
+					return len(f.waiters) == waiting && calls.Load() == 1
+				}
+				return false
+			}, time.Second, time.Millisecond)
+			close(release)
+			wg.Wait()
+
+			assert.Equal(t, tt.wantCalls, calls.Load())
+			for i := range errs {
+				if tt.cancelHalf && i%2 == 0 {
+					assert.ErrorIs(t, errs[i], context.Canceled)
+					continue
+				}
+				require.NoError(t, errs[i])
+				assert.Equal(t, "251 E 13TH ST", results[i].Line1)
+			}
+		})
+	}
+}
+
+func TestFlightGroup_AllCallersCancelled(t *testing.T) {
+	var g flightGroup
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	_, err := g.do(ctx, "key", PriorityInteractive, func(ctx context.Context) (any, error) {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+		return nil, ctx.Err()
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("in-flight call was not cancelled after its only caller gave up")
+	}
+
+	// A new caller starts a fresh flight.
+	got, err := g.do(context.Background(), "key", PriorityInteractive, func(context.Context) (any, error) {
+		return "fresh", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "fresh", got)
+}
+
+func TestFlightGroup_Deadline(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name         string
+		deadlines    []time.Time
+		wantDeadline time.Time
+	}{
+		{name: "single caller", deadlines: []time.Time{now.Add(time.Hour)}, wantDeadline: now.Add(time.Hour)},
+		{name: "latest caller", deadlines: []time.Time{now.Add(time.Hour), now.Add(2 * time.Hour)}, wantDeadline: now.Add(2 * time.Hour)},
+		{name: "caller without deadline", deadlines: []time.Time{now.Add(time.Hour), {}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var g flightGroup
+			release := make(chan struct{})
+			fn := func(ctx context.Context) (any, error) {
+				<-release
+				// The deadline of the flight changes with its callers, so its context does not carry it.
+				_, ok := ctx.Deadline()
+				assert.False(t, ok)
+				deadline, _ := requestDeadline(ctx)
+				return deadline, nil
+			}
+
+			results := make(chan any, len(tt.deadlines))
+			for _, d := range tt.deadlines {
+				ctx := context.Background()
+				if !d.IsZero() {
+					var cancel context.CancelFunc
+					ctx, cancel = context.WithDeadline(ctx, d)
+					defer cancel()
+				}
+				go func() {
+					v, err := g.do(ctx, "key", PriorityInteractive, fn)
+					assert.NoError(t, err)
+					results <- v
+				}()
+			}
+			require.Eventually(t, func() bool {
+				g.mu.Lock()
+				defer g.mu.Unlock()
+				f, ok := g.flights["key"]
+				return ok && len(f.waiters) == len(tt.deadlines)
+			}, time.Second, time.Millisecond)
+			close(release)
+
+			for range tt.deadlines {
+				assert.True(t, tt.wantDeadline.Equal((<-results).(time.Time)))
+			}
+		})
+	}
+}
+
+func TestFlightGroup_DeadlineExceeded(t *testing.T) {
+	var g flightGroup
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	errs := make(chan error, 1)
+	_, err := g.do(ctx, "key", PriorityInteractive, func(ctx context.Context) (any, error) {
+		<-ctx.Done()
+		errs <- ctx.Err()
+		return nil, ctx.Err()
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	select {
+	case err := <-errs:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(time.Second):
+		t.Fatal("in-flight call was not cancelled at its deadline")
+	}
+}
+
+func TestClient_Coalescing_Priority(t *testing.T) {
+	var mu sync.Mutex
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		mu.Lock()
+		sent = append(sent, r.Form.Get("address[line1]"))
+		mu.Unlock()
+		writeTestResponse(t, w, VerifiedAddress{Status: VerificationStatusVerified})
+	}))
+	t.Cleanup(srv.Close)
+
+	limiter := rate.NewLimiter(rate.Every(300*time.Millisecond), 1)
+	require.True(t, limiter.Allow(), "the token is taken so that requests queue")
+	client := NewClient("", srv.URL, WithHTTPClient(srv.Client()), WithRateLimiter(limiter), WithRequestCoalescing(true))
+
+	done := make(chan error, 3)
+	verify := func(ctx context.Context, line1 string) {
+		_, err := client.VerifyAddress(ctx, VerifyAddressRequest{Address: Address{Line1: line1}})
+		done <- err
+	}
+
+	// Two background requests queue, then an interactive caller joins the second one.
+	background := WithPriority(context.Background(), PriorityBackground)
+	go verify(background, "A")
+	require.Eventually(t, func() bool { return queued(client.scheduler, PriorityBackground) == 1 }, time.Second, time.Millisecond)
+	go verify(background, "B")
+	require.Eventually(t, func() bool { return queued(client.scheduler, PriorityBackground) == 2 }, time.Second, time.Millisecond)
+	go verify(context.Background(), "B")
+	require.Eventually(t, func() bool { return queued(client.scheduler, PriorityInteractive) == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, 1, queued(client.scheduler, PriorityBackground))
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, <-done)
+	}
+	assert.Equal(t, []string{"B", "A"}, sent, "the shared request is sent first at the priority of its interactive caller")
+}
+
+// queued returns the number of requests waiting for a token of s at priority p.
+func queued(s *scheduler, p Priority) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.queues[p])
+}
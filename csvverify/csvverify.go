@@ -0,0 +1,419 @@
+// Package csvverify verifies the addresses in a CSV file with postgrid and writes them back out with the
+// verified fields appended to every row.
+package csvverify
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	postgrid "github.com/bloomcredit/bloomcredit-postgrid-sdk"
+)
+
+// DefaultChunkSize is the number of rows verified per batch.
+const DefaultChunkSize = 500
+
+// DefaultColumnPrefix prefixes the names of the appended columns.
+const DefaultColumnPrefix = "verified_"
+
+var (
+	// ErrNoMapping is returned when the Mapping does not name any address column.
+	ErrNoMapping = errors.New("csvverify: mapping has no address columns")
+	// ErrMissingColumn is returned when a column named by the Mapping is not in the CSV header.
+	ErrMissingColumn = errors.New("csvverify: column not found in header")
+	// ErrUnknownDetail is returned when WithDetails names a field postgrid does not return.
+	ErrUnknownDetail = errors.New("csvverify: unknown details field")
+)
+
+// Mapping names the CSV columns holding the address fields. Set String to read a freeform address from a
+// single column, or any of the structured columns otherwise. Empty names are not read.
+type Mapping struct {
+	String          string
+	Line1           string
+	Line2           string
+	City            string
+	ProvinceOrState string
+	PostalOrZip     string
+	Country         string
+}
+
+// Summary counts the rows processed by Verify.
+type Summary struct {
+	Rows int
+	// Skipped is the number of rows without an address, which are written without verified fields.
+	Skipped int
+	// StatusCounts counts the verified rows by postgrid.VerifiedAddress.Status.
+	StatusCounts map[string]int
+}
+
+type options struct {
+	chunkSize int
+	prefix    string
+	details   []string
+	comma     rune
+}
+
+// Option represents optional arguments for Verify.
+type Option interface {
+	apply(*options)
+}
+
+type chunkSizeOption struct {
+	size int
+}
+
+func (c chunkSizeOption) apply(opts *options) {
+	opts.chunkSize = c.size
+}
+
+// WithChunkSize configures the number of rows verified per batch. It is capped at postgrid.MaxBatchSize.
+func WithChunkSize(size int) Option {
+	return chunkSizeOption{size: size}
+}
+
+type columnPrefixOption struct {
+	prefix string
+}
+
+func (c columnPrefixOption) apply(opts *options) {
+	opts.prefix = c.prefix
+}
+
+// WithColumnPrefix configures the prefix of the appended column names. It defaults to DefaultColumnPrefix.
+func WithColumnPrefix(prefix string) Option {
+	return columnPrefixOption{prefix: prefix}
+}
+
+type detailsOption struct {
+	fields []string
+}
+
+func (d detailsOption) apply(opts *options) {
+	opts.details = d.fields
+}
+
+// WithDetails appends a column for each of the given postgrid.VerifiedAddressDetails fields, named by their
+// JSON names, e.g. "county" or "usMailingsCarrierRoute".
+func WithDetails(fields ...string) Option {
+	return detailsOption{fields: fields}
+}
+
+type commaOption struct {
+	comma rune
+}
+
+func (c commaOption) apply(opts *options) {
+	opts.comma = c.comma
+}
+
+// WithComma configures the field delimiter of both the input and output CSV. It defaults to ','.
+func WithComma(comma rune) Option {
+	return commaOption{comma: comma}
+}
+
+// Indexes of the verified columns in verifiedColumns.
+const (
+	colLine1 = iota
+	colLine2
+	colCity
+	colProvinceOrState
+	colPostalOrZip
+	colZipPlus4
+	colCountry
+	colFirmName
+	colStatus
+	colErrors
+	colLatitude
+	colLongitude
+	colAccuracyType
+	numVerifiedColumns
+)
+
+// verifiedColumns are the columns appended to every row, before the details columns.
+var verifiedColumns = [numVerifiedColumns]string{
+	colLine1:           "line1",
+	colLine2:           "line2",
+	colCity:            "city",
+	colProvinceOrState: "province_or_state",
+	colPostalOrZip:     "postal_or_zip",
+	colZipPlus4:        "zip_plus4",
+	colCountry:         "country",
+	colFirmName:        "firm_name",
+	colStatus:          "status",
+	colErrors:          "errors",
+	colLatitude:        "latitude",
+	colLongitude:       "longitude",
+	colAccuracyType:    "accuracy_type",
+}
+
+// detailFields maps the JSON names of the postgrid.VerifiedAddressDetails fields to their index.
+var detailFields = func() map[string]int {
+	fields := map[string]int{}
+	t := reflect.TypeOf(postgrid.VerifiedAddressDetails{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		fields[name] = i
+	}
+	return fields
+}()
+
+// Verify reads the CSV from r, verifies its addresses in chunks through the Batch Verify Addresses endpoint
+// and writes every row to w in the original order, with its original columns followed by the verified
+// columns. The first row must be a header. The chunks are reported to the progress hooks of a
+// *postgrid.Client as a single bulk operation, see postgrid.WithBulkProgress.
+func Verify(ctx context.Context, client postgrid.AddressVerifier, r io.Reader, w io.Writer, m Mapping, opts ...Option) (_ Summary, err error) {
+	options := options{
+		chunkSize: DefaultChunkSize,
+		prefix:    DefaultColumnPrefix,
+		comma:     ',',
+	}
+
+	for _, opt := range opts {
+		opt.apply(&options)
+	}
+	chunkSize := min(max(options.chunkSize, 1), postgrid.MaxBatchSize)
+
+	details := make([]int, len(options.details))
+	for i, name := range options.details {
+		idx, ok := detailFields[name]
+		if !ok {
+			return Summary{}, fmt.Errorf("%w: %s", ErrUnknownDetail, name)
+		}
+		details[i] = idx
+	}
+
+	cr := csv.NewReader(r)
+	cr.Comma = options.comma
+	cw := csv.NewWriter(w)
+	cw.Comma = options.comma
+	// Rows written before a failure are flushed too.
+	defer func() {
+		cw.Flush()
+		if flushErr := cw.Error(); flushErr != nil && err == nil {
+			err = fmt.Errorf("csvverify: %w", flushErr)
+		}
+	}()
+
+	header, err := cr.Read()
+	if err != nil {
+		return Summary{}, fmt.Errorf("csvverify: reading header: %w", err)
+	}
+	cols, err := m.columns(header)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	out := append([]string(nil), header...)
+	for _, col := range verifiedColumns {
+		out = append(out, options.prefix+col)
+	}
+	for _, name := range options.details {
+		out = append(out, options.prefix+name)
+	}
+	if err := cw.Write(out); err != nil {
+		return Summary{}, fmt.Errorf("csvverify: %w", err)
+	}
+
+	ctx = postgrid.WithBulkProgress(ctx, 0)
+	summary := Summary{StatusCounts: map[string]int{}}
+	for {
+		rows, err := readRows(cr, chunkSize)
+		if err != nil {
+			return summary, err
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		if err := verifyRows(ctx, client, cw, rows, cols, details, &summary); err != nil {
+			return summary, err
+		}
+	}
+
+	return summary, nil
+}
+
+// columns holds the header index of each mapped field, or -1.
+type columns struct {
+	str, line1, line2, city, state, postal, country int
+}
+
+func (m Mapping) columns(header []string) (columns, error) {
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		if _, ok := index[name]; !ok {
+			index[name] = i
+		}
+	}
+
+	var mapped bool
+	lookup := func(name string) (int, error) {
+		if name == "" {
+			return -1, nil
+		}
+		i, ok := index[name]
+		if !ok {
+			return -1, fmt.Errorf("%w: %s", ErrMissingColumn, name)
+		}
+		mapped = true
+		return i, nil
+	}
+
+	var c columns
+	var errs []error
+	for _, f := range []struct {
+		dst  *int
+		name string
+	}{
+		{&c.str, m.String},
+		{&c.line1, m.Line1},
+		{&c.line2, m.Line2},
+		{&c.city, m.City},
+		{&c.state, m.ProvinceOrState},
+		{&c.postal, m.PostalOrZip},
+		{&c.country, m.Country},
+	} {
+		var err error
+		*f.dst, err = lookup(f.name)
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return columns{}, err
+	}
+	if !mapped {
+		return columns{}, ErrNoMapping
+	}
+
+	return c, nil
+}
+
+// address reads the mapped fields of a row, reporting false when they are all blank.
+func (c columns) address(row []string) (postgrid.Address, bool) {
+	get := func(i int) string {
+		if i < 0 || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	if s := get(c.str); s != "" {
+		return postgrid.Address{String: s}, true
+	}
+	a := postgrid.Address{
+		Line1:           get(c.line1),
+		Line2:           get(c.line2),
+		City:            get(c.city),
+		ProvinceOrState: get(c.state),
+		PostalOrZip:     get(c.postal),
+		Country:         get(c.country),
+	}
+
+	return a, a != postgrid.Address{}
+}
+
+func readRows(cr *csv.Reader, n int) ([][]string, error) {
+	var rows [][]string
+	for len(rows) < n {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("csvverify: %w", err)
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// verifyRows verifies the addresses of a chunk of rows and writes the rows with their verified columns.
+func verifyRows(ctx context.Context, client postgrid.AddressVerifier, cw *csv.Writer, rows [][]string, cols columns, details []int, summary *Summary) error {
+	var req postgrid.BatchVerifyAddressesRequest
+	sent := make([]int, len(rows))
+	for i, row := range rows {
+		sent[i] = -1
+		if a, ok := cols.address(row); ok {
+			sent[i] = len(req.Addresses)
+			req.Addresses = append(req.Addresses, a)
+		}
+	}
+
+	var results []postgrid.VerifiedAddressResponse
+	if len(req.Addresses) > 0 {
+		resp, err := client.BatchVerifyAddresses(ctx, req)
+		if err != nil {
+			return fmt.Errorf("csvverify: verifying rows %d-%d: %w", summary.Rows+1, summary.Rows+len(rows), err)
+		}
+		if len(resp.Results) != len(req.Addresses) {
+			return fmt.Errorf("csvverify: expected %d batch results, received %d", len(req.Addresses), len(resp.Results))
+		}
+		results = resp.Results
+	}
+
+	for i, row := range rows {
+		summary.Rows++
+		extra := make([]string, len(verifiedColumns)+len(details))
+		if sent[i] < 0 {
+			summary.Skipped++
+		} else {
+			v := results[sent[i]].VerifiedAddress
+			summary.StatusCounts[v.Status]++
+			fill(extra, v, details)
+		}
+
+		if err := cw.Write(append(row, extra...)); err != nil {
+			return fmt.Errorf("csvverify: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// fill sets the verified columns of a row, in the order of verifiedColumns followed by details.
+func fill(dst []string, v postgrid.VerifiedAddress, details []int) {
+	dst[colLine1] = v.Line1
+	dst[colLine2] = v.Line2
+	dst[colCity] = v.City
+	dst[colProvinceOrState] = v.ProvinceOrState
+	dst[colPostalOrZip] = v.PostalOrZip
+	dst[colZipPlus4] = v.ZipPlus4
+	dst[colCountry] = v.Country
+	dst[colFirmName] = v.FirmName
+	dst[colStatus] = v.Status
+	dst[colErrors] = formatErrors(v.Errors)
+	dst[colAccuracyType] = string(v.GeocodeResult.AccuracyType)
+	if v.GeocodeResult.HasLocation() {
+		dst[colLatitude] = strconv.FormatFloat(v.GeocodeResult.Location.Latitude, 'f', -1, 64)
+		dst[colLongitude] = strconv.FormatFloat(v.GeocodeResult.Location.Longitude, 'f', -1, 64)
+	}
+
+	rv := reflect.ValueOf(v.Details)
+	for i, idx := range details {
+		dst[len(verifiedColumns)+i] = fmt.Sprint(rv.Field(idx).Interface())
+	}
+}
+
+// formatErrors formats the postgrid errors as "field: message; ..." sorted by field.
+func formatErrors(errs map[string][]string) string {
+	fields := make([]string, 0, len(errs))
+	for field := range errs {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	parts := make([]string, 0, len(errs))
+	for _, field := range fields {
+		for _, msg := range errs[field] {
+			parts = append(parts, field+": "+msg)
+		}
+	}
+
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,210 @@
+package csvverify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+
+	postgrid "github.com/bloomcredit/bloomcredit-postgrid-sdk"
+)
+
+// newTestClient returns a client whose batch endpoint upper cases Line1, or the freeform string, and
+// records the size of every batch.
+func newTestClient(tb testing.TB, opts ...postgrid.Option) (*postgrid.Client, *[]int) {
+	var sizes []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The handler runs on the server goroutine, where require cannot stop the test.
+		var req postgrid.BatchVerifyAddressesRequest
+		if !assert.NoError(tb, json.NewDecoder(r.Body).Decode(&req)) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		sizes = append(sizes, len(req.Addresses))
+
+		var results []postgrid.VerifiedAddressResponse
+		for _, a := range req.Addresses {
+			if a.String != "" {
+				a.Line1 = a.String
+			}
+			v := postgrid.VerifiedAddress{
+				Line1:           strings.ToUpper(a.Line1),
+				City:            strings.ToUpper(a.City),
+				PostalOrZip:     a.PostalOrZip,
+				ZipPlus4:        "1234",
+				Status:          postgrid.VerificationStatusVerified,
+				Details:         postgrid.VerifiedAddressDetails{County: "KINGS", Residential: true},
+				GeocodeResult:   postgrid.GeocodeResult{Location: postgrid.GeocodeLocation{Latitude: 40.5, Longitude: -73.25}, AccuracyType: postgrid.AccuracyTypeRooftop},
+				ProvinceOrState: "NY",
+			}
+			if a.Line1 == "bad" {
+				v = postgrid.VerifiedAddress{Status: postgrid.VerificationStatusFailed, Errors: map[string][]string{"line1": {"Missing"}, "city": {"Invalid"}}}
+			}
+			results = append(results, postgrid.VerifiedAddressResponse{VerifiedAddress: v})
+		}
+
+		data, err := json.Marshal(postgrid.BatchVerifyAddressesResponse{Results: results})
+		if !assert.NoError(tb, err) {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		assert.NoError(tb, json.NewEncoder(w).Encode(postgrid.Response{Status: postgrid.ResponseStatusSuccess, Data: data}))
+	}))
+	tb.Cleanup(srv.Close)
+
+	opts = append([]postgrid.Option{postgrid.WithHTTPClient(srv.Client()), postgrid.WithRateLimiter(rate.NewLimiter(rate.Inf, 0))}, opts...)
+	return postgrid.NewClient("", srv.URL, opts...), &sizes
+}
+
+func TestVerify(t *testing.T) {
+	header := "id,line1,verified_line1,verified_line2,verified_city,verified_province_or_state,verified_postal_or_zip," +
+		"verified_zip_plus4,verified_country,verified_firm_name,verified_status,verified_errors,verified_latitude," +
+		"verified_longitude,verified_accuracy_type"
+
+	tests := []struct {
+		name      string
+		input     string
+		mapping   Mapping
+		opts      []Option
+		want      string
+		wantSizes []int
+		wantErr   error
+	}{
+		{
+			name:    "structured with extra columns",
+			input:   "id,street,town,zip,note\n1,1 main st,brooklyn,11201,a\n2,,,,b\n3,bad,x,1,c\n",
+			mapping: Mapping{Line1: "street", City: "town", PostalOrZip: "zip"},
+			opts:    []Option{WithChunkSize(2), WithDetails("county", "residential")},
+			want: "id,street,town,zip,note,verified_line1,verified_line2,verified_city,verified_province_or_state," +
+				"verified_postal_or_zip,verified_zip_plus4,verified_country,verified_firm_name,verified_status," +
+				"verified_errors,verified_latitude,verified_longitude,verified_accuracy_type,verified_county,verified_residential\n" +
+				"1,1 main st,brooklyn,11201,a,1 MAIN ST,,BROOKLYN,NY,11201,1234,,,verified,,40.5,-73.25,rooftop,KINGS,true\n" +
+				"2,,,,b,,,,,,,,,,,,,,,\n" +
+				"3,bad,x,1,c,,,,,,,,,failed,city: Invalid; line1: Missing,,,,,false\n",
+			wantSizes: []int{1, 1},
+		},
+		{
+			name:      "freeform",
+			input:     "id,line1\n1,1 main st brooklyn ny\n",
+			mapping:   Mapping{String: "line1"},
+			want:      header + "\n1,1 main st brooklyn ny,1 MAIN ST BROOKLYN NY,,,NY,,1234,,,verified,,40.5,-73.25,rooftop\n",
+			wantSizes: []int{1},
+		},
+		{
+			name:    "custom prefix and comma",
+			input:   "id;line1\n1;a\n",
+			mapping: Mapping{Line1: "line1"},
+			opts:    []Option{WithColumnPrefix("pg_"), WithComma(';')},
+			want: strings.ReplaceAll(strings.ReplaceAll(header, "verified_", "pg_"), ",", ";") +
+				"\n1;a;A;;;NY;;1234;;;verified;;40.5;-73.25;rooftop\n",
+			wantSizes: []int{1},
+		},
+		{
+			name:    "missing column",
+			input:   "id,line1\n",
+			mapping: Mapping{Line1: "street"},
+			wantErr: ErrMissingColumn,
+		},
+		{
+			name:    "no mapping",
+			input:   "id,line1\n",
+			wantErr: ErrNoMapping,
+		},
+		{
+			name:    "unknown detail",
+			input:   "id,line1\n",
+			mapping: Mapping{Line1: "line1"},
+			opts:    []Option{WithDetails("nope")},
+			wantErr: ErrUnknownDetail,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, sizes := newTestClient(t)
+
+			var out bytes.Buffer
+			summary, err := Verify(context.Background(), client, strings.NewReader(tt.input), &out, tt.mapping, tt.opts...)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, out.String())
+			assert.Equal(t, tt.wantSizes, *sizes)
+			assert.Equal(t, strings.Count(tt.input, "\n")-1, summary.Rows, fmt.Sprint(summary))
+		})
+	}
+}
+
+func TestVerify_Progress(t *testing.T) {
+	var events []postgrid.ProgressEvent
+	client, _ := newTestClient(t, postgrid.WithProgressHook(func(ev postgrid.ProgressEvent) {
+		if ev.Type == postgrid.ProgressChunkFinished {
+			events = append(events, ev)
+		}
+	}))
+
+	var out bytes.Buffer
+	_, err := Verify(context.Background(), client, strings.NewReader("line1\na\nb\nc\n"), &out, Mapping{Line1: "line1"}, WithChunkSize(2))
+	require.NoError(t, err)
+
+	// The chunks are reported as a single operation of unknown size.
+	require.Len(t, events, 2)
+	for i, want := range []int{2, 3} {
+		assert.Equal(t, i, events[i].Chunk)
+		assert.Equal(t, want, events[i].Completed)
+		assert.Zero(t, events[i].Total)
+	}
+}
+
+// failingVerifier fails every BatchVerifyAddresses call after the first.
+type failingVerifier struct {
+	postgrid.AddressVerifier
+	calls int
+}
+
+func (f *failingVerifier) BatchVerifyAddresses(ctx context.Context, req postgrid.BatchVerifyAddressesRequest) (postgrid.BatchVerifyAddressesResponse, error) {
+	f.calls++
+	if f.calls > 1 {
+		return postgrid.BatchVerifyAddressesResponse{}, errors.New("unavailable")
+	}
+	return f.AddressVerifier.BatchVerifyAddresses(ctx, req)
+}
+
+func TestVerify_FlushesOnError(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	var out bytes.Buffer
+	input := "id,line1\n1,a\n2,b\n"
+	summary, err := Verify(context.Background(), &failingVerifier{AddressVerifier: client}, strings.NewReader(input), &out,
+		Mapping{Line1: "line1"}, WithChunkSize(1))
+	assert.ErrorContains(t, err, "verifying rows 2-2: unavailable")
+	assert.Equal(t, 1, summary.Rows)
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	require.Len(t, lines, 2, "the header and the row verified before the failure are written")
+	assert.True(t, strings.HasPrefix(lines[1], "1,a,A,"), lines[1])
+}
+
+// errWriter fails every write.
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestVerify_WriteError(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	_, err := Verify(context.Background(), client, strings.NewReader("id,line1\n1,a\n"), errWriter{}, Mapping{Line1: "line1"})
+	assert.ErrorContains(t, err, "csvverify: disk full")
+}
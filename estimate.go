@@ -0,0 +1,209 @@
+package postgrid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Estimate projects the api usage of a bulk verification without making any api call.
+type Estimate struct {
+	// Addresses is the number of input addresses.
+	Addresses int
+	// Invalid is the number of addresses failing local validation: they lack both a freeform string and a
+	// first line, or a structured address lacks both a postal code and a city with a province or state.
+	// They are still sent, and counted in Lookups, but are unlikely to verify.
+	Invalid int
+	// CacheHits is the number of addresses that would be served from the client's cache. The cache is
+	// consulted without side effects when it implements CacheContainer.
+	CacheHits int
+	// Duplicates is the number of addresses that would be served from the result of an identical address
+	// earlier in the input. Duplicates are only saved when the client is configured WithCache.
+	Duplicates int
+	// Completed is the number of addresses already verified in a BatchJob checkpoint.
+	Completed int
+	// Lookups is the number of addresses that would be sent to postgrid, i.e. the billable lookups.
+	Lookups int
+	// Chunks is the number of Batch Verify Addresses requests that would be made.
+	Chunks int
+	// Duration is the time the rate limiters of the Batch Verify Addresses endpoint would take to allow the
+	// requests at their current rates and tokens, after any pause requested by postgrid of an adaptive
+	// client. It ignores api latency and retries, and is 0 for unlimited rates.
+	Duration time.Duration
+}
+
+// EstimateBatchVerifyAddresses projects the usage of calling BatchVerifyAddresses with req, consulting
+// the client's cache but without touching the network.
+func (c *Client) EstimateBatchVerifyAddresses(ctx context.Context, req BatchVerifyAddressesRequest) (Estimate, error) {
+	e := c.newEstimator()
+	e.addChunk(ctx, req.Addresses)
+
+	return e.finish(), nil
+}
+
+// EstimateStreamVerifyAddresses consumes the addresses received from in and projects the usage of
+// verifying them with StreamVerifyAddresses and the same options, consulting the client's cache but without
+// touching the network. It returns the context's error when ctx is done before in is closed.
+func (c *Client) EstimateStreamVerifyAddresses(ctx context.Context, in <-chan Address, opts ...StreamOption) (Estimate, error) {
+	options := streamOptions{
+		chunkSize:     DefaultStreamChunkSize,
+		flushInterval: DefaultBatchWindow,
+	}
+
+	for _, opt := range opts {
+		opt.apply(&options)
+	}
+	chunkSize := min(max(options.chunkSize, 1), MaxBatchSize)
+
+	e := c.newEstimator()
+	chunk := make([]Address, 0, chunkSize)
+	for more := true; more; {
+		chunk, more = readChunk(ctx, in, chunk[:0], options.flushInterval)
+		if err := ctx.Err(); err != nil {
+			return Estimate{}, err
+		}
+		e.addChunk(ctx, chunk)
+	}
+
+	return e.finish(), nil
+}
+
+// Estimate projects the usage of running the job with addresses, skipping the chunks already completed in
+// its checkpoint file and consulting the client's cache, without touching the network. The checkpoint file
+// is not created or modified.
+func (j *BatchJob) Estimate(ctx context.Context, addresses []Address) (Estimate, error) {
+	aead, err := j.cipher()
+	if err != nil {
+		return Estimate{}, err
+	}
+	next := 0
+	f, err := os.Open(j.checkpoint)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return Estimate{}, fmt.Errorf("postgrid: %w", err)
+	default:
+		defer f.Close()
+
+		var header checkpointHeader
+		size, err := readCheckpoint(f, aead, &header, func(checkpointChunk) error {
+			next++
+			return nil
+		})
+		if err != nil {
+			return Estimate{}, err
+		}
+		want := checkpointHeader{Total: len(addresses), ChunkSize: j.chunkSize, InputHash: hashAddresses(addresses)}
+		if size > 0 && header != want {
+			return Estimate{}, ErrCheckpointMismatch
+		}
+	}
+
+	e := j.client.newEstimator()
+	for lo := 0; lo < len(addresses); lo += j.chunkSize {
+		chunk := addresses[lo:min(lo+j.chunkSize, len(addresses))]
+		if lo/j.chunkSize < next {
+			e.est.Addresses += len(chunk)
+			e.est.Completed += len(chunk)
+			continue
+		}
+		e.addChunk(ctx, chunk)
+	}
+
+	return e.finish(), nil
+}
+
+// estimator accumulates an Estimate chunk by chunk, mirroring how batchVerify uses the cache.
+type estimator struct {
+	c    *Client
+	est  Estimate
+	sent map[string]bool
+}
+
+func (c *Client) newEstimator() *estimator {
+	return &estimator{c: c, sent: map[string]bool{}}
+}
+
+func (e *estimator) addChunk(ctx context.Context, chunk []Address) {
+	lookups := 0
+	inChunk := map[string]bool{}
+	for _, a := range chunk {
+		e.est.Addresses++
+		if !validForEstimate(a) {
+			e.est.Invalid++
+		}
+
+		if e.c.cache == nil {
+			lookups++
+			continue
+		}
+
+		key := CacheKey(a)
+		switch {
+		case inChunk[key], e.sent[key] && e.c.cacheTTL > 0:
+			// Sent once per chunk, then cached for later chunks.
+			e.est.Duplicates++
+		default:
+			if e.c.cacheContains(ctx, key) {
+				e.est.CacheHits++
+				continue
+			}
+			inChunk[key] = true
+			lookups++
+		}
+	}
+
+	for key := range inChunk {
+		e.sent[key] = true
+	}
+	e.est.Lookups += lookups
+	if lookups > 0 {
+		e.est.Chunks++
+	}
+}
+
+// finish projects the duration of the requests from the rate limiter.
+func (e *estimator) finish() Estimate {
+	if e.est.Chunks == 0 {
+		return e.est
+	}
+
+	// Requests wait for both the limiter of the client and the limiter of the endpoint.
+	e.est.Duration = limiterDuration(e.c.rateLimiter, e.est.Chunks)
+	if s, ok := e.c.endpointSchedulers[EndpointBatchVerify]; ok {
+		e.est.Duration = max(e.est.Duration, limiterDuration(s.limiter, e.est.Chunks))
+	}
+	if e.c.adaptive != nil {
+		e.est.Duration += e.c.adaptive.pause()
+	}
+
+	return e.est
+}
+
+// limiterDuration returns the time limiter takes to allow n requests at its current rate and tokens.
+func limiterDuration(limiter *rate.Limiter, n int) time.Duration {
+	limit := float64(limiter.Limit())
+	if waiting := float64(n) - limiter.Tokens(); limit > 0 && waiting > 0 {
+		return time.Duration(waiting / limit * float64(time.Second))
+	}
+
+	return 0
+}
+
+// validForEstimate applies the local validation described by Estimate.Invalid.
+func validForEstimate(a Address) bool {
+	if strings.TrimSpace(a.String) != "" {
+		return true
+	}
+	if strings.TrimSpace(a.Line1) == "" {
+		return false
+	}
+
+	return strings.TrimSpace(a.PostalOrZip) != "" ||
+		strings.TrimSpace(a.City) != "" && strings.TrimSpace(a.ProvinceOrState) != ""
+}
@@ -0,0 +1,172 @@
+package postgrid
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+// newOfflineClient returns a client whose every request fails the test.
+func newOfflineClient(t *testing.T, opts ...Option) *Client {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %s", r.URL.Path)
+	}))
+	t.Cleanup(srv.Close)
+
+	return NewClient("", srv.URL, append([]Option{WithHTTPClient(srv.Client())}, opts...)...)
+}
+
+func TestClient_EstimateStreamVerifyAddresses(t *testing.T) {
+	addresses := []Address{
+		{Line1: "1 main st", PostalOrZip: "11201"},
+		{Line1: "1 MAIN ST", PostalOrZip: "11201"},
+		{String: "2 main st, brooklyn ny"},
+		{Line1: "3 main st", City: "brooklyn"},
+		{},
+		{Line1: "1 main st", PostalOrZip: "11201"},
+		{Line1: "cached", PostalOrZip: "11201"},
+	}
+
+	tests := []struct {
+		name string
+		opts []Option
+		want Estimate
+	}{
+		{
+			name: "without cache",
+			opts: []Option{WithRateLimiter(rate.NewLimiter(2, 1))},
+			want: Estimate{Addresses: 7, Invalid: 2, Lookups: 7, Chunks: 3, Duration: time.Second},
+		},
+		{
+			name: "with cache",
+			opts: []Option{WithRateLimiter(rate.NewLimiter(2, 1)), WithCache(NewLRUCache(10))},
+			want: Estimate{Addresses: 7, Invalid: 2, CacheHits: 1, Duplicates: 2, Lookups: 4, Chunks: 2, Duration: time.Second / 2},
+		},
+		{
+			name: "endpoint limiter",
+			opts: []Option{WithRateLimiter(rate.NewLimiter(2, 1)), WithEndpointRateLimiter(EndpointBatchVerify, rate.NewLimiter(1, 1))},
+			want: Estimate{Addresses: 7, Invalid: 2, Lookups: 7, Chunks: 3, Duration: 2 * time.Second},
+		},
+		{
+			name: "limiter of another endpoint",
+			opts: []Option{WithRateLimiter(rate.NewLimiter(2, 1)), WithEndpointRateLimiter(EndpointVerify, rate.NewLimiter(1, 1))},
+			want: Estimate{Addresses: 7, Invalid: 2, Lookups: 7, Chunks: 3, Duration: time.Second},
+		},
+		{
+			name: "cache without ttl does not dedupe across chunks",
+			opts: []Option{WithRateLimiter(rate.NewLimiter(rate.Inf, 0)), WithCache(NewLRUCache(10)), WithCacheTTL(0, 0)},
+			want: Estimate{Addresses: 7, Invalid: 2, CacheHits: 1, Duplicates: 1, Lookups: 5, Chunks: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newOfflineClient(t, tt.opts...)
+			if client.cache != nil {
+				require.NoError(t, client.cache.Set(context.Background(), CacheKey(addresses[6]), CacheEntry{ExpiresAt: time.Now().Add(time.Hour)}))
+			}
+
+			in := make(chan Address, len(addresses))
+			for _, a := range addresses {
+				in <- a
+			}
+			close(in)
+
+			got, err := client.EstimateStreamVerifyAddresses(context.Background(), in, WithStreamChunkSize(3))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestClient_EstimateBatchVerifyAddresses(t *testing.T) {
+	client := newOfflineClient(t, WithRateLimiter(rate.NewLimiter(rate.Inf, 0)), WithCache(NewLRUCache(10)))
+
+	got, err := client.EstimateBatchVerifyAddresses(context.Background(), BatchVerifyAddressesRequest{
+		Addresses: []Address{{String: "a"}, {String: "a"}, {String: "b"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, Estimate{Addresses: 3, Duplicates: 1, Lookups: 2, Chunks: 1}, got)
+}
+
+func TestClient_EstimateBatchVerifyAddresses_CacheUnchanged(t *testing.T) {
+	ctx := context.Background()
+	cache := NewLRUCache(2)
+	client := newOfflineClient(t, WithRateLimiter(rate.NewLimiter(rate.Inf, 0)), WithCache(cache))
+	a, b := Address{String: "a"}, Address{String: "b"}
+	client.cacheSet(ctx, CacheKey(a), VerifiedAddress{Status: VerificationStatusVerified})
+	client.cacheSet(ctx, CacheKey(b), VerifiedAddress{Status: VerificationStatusVerified})
+
+	got, err := client.EstimateBatchVerifyAddresses(ctx, BatchVerifyAddressesRequest{Addresses: []Address{a}})
+	require.NoError(t, err)
+	assert.Equal(t, 1, got.CacheHits)
+
+	// The estimate did not make "a" the most recently used entry, so it is evicted first.
+	client.cacheSet(ctx, CacheKey(Address{String: "c"}), VerifiedAddress{Status: VerificationStatusVerified})
+	ok, err := cache.Contains(ctx, CacheKey(a))
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestClient_EstimateStreamVerifyAddresses_Cancel(t *testing.T) {
+	client := newOfflineClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := client.EstimateStreamVerifyAddresses(ctx, make(chan Address))
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestBatchJob_Estimate(t *testing.T) {
+	var addresses []Address
+	for i := 0; i < 5; i++ {
+		addresses = append(addresses, Address{String: fmt.Sprint(i)})
+	}
+	path := filepath.Join(t.TempDir(), "job.checkpoint")
+
+	// The first chunk completes before the second fails.
+	srv, _ := newJobTestServer(t, 2)
+	client := NewClient("", srv.URL, WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Inf, 0)))
+	job := NewBatchJob(client, path, WithJobChunkSize(2))
+
+	got, err := job.Estimate(context.Background(), addresses)
+	require.NoError(t, err)
+	assert.Equal(t, Estimate{Addresses: 5, Lookups: 5, Chunks: 3}, got)
+	_, err = os.Stat(path)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	_, err = job.Run(context.Background(), addresses)
+	require.Error(t, err)
+
+	got, err = job.Estimate(context.Background(), addresses)
+	require.NoError(t, err)
+	assert.Equal(t, Estimate{Addresses: 5, Completed: 2, Lookups: 3, Chunks: 2}, got)
+
+	_, err = job.Estimate(context.Background(), addresses[:4])
+	assert.ErrorIs(t, err, ErrCheckpointMismatch)
+}
+
+func TestClient_EstimateStreamVerifyAddresses_Adaptive(t *testing.T) {
+	client := newOfflineClient(t, WithRateLimiter(rate.NewLimiter(2, 1)), WithAdaptiveRateLimit(0.5, 0))
+	client.adaptive.observe(testResponse(http.StatusTooManyRequests, map[string]string{"Retry-After": "10"}))
+
+	in := make(chan Address, 3)
+	for _, line := range []string{"A", "B", "C"} {
+		in <- Address{Line1: line}
+	}
+	close(in)
+
+	// The rate is halved to 1 request per second after a pause of 10s.
+	got, err := client.EstimateStreamVerifyAddresses(context.Background(), in, WithStreamChunkSize(1))
+	require.NoError(t, err)
+	assert.Equal(t, 3, got.Chunks)
+	assert.InDelta(t, 12*time.Second, got.Duration, float64(100*time.Millisecond))
+}
@@ -0,0 +1,490 @@
+// Package filecache provides a postgrid.Cache that persists verification results to a local file so they
+// survive process restarts.
+//
+// Entries are stored in an append-only log. Every record is encrypted with AES-GCM using a caller-provided
+// key, since verified addresses are personal information. Expired and overwritten records are dropped when
+// the log is compacted.
+package filecache
+
+import (
+	"bufio"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	postgrid "github.com/bloomcredit/bloomcredit-postgrid-sdk"
+)
+
+// magic identifies a cache file and its format version. It is also authenticated with every record.
+var magic = []byte("PGCACHE1")
+
+// maxRecordSize guards against allocating huge buffers when reading a corrupt length prefix.
+const maxRecordSize = 1 << 20
+
+var (
+	// ErrInvalidFile is returned by Open when the file is not a postgrid cache file.
+	ErrInvalidFile = errors.New("filecache: not a postgrid cache file")
+	// ErrDecrypt is returned by Open when the first record cannot be decrypted, most likely because the key
+	// is wrong.
+	ErrDecrypt = errors.New("filecache: unable to decrypt record")
+	// ErrCorrupt is returned by Open when a record that is followed by valid records cannot be read.
+	ErrCorrupt = errors.New("filecache: corrupt record")
+	// ErrClosed is returned when using a closed cache.
+	ErrClosed = errors.New("filecache: cache is closed")
+)
+
+var (
+	_ postgrid.Cache          = (*Cache)(nil)
+	_ postgrid.CacheContainer = (*Cache)(nil)
+)
+
+// Cache is a file-backed postgrid.Cache. It is safe for concurrent use within a single process; the file
+// must not be shared between processes.
+type Cache struct {
+	path string
+	aead cipher.AEAD
+	now  func() time.Time
+
+	// compactRatio is the fraction of dead records that triggers an automatic compaction.
+	compactRatio float64
+	sync         bool
+
+	mu    sync.Mutex
+	file  *os.File
+	size  int64
+	index map[string]location
+	dead  int
+}
+
+type location struct {
+	offset    int64
+	length    int
+	expiresAt time.Time
+}
+
+type record struct {
+	Key   string              `json:"key"`
+	Entry postgrid.CacheEntry `json:"entry"`
+}
+
+// Option configures a Cache.
+type Option interface {
+	apply(*Cache)
+}
+
+type compactRatioOption float64
+
+func (o compactRatioOption) apply(c *Cache) {
+	c.compactRatio = float64(o)
+}
+
+// WithCompactRatio configures the fraction of expired or overwritten records, between 0 and 1, above which
+// Set compacts the file. It defaults to 0.5. A ratio of 1 disables automatic compaction.
+func WithCompactRatio(ratio float64) Option {
+	return compactRatioOption(ratio)
+}
+
+type syncOption bool
+
+func (o syncOption) apply(c *Cache) {
+	c.sync = bool(o)
+}
+
+// WithSync configures whether every Set is flushed to stable storage before returning. It defaults to
+// false, trading the last few entries on power loss for throughput.
+func WithSync(sync bool) Option {
+	return syncOption(sync)
+}
+
+// clockOption overrides the current time, for tests.
+type clockOption func() time.Time
+
+func (o clockOption) apply(c *Cache) {
+	c.now = o
+}
+
+// Open opens or creates the cache file at path. key must be 16, 24 or 32 bytes to select AES-128, AES-192
+// or AES-256. Expired entries are compacted away on open, and the partially written or unflushed records
+// left at the end of the file by a crash are truncated.
+func Open(path string, key []byte, opts ...Option) (*Cache, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("filecache: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("filecache: %w", err)
+	}
+
+	c := &Cache{
+		path:         path,
+		aead:         aead,
+		now:          time.Now,
+		compactRatio: 0.5,
+	}
+	for _, opt := range opts {
+		opt.apply(c)
+	}
+
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	if c.dead > 0 {
+		if err := c.compact(); err != nil {
+			c.file.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// load opens the file and rebuilds the index from its records.
+func (c *Cache) load() error {
+	f, err := os.OpenFile(c.path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("filecache: %w", err)
+	}
+
+	size, err := c.readIndex(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	// Drop the torn tail of the log.
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return fmt.Errorf("filecache: %w", err)
+	}
+	if _, err := f.Seek(size, io.SeekStart); err != nil {
+		f.Close()
+		return fmt.Errorf("filecache: %w", err)
+	}
+
+	c.file = f
+	c.size = size
+
+	return nil
+}
+
+// readIndex reads every record in f and returns the offset after the last valid record. Records that
+// cannot be read are a torn tail when no valid record follows them; a crash may leave the last records
+// cut short or zero-filled.
+func (c *Cache) readIndex(f *os.File) (int64, error) {
+	c.index = map[string]location{}
+	c.dead = 0
+
+	r := bufio.NewReader(f)
+	header := make([]byte, len(magic))
+	n, err := io.ReadFull(r, header)
+	switch {
+	case n == 0 && errors.Is(err, io.EOF):
+		if _, err := f.Write(magic); err != nil {
+			return 0, fmt.Errorf("filecache: %w", err)
+		}
+		return int64(len(magic)), nil
+	case err != nil || string(header) != string(magic):
+		return 0, ErrInvalidFile
+	}
+
+	offset := int64(len(magic))
+	torn := int64(-1)
+	now := c.now()
+	for {
+		ciphertext, err := readRecord(r)
+		switch {
+		case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+			if torn >= 0 {
+				return torn, nil
+			}
+			return offset, nil
+		case err != nil:
+			// The records after a corrupt length prefix cannot be found, so only a torn tail may
+			// be dropped.
+			if torn >= 0 {
+				return torn, nil
+			}
+			return 0, fmt.Errorf("%w at offset %d", ErrCorrupt, offset)
+		}
+
+		rec, err := c.decrypt(ciphertext)
+		switch {
+		case errors.Is(err, ErrDecrypt):
+			if offset == int64(len(magic)) && !zeroed(ciphertext) {
+				return 0, err
+			}
+			if torn < 0 {
+				torn = offset
+			}
+			offset += int64(4 + len(ciphertext))
+			continue
+		case err != nil:
+			return 0, err
+		case torn >= 0:
+			return 0, fmt.Errorf("%w at offset %d", ErrCorrupt, torn)
+		}
+
+		if _, ok := c.index[rec.Key]; ok {
+			c.dead++
+		}
+		if now.Before(rec.Entry.ExpiresAt) {
+			c.index[rec.Key] = location{offset: offset, length: 4 + len(ciphertext), expiresAt: rec.Entry.ExpiresAt}
+		} else {
+			delete(c.index, rec.Key)
+			c.dead++
+		}
+		offset += int64(4 + len(ciphertext))
+	}
+}
+
+// zeroed reports whether b holds only zero bytes, as left by a crash before the record was flushed.
+func zeroed(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func readRecord(r io.Reader) ([]byte, error) {
+	var length uint32
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	if length > maxRecordSize {
+		return nil, ErrInvalidFile
+	}
+
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// Get returns the entry stored under key unless it has expired.
+func (c *Cache) Get(_ context.Context, key string) (postgrid.CacheEntry, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return postgrid.CacheEntry{}, false, ErrClosed
+	}
+
+	loc, ok := c.index[key]
+	if !ok {
+		return postgrid.CacheEntry{}, false, nil
+	}
+	if !c.now().Before(loc.expiresAt) {
+		delete(c.index, key)
+		c.dead++
+		return postgrid.CacheEntry{}, false, nil
+	}
+
+	buf := make([]byte, loc.length)
+	if _, err := c.file.ReadAt(buf, loc.offset); err != nil {
+		return postgrid.CacheEntry{}, false, fmt.Errorf("filecache: %w", err)
+	}
+	rec, err := c.decrypt(buf[4:])
+	if err != nil {
+		return postgrid.CacheEntry{}, false, err
+	}
+
+	return rec.Entry, true, nil
+}
+
+// Contains reports whether an unexpired entry is stored under key, without reading or evicting it.
+func (c *Cache) Contains(_ context.Context, key string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return false, ErrClosed
+	}
+
+	loc, ok := c.index[key]
+	return ok && c.now().Before(loc.expiresAt), nil
+}
+
+// Set appends entry to the log, compacting the file when enough records are dead.
+func (c *Cache) Set(_ context.Context, key string, entry postgrid.CacheEntry) error {
+	ciphertext, err := c.encrypt(record{Key: key, Entry: entry})
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return ErrClosed
+	}
+
+	if err := c.append(ciphertext); err != nil {
+		return err
+	}
+	if _, ok := c.index[key]; ok {
+		c.dead++
+	}
+	c.index[key] = location{offset: c.size, length: 4 + len(ciphertext), expiresAt: entry.ExpiresAt}
+	c.size += int64(4 + len(ciphertext))
+
+	if c.compactRatio < 1 && c.dead > 0 && float64(c.dead) > c.compactRatio*float64(c.dead+len(c.index)) {
+		return c.compact()
+	}
+
+	return nil
+}
+
+func (c *Cache) append(ciphertext []byte) error {
+	buf := make([]byte, 4+len(ciphertext))
+	binary.BigEndian.PutUint32(buf, uint32(len(ciphertext)))
+	copy(buf[4:], ciphertext)
+
+	if _, err := c.file.WriteAt(buf, c.size); err != nil {
+		return fmt.Errorf("filecache: %w", err)
+	}
+	if c.sync {
+		if err := c.file.Sync(); err != nil {
+			return fmt.Errorf("filecache: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Compact rewrites the file with only the live entries.
+func (c *Cache) Compact() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return ErrClosed
+	}
+
+	return c.compact()
+}
+
+// compact copies live records to a temporary file which then atomically replaces the log.
+func (c *Cache) compact() error {
+	tmpPath := c.path + ".compact"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("filecache: %w", err)
+	}
+	defer os.Remove(tmpPath)
+
+	now := c.now()
+	index := make(map[string]location, len(c.index))
+	offset := int64(len(magic))
+	w := bufio.NewWriter(tmp)
+	if _, err := w.Write(magic); err != nil {
+		tmp.Close()
+		return fmt.Errorf("filecache: %w", err)
+	}
+	for key, loc := range c.index {
+		if !now.Before(loc.expiresAt) {
+			continue
+		}
+		buf := make([]byte, loc.length)
+		if _, err := c.file.ReadAt(buf, loc.offset); err != nil {
+			tmp.Close()
+			return fmt.Errorf("filecache: %w", err)
+		}
+		if _, err := w.Write(buf); err != nil {
+			tmp.Close()
+			return fmt.Errorf("filecache: %w", err)
+		}
+		index[key] = location{offset: offset, length: loc.length, expiresAt: loc.expiresAt}
+		offset += int64(loc.length)
+	}
+
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("filecache: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("filecache: %w", err)
+	}
+	if err := os.Rename(tmpPath, c.path); err != nil {
+		tmp.Close()
+		return fmt.Errorf("filecache: %w", err)
+	}
+
+	c.file.Close()
+	c.file = tmp
+	c.size = offset
+	c.index = index
+	c.dead = 0
+
+	return nil
+}
+
+// Len returns the number of live entries.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.index)
+}
+
+// Close closes the underlying file.
+func (c *Cache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return ErrClosed
+	}
+	err := c.file.Close()
+	c.file = nil
+
+	return err
+}
+
+func (c *Cache) encrypt(rec record) ([]byte, error) {
+	plaintext, err := json.Marshal(rec)
+	if err != nil {
+		return nil, fmt.Errorf("filecache: %w", err)
+	}
+
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(plaintext)+c.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("filecache: %w", err)
+	}
+
+	return c.aead.Seal(nonce, nonce, plaintext, magic), nil
+}
+
+func (c *Cache) decrypt(ciphertext []byte) (record, error) {
+	if len(ciphertext) < c.aead.NonceSize() {
+		return record{}, ErrDecrypt
+	}
+
+	nonce, sealed := ciphertext[:c.aead.NonceSize()], ciphertext[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, sealed, magic)
+	if err != nil {
+		return record{}, ErrDecrypt
+	}
+
+	var rec record
+	if err := json.Unmarshal(plaintext, &rec); err != nil {
+		return record{}, fmt.Errorf("filecache: %w", err)
+	}
+
+	return rec, nil
+}
@@ -0,0 +1,262 @@
+package filecache
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	postgrid "github.com/bloomcredit/bloomcredit-postgrid-sdk"
+)
+
+var testKey = bytes.Repeat([]byte{0x42}, 32)
+
+func TestCache_Persistence(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache.log")
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	entry := postgrid.CacheEntry{
+		Address:   postgrid.VerifiedAddress{Line1: "251 E 13TH ST", City: "NEW YORK", Status: postgrid.VerificationStatusVerified},
+		StoredAt:  now,
+		ExpiresAt: now.Add(time.Hour),
+	}
+
+	c := openAt(t, path, now)
+	require.NoError(t, c.Set(ctx, "key", entry))
+	require.NoError(t, c.Close())
+
+	// Addresses must not be stored in plain text.
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "13TH")
+	assert.NotContains(t, string(raw), "key")
+
+	c = openAt(t, path, now)
+	got, ok, err := c.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, entry.Address, got.Address)
+	assert.True(t, entry.ExpiresAt.Equal(got.ExpiresAt))
+
+	_, ok, err = c.Get(ctx, "missing")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestCache_Expiry(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache.log")
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	c := openAt(t, path, now)
+	require.NoError(t, c.Set(ctx, "short", postgrid.CacheEntry{ExpiresAt: now.Add(time.Minute)}))
+	require.NoError(t, c.Set(ctx, "long", postgrid.CacheEntry{ExpiresAt: now.Add(time.Hour)}))
+
+	c.now = func() time.Time { return now.Add(2 * time.Minute) }
+	_, ok, err := c.Get(ctx, "short")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	_, ok, err = c.Get(ctx, "long")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = c.Contains(ctx, "long")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = c.Contains(ctx, "short")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	require.NoError(t, c.Close())
+
+	// Reopening after expiry compacts the expired record away.
+	before := fileSize(t, path)
+	c = openAt(t, path, now.Add(2*time.Minute))
+	assert.Equal(t, 1, c.Len())
+	assert.Less(t, fileSize(t, path), before)
+}
+
+func TestCache_CompactsOverwrittenEntries(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache.log")
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	c := openAt(t, path, now)
+	c.compactRatio = 1
+	for i := 0; i < 10; i++ {
+		require.NoError(t, c.Set(ctx, "key", postgrid.CacheEntry{
+			Address:   postgrid.VerifiedAddress{Line1: string(rune('A' + i))},
+			ExpiresAt: now.Add(time.Hour),
+		}))
+	}
+	before := fileSize(t, path)
+
+	require.NoError(t, c.Compact())
+	assert.Less(t, fileSize(t, path), before)
+
+	got, ok, err := c.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "J", got.Address.Line1)
+
+	// Compaction also happens automatically once half of the records are dead.
+	c.compactRatio = 0.5
+	require.NoError(t, c.Set(ctx, "key", postgrid.CacheEntry{ExpiresAt: now.Add(time.Hour)}))
+	require.NoError(t, c.Set(ctx, "key", postgrid.CacheEntry{ExpiresAt: now.Add(time.Hour)}))
+	assert.Equal(t, 0, c.dead)
+}
+
+func TestCache_TruncatedTail(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache.log")
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	c := openAt(t, path, now)
+	require.NoError(t, c.Set(ctx, "a", postgrid.CacheEntry{ExpiresAt: now.Add(time.Hour)}))
+	require.NoError(t, c.Set(ctx, "b", postgrid.CacheEntry{ExpiresAt: now.Add(time.Hour)}))
+	require.NoError(t, c.Close())
+
+	// Simulate a crash in the middle of writing the last record.
+	require.NoError(t, os.Truncate(path, fileSize(t, path)-5))
+
+	c = openAt(t, path, now)
+	_, ok, err := c.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	_, ok, err = c.Get(ctx, "b")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// New records are appended after the last complete record.
+	require.NoError(t, c.Set(ctx, "c", postgrid.CacheEntry{ExpiresAt: now.Add(time.Hour)}))
+	require.NoError(t, c.Close())
+	c = openAt(t, path, now)
+	assert.Equal(t, 2, c.Len())
+}
+
+func TestCache_ZeroFilledTail(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache.log")
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	c := openAt(t, path, now)
+	require.NoError(t, c.Set(ctx, "a", postgrid.CacheEntry{ExpiresAt: now.Add(time.Hour)}))
+	require.NoError(t, c.Close())
+	size := fileSize(t, path)
+
+	// Simulate a crash before the payloads of the last records were flushed.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	for _, n := range []int{64, 0, 32} {
+		require.NoError(t, binary.Write(f, binary.BigEndian, uint32(n)))
+		_, err = f.Write(make([]byte, n))
+		require.NoError(t, err)
+	}
+	require.NoError(t, f.Close())
+
+	c = openAt(t, path, now)
+	assert.Equal(t, size, fileSize(t, path))
+	_, ok, err := c.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	require.NoError(t, c.Set(ctx, "b", postgrid.CacheEntry{ExpiresAt: now.Add(time.Hour)}))
+	require.NoError(t, c.Close())
+	c = openAt(t, path, now)
+	assert.Equal(t, 2, c.Len())
+}
+
+func TestCache_CorruptRecord(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		corrupt func(data []byte, second int)
+	}{
+		{
+			name: "payload",
+			corrupt: func(data []byte, second int) {
+				data[second+10] ^= 0xff
+			},
+		},
+		{
+			name: "length",
+			corrupt: func(data []byte, second int) {
+				binary.BigEndian.PutUint32(data[second:], maxRecordSize+1)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cache.log")
+			c := openAt(t, path, now)
+			require.NoError(t, c.Set(ctx, "a", postgrid.CacheEntry{ExpiresAt: now.Add(time.Hour)}))
+			second := int(fileSize(t, path))
+			require.NoError(t, c.Set(ctx, "b", postgrid.CacheEntry{ExpiresAt: now.Add(time.Hour)}))
+			require.NoError(t, c.Set(ctx, "c", postgrid.CacheEntry{ExpiresAt: now.Add(time.Hour)}))
+			require.NoError(t, c.Close())
+
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			tt.corrupt(data, second)
+			require.NoError(t, os.WriteFile(path, data, 0o600))
+
+			// Records following the corrupt one are not silently dropped.
+			_, err = Open(path, testKey)
+			assert.ErrorIs(t, err, ErrCorrupt)
+			assert.Equal(t, int64(len(data)), fileSize(t, path))
+		})
+	}
+}
+
+func TestOpen_Errors(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	now := time.Now()
+
+	path := filepath.Join(dir, "cache.log")
+	c := openAt(t, path, now)
+	require.NoError(t, c.Set(ctx, "a", postgrid.CacheEntry{ExpiresAt: now.Add(time.Hour)}))
+	require.NoError(t, c.Close())
+
+	_, err := Open(path, bytes.Repeat([]byte{0x43}, 32))
+	assert.ErrorIs(t, err, ErrDecrypt)
+
+	_, err = Open(path, []byte("short"))
+	assert.Error(t, err)
+
+	other := filepath.Join(dir, "other.txt")
+	require.NoError(t, os.WriteFile(other, []byte("not a cache file"), 0o600))
+	_, err = Open(other, testKey)
+	assert.ErrorIs(t, err, ErrInvalidFile)
+
+	c = openAt(t, filepath.Join(dir, "closed.log"), now)
+	require.NoError(t, c.Close())
+	_, _, err = c.Get(ctx, "a")
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.ErrorIs(t, c.Set(ctx, "a", postgrid.CacheEntry{}), ErrClosed)
+}
+
+func openAt(tb testing.TB, path string, now time.Time) *Cache {
+	tb.Helper()
+
+	c, err := Open(path, testKey, clockOption(func() time.Time { return now }))
+	require.NoError(tb, err)
+	tb.Cleanup(func() { _ = c.Close() })
+
+	return c
+}
+
+func fileSize(tb testing.TB, path string) int64 {
+	tb.Helper()
+
+	info, err := os.Stat(path)
+	require.NoError(tb, err)
+
+	return info.Size()
+}
@@ -0,0 +1,38 @@
+package postgrid
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"unicode"
+)
+
+// Fingerprint returns a canonical identifier for the delivery point of a verified address. Two verified
+// addresses share a fingerprint when their normalized Line1, Line2, postal code, ZIP+4, delivery point and
+// check digit are equal, regardless of casing, punctuation or spacing differences.
+func (v VerifiedAddress) Fingerprint() string {
+	parts := []string{
+		normalizeFingerprintPart(v.Country),
+		normalizeFingerprintPart(v.PostalOrZip),
+		normalizeFingerprintPart(v.ZipPlus4),
+		normalizeFingerprintPart(v.Details.USMailingsDeliveryPoint),
+		normalizeFingerprintPart(v.Details.USMailingsCheckDigit),
+		normalizeFingerprintPart(v.Line1),
+		normalizeFingerprintPart(v.Line2),
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(parts, "|")))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeFingerprintPart upper cases s, replaces punctuation with spaces and collapses whitespace.
+func normalizeFingerprintPart(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+		return ' '
+	}, s)
+
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,82 @@
+package postgrid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifiedAddress_Fingerprint(t *testing.T) {
+	base := VerifiedAddress{
+		Line1:       "251 E 13TH ST FRNT A",
+		City:        "NEW YORK",
+		PostalOrZip: "10003",
+		ZipPlus4:    "5646",
+		Country:     "us",
+		Details: VerifiedAddressDetails{
+			USMailingsDeliveryPoint: "51",
+			USMailingsCheckDigit:    "4",
+		},
+	}
+
+	tests := []struct {
+		name string
+		a    VerifiedAddress
+		b    VerifiedAddress
+		want bool
+	}{
+		{
+			name: "identical",
+			a:    base,
+			b:    base,
+			want: true,
+		},
+		{
+			name: "casing, punctuation and spacing are ignored",
+			a:    base,
+			b: func() VerifiedAddress {
+				v := base
+				v.Line1 = " 251 e. 13th  st, frnt a "
+				v.Country = "US"
+				return v
+			}(),
+			want: true,
+		},
+		{
+			name: "fields outside the fingerprint are ignored",
+			a:    base,
+			b: func() VerifiedAddress {
+				v := base
+				v.City = "MANHATTAN"
+				v.Status = "verified"
+				return v
+			}(),
+			want: true,
+		},
+		{
+			name: "different delivery point",
+			a:    base,
+			b: func() VerifiedAddress {
+				v := base
+				v.Details.USMailingsDeliveryPoint = "53"
+				return v
+			}(),
+			want: false,
+		},
+		{
+			name: "different unit",
+			a:    base,
+			b: func() VerifiedAddress {
+				v := base
+				v.Line2 = "APT 2"
+				return v
+			}(),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.a.Fingerprint() == tt.b.Fingerprint())
+		})
+	}
+}
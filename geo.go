@@ -0,0 +1,222 @@
+package postgrid
+
+import "math"
+
+// EarthRadiusMeters is the mean radius of the earth used for great-circle distances.
+const EarthRadiusMeters = 6371008.8
+
+// AccuracyType describes what a geocoded location points at.
+type AccuracyType string
+
+// All possible values for AccuracyType, from most to least precise.
+const (
+	AccuracyTypeRooftop             AccuracyType = "rooftop"
+	AccuracyTypePoint               AccuracyType = "point"
+	AccuracyTypeNearestRooftopMatch AccuracyType = "nearest_rooftop_match"
+	AccuracyTypeRangeInterpolation  AccuracyType = "range_interpolation"
+	AccuracyTypeIntersection        AccuracyType = "intersection"
+	AccuracyTypeStreetCenter        AccuracyType = "street_center"
+	AccuracyTypePlace               AccuracyType = "place"
+	AccuracyTypePostalCentroid      AccuracyType = "postal_centroid"
+	AccuracyTypeCounty              AccuracyType = "county"
+	AccuracyTypeState               AccuracyType = "state"
+)
+
+var accuracyTypeRanks = map[AccuracyType]int{
+	AccuracyTypeRooftop:             10,
+	AccuracyTypePoint:               9,
+	AccuracyTypeNearestRooftopMatch: 8,
+	AccuracyTypeRangeInterpolation:  7,
+	AccuracyTypeIntersection:        6,
+	AccuracyTypeStreetCenter:        5,
+	AccuracyTypePlace:               4,
+	AccuracyTypePostalCentroid:      3,
+	AccuracyTypeCounty:              2,
+	AccuracyTypeState:               1,
+}
+
+// Rank orders accuracy types by precision. Higher ranks are more precise, unknown types rank 0.
+func (t AccuracyType) Rank() int {
+	return accuracyTypeRanks[t]
+}
+
+// AtLeast reports whether t is at least as precise as other.
+func (t AccuracyType) AtLeast(other AccuracyType) bool {
+	return t.Rank() > 0 && t.Rank() >= other.Rank()
+}
+
+// IsStreetLevel reports whether the location points at or along the street of the address rather than at
+// the centroid of a larger area.
+func (t AccuracyType) IsStreetLevel() bool {
+	return t.AtLeast(AccuracyTypeStreetCenter)
+}
+
+// HasLocation reports whether the geocode result carries a location.
+func (g GeocodeResult) HasLocation() bool {
+	return g.AccuracyType != "" || g.Location != GeocodeLocation{}
+}
+
+// DistanceTo returns the great-circle distance in meters between l and other using the haversine formula.
+func (l GeocodeLocation) DistanceTo(other GeocodeLocation) float64 {
+	lat1, lat2 := radians(l.Latitude), radians(other.Latitude)
+	dLat := lat2 - lat1
+	dLng := radians(other.Longitude - l.Longitude)
+
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dLng/2), 2)
+
+	return 2 * EarthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// WithinRadius reports whether l is at most radiusMeters away from center.
+func (l GeocodeLocation) WithinRadius(center GeocodeLocation, radiusMeters float64) bool {
+	return l.DistanceTo(center) <= radiusMeters
+}
+
+// BoundingBox returns the smallest box containing every point within radiusMeters of l. The box is useful
+// to pre-filter candidates, e.g. in a database query, before checking WithinRadius.
+func (l GeocodeLocation) BoundingBox(radiusMeters float64) BoundingBox {
+	dLat := degrees(radiusMeters / EarthRadiusMeters)
+	minLat, maxLat := l.Latitude-dLat, l.Latitude+dLat
+
+	// Near the poles every longitude is within reach.
+	if minLat <= -90 || maxLat >= 90 {
+		return BoundingBox{
+			MinLatitude:  math.Max(minLat, -90),
+			MinLongitude: -180,
+			MaxLatitude:  math.Min(maxLat, 90),
+			MaxLongitude: 180,
+		}
+	}
+
+	dLng := degrees(math.Asin(math.Min(1, math.Sin(radiusMeters/EarthRadiusMeters)/math.Cos(radians(l.Latitude)))))
+
+	return BoundingBox{
+		MinLatitude:  minLat,
+		MinLongitude: normalizeLongitude(l.Longitude - dLng),
+		MaxLatitude:  maxLat,
+		MaxLongitude: normalizeLongitude(l.Longitude + dLng),
+	}
+}
+
+// BoundingBox is an area between two latitudes and two longitudes. When MinLongitude is greater than
+// MaxLongitude the box crosses the antimeridian.
+type BoundingBox struct {
+	MinLatitude  float64
+	MinLongitude float64
+	MaxLatitude  float64
+	MaxLongitude float64
+}
+
+// NewBoundingBox returns the smallest box containing all locations. It does not consider boxes crossing
+// the antimeridian.
+func NewBoundingBox(locations ...GeocodeLocation) BoundingBox {
+	if len(locations) == 0 {
+		return BoundingBox{}
+	}
+
+	b := BoundingBox{
+		MinLatitude:  locations[0].Latitude,
+		MinLongitude: locations[0].Longitude,
+		MaxLatitude:  locations[0].Latitude,
+		MaxLongitude: locations[0].Longitude,
+	}
+	for _, l := range locations[1:] {
+		b.MinLatitude = math.Min(b.MinLatitude, l.Latitude)
+		b.MinLongitude = math.Min(b.MinLongitude, l.Longitude)
+		b.MaxLatitude = math.Max(b.MaxLatitude, l.Latitude)
+		b.MaxLongitude = math.Max(b.MaxLongitude, l.Longitude)
+	}
+
+	return b
+}
+
+// Contains reports whether l lies within the box, edges included.
+func (b BoundingBox) Contains(l GeocodeLocation) bool {
+	if l.Latitude < b.MinLatitude || l.Latitude > b.MaxLatitude {
+		return false
+	}
+	if b.MinLongitude > b.MaxLongitude {
+		return l.Longitude >= b.MinLongitude || l.Longitude <= b.MaxLongitude
+	}
+
+	return l.Longitude >= b.MinLongitude && l.Longitude <= b.MaxLongitude
+}
+
+// Center returns the midpoint of the box.
+func (b BoundingBox) Center() GeocodeLocation {
+	lng := (b.MinLongitude + b.MaxLongitude) / 2
+	if b.MinLongitude > b.MaxLongitude {
+		lng = normalizeLongitude(lng + 180)
+	}
+
+	return GeocodeLocation{
+		Latitude:  (b.MinLatitude + b.MaxLatitude) / 2,
+		Longitude: lng,
+	}
+}
+
+// GeoJSONFeature is a GeoJSON Feature as defined by RFC 7946.
+type GeoJSONFeature struct {
+	Type       string           `json:"type"`
+	Geometry   *GeoJSONGeometry `json:"geometry"`
+	Properties map[string]any   `json:"properties"`
+}
+
+// GeoJSONGeometry is a GeoJSON Point geometry. Coordinates are ordered longitude, latitude.
+type GeoJSONGeometry struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// GeoJSONFeature exports the verified address as a GeoJSON Feature with its geocoded location as a Point
+// geometry and the address fields as properties. The geometry is null when the address has no location.
+func (v VerifiedAddress) GeoJSONFeature() GeoJSONFeature {
+	f := GeoJSONFeature{
+		Type: "Feature",
+		Properties: map[string]any{
+			"line1":           v.Line1,
+			"line2":           v.Line2,
+			"city":            v.City,
+			"provinceOrState": v.ProvinceOrState,
+			"postalOrZip":     v.PostalOrZip,
+			"zipPlus4":        v.ZipPlus4,
+			"firmName":        v.FirmName,
+			"country":         v.Country,
+			"status":          v.Status,
+		},
+	}
+
+	if v.GeocodeResult.HasLocation() {
+		f.Geometry = &GeoJSONGeometry{
+			Type:        "Point",
+			Coordinates: [2]float64{v.GeocodeResult.Location.Longitude, v.GeocodeResult.Location.Latitude},
+		}
+		f.Properties["accuracy"] = v.GeocodeResult.Accuracy
+		f.Properties["accuracyType"] = v.GeocodeResult.AccuracyType
+	}
+
+	return f
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+// normalizeLongitude wraps a longitude into the range [-180, 180]. NaN and infinite longitudes are
+// returned unchanged.
+func normalizeLongitude(lng float64) float64 {
+	if lng >= -180 && lng <= 180 || math.IsNaN(lng) || math.IsInf(lng, 0) {
+		return lng
+	}
+
+	lng = math.Mod(lng+180, 360)
+	if lng < 0 {
+		lng += 360
+	}
+
+	return lng - 180
+}
@@ -0,0 +1,224 @@
+package postgrid
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	newYork = GeocodeLocation{Latitude: 40.731862, Longitude: -73.985679}
+	london  = GeocodeLocation{Latitude: 51.507351, Longitude: -0.127758}
+)
+
+func TestGeocodeLocation_DistanceTo(t *testing.T) {
+	tests := []struct {
+		name  string
+		a     GeocodeLocation
+		b     GeocodeLocation
+		want  float64
+		delta float64
+	}{
+		{
+			name: "same point",
+			a:    newYork,
+			b:    newYork,
+			want: 0,
+		},
+		{
+			name:  "new york to london",
+			a:     newYork,
+			b:     london,
+			want:  5_570_000,
+			delta: 5_000,
+		},
+		{
+			name:  "across the antimeridian",
+			a:     GeocodeLocation{Latitude: 0, Longitude: 179.5},
+			b:     GeocodeLocation{Latitude: 0, Longitude: -179.5},
+			want:  111_195,
+			delta: 10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, tt.a.DistanceTo(tt.b), tt.delta)
+			assert.InDelta(t, tt.want, tt.b.DistanceTo(tt.a), tt.delta)
+		})
+	}
+}
+
+func TestGeocodeLocation_WithinRadius(t *testing.T) {
+	nearby := GeocodeLocation{Latitude: 40.7359, Longitude: -73.9911}
+
+	assert.True(t, nearby.WithinRadius(newYork, 1000))
+	assert.False(t, nearby.WithinRadius(newYork, 500))
+	assert.False(t, london.WithinRadius(newYork, 1000))
+}
+
+func TestGeocodeLocation_BoundingBox(t *testing.T) {
+	tests := []struct {
+		name   string
+		center GeocodeLocation
+		radius float64
+	}{
+		{
+			name:   "mid latitude",
+			center: newYork,
+			radius: 10_000,
+		},
+		{
+			name:   "crossing the antimeridian",
+			center: GeocodeLocation{Latitude: -17.7134, Longitude: 179.9},
+			radius: 50_000,
+		},
+		{
+			name:   "near the pole",
+			center: GeocodeLocation{Latitude: 89.9, Longitude: 10},
+			radius: 50_000,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box := tt.center.BoundingBox(tt.radius)
+			assert.True(t, box.Contains(tt.center))
+
+			// Every point on the circle must be inside the box.
+			for bearing := 0.0; bearing < 360; bearing += 15 {
+				assert.True(t, box.Contains(destination(tt.center, bearing, tt.radius*0.999)), "bearing %v", bearing)
+			}
+			assert.False(t, box.Contains(destination(tt.center, 180, tt.radius*1.01)))
+		})
+	}
+}
+
+func TestNormalizeLongitude(t *testing.T) {
+	tests := []struct {
+		lng  float64
+		want float64
+	}{
+		{lng: 0, want: 0},
+		{lng: 180, want: 180},
+		{lng: -180, want: -180},
+		{lng: 190, want: -170},
+		{lng: -190, want: 170},
+		{lng: 540, want: -180},
+		{lng: 360*1e9 + 10, want: 10},
+		{lng: -360*1e9 - 10, want: -10},
+		{lng: math.Inf(1), want: math.Inf(1)},
+		{lng: math.Inf(-1), want: math.Inf(-1)},
+	}
+	for _, tt := range tests {
+		assert.InDelta(t, tt.want, normalizeLongitude(tt.lng), 1e-3, "normalizeLongitude(%v)", tt.lng)
+	}
+	assert.True(t, math.IsNaN(normalizeLongitude(math.NaN())))
+}
+
+func TestNewBoundingBox(t *testing.T) {
+	box := NewBoundingBox(newYork, london)
+
+	assert.Equal(t, BoundingBox{
+		MinLatitude:  newYork.Latitude,
+		MinLongitude: newYork.Longitude,
+		MaxLatitude:  london.Latitude,
+		MaxLongitude: london.Longitude,
+	}, box)
+	assert.True(t, box.Contains(GeocodeLocation{Latitude: 45, Longitude: -40}))
+	assert.False(t, box.Contains(GeocodeLocation{Latitude: 45, Longitude: 10}))
+	assert.InDelta(t, 46.12, box.Center().Latitude, 0.01)
+	assert.Equal(t, BoundingBox{}, NewBoundingBox())
+}
+
+func TestAccuracyType(t *testing.T) {
+	assert.True(t, AccuracyTypeRooftop.AtLeast(AccuracyTypeRangeInterpolation))
+	assert.False(t, AccuracyTypePostalCentroid.AtLeast(AccuracyTypeStreetCenter))
+	assert.False(t, AccuracyType("unknown").AtLeast(AccuracyTypeState))
+	assert.True(t, AccuracyTypeRangeInterpolation.IsStreetLevel())
+	assert.False(t, AccuracyTypePlace.IsStreetLevel())
+}
+
+func TestVerifiedAddress_GeoJSONFeature(t *testing.T) {
+	tests := []struct {
+		name string
+		v    VerifiedAddress
+		want string
+	}{
+		{
+			name: "with location",
+			v: VerifiedAddress{
+				Line1:           "251 E 13TH ST FRNT A",
+				City:            "NEW YORK",
+				ProvinceOrState: "NY",
+				PostalOrZip:     "10003",
+				ZipPlus4:        "5646",
+				Country:         "us",
+				Status:          "corrected",
+				GeocodeResult: GeocodeResult{
+					Location:     newYork,
+					Accuracy:     1,
+					AccuracyType: AccuracyTypeRooftop,
+				},
+			},
+			want: `{
+				"type": "Feature",
+				"geometry": {"type": "Point", "coordinates": [-73.985679, 40.731862]},
+				"properties": {
+					"line1": "251 E 13TH ST FRNT A",
+					"line2": "",
+					"city": "NEW YORK",
+					"provinceOrState": "NY",
+					"postalOrZip": "10003",
+					"zipPlus4": "5646",
+					"firmName": "",
+					"country": "us",
+					"status": "corrected",
+					"accuracy": 1,
+					"accuracyType": "rooftop"
+				}
+			}`,
+		},
+		{
+			name: "without location",
+			v: VerifiedAddress{
+				Line1:  "1 NOWHERE RD",
+				Status: "failed",
+			},
+			want: `{
+				"type": "Feature",
+				"geometry": null,
+				"properties": {
+					"line1": "1 NOWHERE RD",
+					"line2": "",
+					"city": "",
+					"provinceOrState": "",
+					"postalOrZip": "",
+					"zipPlus4": "",
+					"firmName": "",
+					"country": "",
+					"status": "failed"
+				}
+			}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.v.GeoJSONFeature())
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+		})
+	}
+}
+
+// destination returns the point reached by travelling distance meters from l along the given bearing.
+func destination(l GeocodeLocation, bearing, distance float64) GeocodeLocation {
+	lat1, lng1, theta := radians(l.Latitude), radians(l.Longitude), radians(bearing)
+	delta := distance / EarthRadiusMeters
+
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(delta) + math.Cos(lat1)*math.Sin(delta)*math.Cos(theta))
+	lng2 := lng1 + math.Atan2(math.Sin(theta)*math.Sin(delta)*math.Cos(lat1), math.Cos(delta)-math.Sin(lat1)*math.Sin(lat2))
+
+	return GeocodeLocation{Latitude: degrees(lat2), Longitude: normalizeLongitude(degrees(lng2))}
+}
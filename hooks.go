@@ -0,0 +1,56 @@
+package postgrid
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// RequestInfo describes a single attempt of an http request to the postgrid api.
+type RequestInfo struct {
+	// Endpoint is EndpointVerify or EndpointBatchVerify and Path the path of the request url, which also
+	// holds the path of the base url.
+	Endpoint string
+	Method   string
+	Path     string
+	// Attempt is the number of the attempt, starting at 1. Retry reports whether another attempt follows.
+	Attempt int
+	Retry   bool
+	// RateLimitWait is the time the attempt waited for the rate limiter and Latency the duration of the
+	// http exchange.
+	RateLimitWait time.Duration
+	Latency       time.Duration
+	// StatusCode is the http status code, or 0 when no response was received.
+	StatusCode int
+	// Message is the message of the response envelope.
+	Message string
+	Err     error
+}
+
+// RequestHook receives the RequestInfo of every attempt along with the context of the request, which
+// carries the values of the caller's context. Hooks are called synchronously and may be called
+// concurrently, so they must be fast and safe for concurrent use.
+type RequestHook func(ctx context.Context, info RequestInfo)
+
+// reportRequest sends a single attempt of an http request to the request hooks.
+func (c *Client) reportRequest(req *http.Request, ex exchange, retry bool, err error) {
+	if len(c.requestHooks) == 0 {
+		return
+	}
+
+	info := RequestInfo{
+		Endpoint:      ex.endpoint,
+		Method:        req.Method,
+		Path:          req.URL.Path,
+		Attempt:       ex.attempt,
+		Retry:         retry,
+		RateLimitWait: ex.wait,
+		Latency:       ex.latency,
+		StatusCode:    ex.status,
+		Message:       ex.message,
+		Err:           err,
+	}
+	for _, hook := range c.requestHooks {
+		hook(req.Context(), info)
+	}
+}
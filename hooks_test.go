@@ -0,0 +1,64 @@
+package postgrid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+type hookContextKey struct{}
+
+func TestClient_WithRequestHook(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"status":"error","message":"unavailable"}`))
+			return
+		}
+		writeTestResponse(t, w, VerifiedAddress{Status: VerificationStatusVerified})
+	}))
+	t.Cleanup(srv.Close)
+
+	var mu sync.Mutex
+	var infos []RequestInfo
+	var values []any
+	hook := func(ctx context.Context, info RequestInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		infos = append(infos, info)
+		values = append(values, ctx.Value(hookContextKey{}))
+	}
+	client := NewClient("", srv.URL, WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Inf, 0)),
+		WithRetry(3, time.Millisecond), WithRequestHook(hook))
+
+	ctx := context.WithValue(context.Background(), hookContextKey{}, "caller")
+	_, err := client.VerifyAddress(ctx, VerifyAddressRequest{Address: Address{Line1: "A"}})
+	require.NoError(t, err)
+
+	require.Len(t, infos, 2)
+	assert.Equal(t, []any{"caller", "caller"}, values)
+
+	assert.Equal(t, EndpointVerify, infos[0].Endpoint)
+	assert.Equal(t, http.MethodPost, infos[0].Method)
+	assert.Equal(t, "/addver/verifications", infos[0].Path)
+	assert.Equal(t, 1, infos[0].Attempt)
+	assert.True(t, infos[0].Retry)
+	assert.Equal(t, http.StatusServiceUnavailable, infos[0].StatusCode)
+	assert.Equal(t, "unavailable", infos[0].Message)
+	assert.Error(t, infos[0].Err)
+
+	assert.Equal(t, 2, infos[1].Attempt)
+	assert.False(t, infos[1].Retry)
+	assert.Equal(t, http.StatusOK, infos[1].StatusCode)
+	assert.NoError(t, infos[1].Err)
+	assert.Positive(t, infos[1].Latency)
+}
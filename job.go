@@ -0,0 +1,427 @@
+package postgrid
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// DefaultJobChunkSize is the number of addresses verified and checkpointed together by a BatchJob.
+const DefaultJobChunkSize = 500
+
+var (
+	// ErrCheckpointMismatch is returned when resuming a BatchJob from a checkpoint written for different
+	// input.
+	ErrCheckpointMismatch = errors.New("postgrid: checkpoint does not match the job input")
+	// ErrCheckpointDecrypt is returned when a line of an encrypted checkpoint cannot be decrypted, most
+	// likely because the key is wrong or the checkpoint was written without one.
+	ErrCheckpointDecrypt = errors.New("postgrid: unable to decrypt checkpoint")
+)
+
+// checkpointAdditionalData is authenticated with every line of an encrypted checkpoint.
+var checkpointAdditionalData = []byte("postgrid checkpoint")
+
+// BatchJob verifies a large list of addresses in chunks through the Batch Verify Addresses endpoint,
+// appending the results of every completed chunk to a checkpoint file. Running a job again with the same
+// checkpoint file and input resumes after the last completed chunk instead of verifying everything again.
+//
+// The checkpoint file is created with mode 0600 and holds the input and verified addresses, in plaintext
+// unless the job is configured WithCheckpointKey.
+type BatchJob struct {
+	client     *Client
+	checkpoint string
+	chunkSize  int
+	key        []byte
+	now        func() time.Time
+}
+
+// BatchJobResult is the verification result of a single address of a BatchJob.
+type BatchJobResult struct {
+	// Index is the position of the address in the job input.
+	Index           int             `json:"index"`
+	Input           Address         `json:"input"`
+	VerifiedAddress VerifiedAddress `json:"verifiedAddress"`
+}
+
+// BatchJobSummary summarizes a BatchJob, including the chunks restored from the checkpoint.
+type BatchJobSummary struct {
+	Total int
+	// Processed is the number of addresses with a result, including Resumed.
+	Processed int
+	// Resumed is the number of addresses restored from the checkpoint rather than verified by this run.
+	Resumed int
+	// StatusCounts counts the results by VerifiedAddress.Status.
+	StatusCounts map[string]int
+	// Failures holds the results with VerificationStatusFailed.
+	Failures []BatchJobResult
+	// Elapsed is the wall time of this run.
+	Elapsed time.Duration
+}
+
+type jobOptions struct {
+	chunkSize int
+	key       []byte
+}
+
+// BatchJobOption represents optional arguments for constructing a BatchJob.
+type BatchJobOption interface {
+	apply(*jobOptions)
+}
+
+type jobChunkSizeOption struct {
+	size int
+}
+
+func (j jobChunkSizeOption) apply(opts *jobOptions) {
+	opts.chunkSize = j.size
+}
+
+// WithJobChunkSize configures the number of addresses verified and checkpointed together. It is capped at
+// MaxBatchSize. Resuming requires the same chunk size the checkpoint was written with.
+func WithJobChunkSize(size int) BatchJobOption {
+	return jobChunkSizeOption{size: size}
+}
+
+type checkpointKeyOption []byte
+
+func (k checkpointKeyOption) apply(opts *jobOptions) {
+	opts.key = k
+}
+
+// WithCheckpointKey encrypts every line of the checkpoint file with AES-GCM. key must be 16, 24 or 32 bytes
+// to select AES-128, AES-192 or AES-256. Resuming and reading the results require the same key.
+func WithCheckpointKey(key []byte) BatchJobOption {
+	return checkpointKeyOption(key)
+}
+
+// checkpointHeader is the first line of a checkpoint file and identifies the job input.
+type checkpointHeader struct {
+	Total     int    `json:"total"`
+	ChunkSize int    `json:"chunkSize"`
+	InputHash string `json:"inputHash"`
+}
+
+// checkpointChunk is a line of a checkpoint file holding the results of a completed chunk.
+type checkpointChunk struct {
+	Chunk   int                `json:"chunk"`
+	Results []checkpointResult `json:"results"`
+}
+
+// checkpointResult is a BatchJobResult in a checkpoint file.
+type checkpointResult struct {
+	Index           int               `json:"index"`
+	Input           checkpointAddress `json:"input"`
+	VerifiedAddress VerifiedAddress   `json:"verifiedAddress"`
+}
+
+// checkpointAddress is an Address in a checkpoint file, always an object with the freeform String as a
+// field.
+type checkpointAddress struct {
+	addressFields
+	String string `json:"string,omitempty"`
+}
+
+// addressFields encodes the structured fields of an Address.
+type addressFields Address
+
+func newCheckpointResult(r BatchJobResult) checkpointResult {
+	return checkpointResult{
+		Index:           r.Index,
+		Input:           checkpointAddress{addressFields: addressFields(r.Input), String: r.Input.String},
+		VerifiedAddress: r.VerifiedAddress,
+	}
+}
+
+func (r checkpointResult) result() BatchJobResult {
+	input := Address(r.Input.addressFields)
+	input.String = r.Input.String
+
+	return BatchJobResult{Index: r.Index, Input: input, VerifiedAddress: r.VerifiedAddress}
+}
+
+// NewBatchJob constructs a BatchJob verifying through the given client and checkpointing to the file at
+// checkpointPath.
+func NewBatchJob(client *Client, checkpointPath string, opts ...BatchJobOption) *BatchJob {
+	options := jobOptions{
+		chunkSize: DefaultJobChunkSize,
+	}
+
+	for _, opt := range opts {
+		opt.apply(&options)
+	}
+
+	return &BatchJob{
+		client:     client,
+		checkpoint: checkpointPath,
+		chunkSize:  min(max(options.chunkSize, 1), MaxBatchSize),
+		key:        options.key,
+		now:        time.Now,
+	}
+}
+
+// Run verifies the addresses, resuming from the checkpoint file when it exists. A chunk that fails to
+// verify stops the job and returns the error along with the summary so far; running the job again retries
+// from that chunk.
+func (j *BatchJob) Run(ctx context.Context, addresses []Address) (BatchJobSummary, error) {
+	start := j.now()
+	summary := BatchJobSummary{
+		Total:        len(addresses),
+		StatusCounts: map[string]int{},
+	}
+
+	header := checkpointHeader{
+		Total:     len(addresses),
+		ChunkSize: j.chunkSize,
+		InputHash: hashAddresses(addresses),
+	}
+	aead, err := j.cipher()
+	if err != nil {
+		return summary, err
+	}
+	f, next, err := j.openCheckpoint(aead, header, &summary)
+	if err != nil {
+		return summary, err
+	}
+	defer f.Close()
+	summary.Resumed = summary.Processed
+
+	progress := &bulkProgress{chunk: next, completed: summary.Processed, total: len(addresses)}
+	for chunk := next; chunk*j.chunkSize < len(addresses); chunk++ {
+		lo := chunk * j.chunkSize
+		hi := min(lo+j.chunkSize, len(addresses))
+
+		resp, err := j.client.verifyChunk(ctx, progress, BatchVerifyAddressesRequest{Addresses: addresses[lo:hi]})
+		if err == nil && len(resp.Results) != hi-lo {
+			err = fmt.Errorf("postgrid error: expected %d batch results, received %d", hi-lo, len(resp.Results))
+		}
+		if err != nil {
+			summary.Elapsed = j.now().Sub(start)
+			return summary, fmt.Errorf("postgrid: batch job chunk %d: %w", chunk, err)
+		}
+
+		results := make([]BatchJobResult, hi-lo)
+		record := checkpointChunk{Chunk: chunk, Results: make([]checkpointResult, hi-lo)}
+		for i, result := range resp.Results {
+			results[i] = BatchJobResult{Index: lo + i, Input: addresses[lo+i], VerifiedAddress: result.VerifiedAddress}
+			record.Results[i] = newCheckpointResult(results[i])
+		}
+		if err := writeCheckpointLine(f, aead, record); err != nil {
+			summary.Elapsed = j.now().Sub(start)
+			return summary, err
+		}
+		for _, result := range results {
+			summary.add(result)
+		}
+	}
+
+	summary.Elapsed = j.now().Sub(start)
+
+	return summary, nil
+}
+
+// Results calls fn with every result in the checkpoint file, in input order, stopping at the first error.
+func (j *BatchJob) Results(fn func(BatchJobResult) error) error {
+	aead, err := j.cipher()
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(j.checkpoint)
+	if err != nil {
+		return fmt.Errorf("postgrid: %w", err)
+	}
+	defer f.Close()
+
+	var header checkpointHeader
+	_, err = readCheckpoint(f, aead, &header, func(record checkpointChunk) error {
+		for _, result := range record.Results {
+			if err := fn(result.result()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return err
+}
+
+// openCheckpoint opens or creates the checkpoint file, adding the completed chunks to summary, and returns
+// the file positioned for appending along with the next chunk to verify.
+func (j *BatchJob) openCheckpoint(aead cipher.AEAD, header checkpointHeader, summary *BatchJobSummary) (*os.File, int, error) {
+	f, err := os.OpenFile(j.checkpoint, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, 0, fmt.Errorf("postgrid: %w", err)
+	}
+
+	var (
+		existing checkpointHeader
+		resumed  []checkpointChunk
+	)
+	size, err := readCheckpoint(f, aead, &existing, func(record checkpointChunk) error {
+		if record.Chunk != len(resumed) {
+			return fmt.Errorf("postgrid: checkpoint chunk %d out of order", record.Chunk)
+		}
+		resumed = append(resumed, record)
+		return nil
+	})
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+
+	// The chunks of a checkpoint for other addresses do not count towards the summary.
+	if size > 0 && existing != header {
+		f.Close()
+		return nil, 0, ErrCheckpointMismatch
+	}
+	for _, record := range resumed {
+		for _, result := range record.Results {
+			summary.add(result.result())
+		}
+	}
+
+	// Drop a partially written trailing line.
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return nil, 0, fmt.Errorf("postgrid: %w", err)
+	}
+	if _, err := f.Seek(size, io.SeekStart); err != nil {
+		f.Close()
+		return nil, 0, fmt.Errorf("postgrid: %w", err)
+	}
+
+	if size == 0 {
+		if err := writeCheckpointLine(f, aead, header); err != nil {
+			f.Close()
+			return nil, 0, err
+		}
+	}
+
+	return f, len(resumed), nil
+}
+
+// cipher returns the AEAD encrypting the checkpoint, or nil when the job has no key.
+func (j *BatchJob) cipher() (cipher.AEAD, error) {
+	if j.key == nil {
+		return nil, nil
+	}
+	block, err := aes.NewCipher(j.key)
+	if err != nil {
+		return nil, fmt.Errorf("postgrid: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("postgrid: %w", err)
+	}
+
+	return aead, nil
+}
+
+// readCheckpoint reads the first line into header and calls fn with every complete chunk, decrypting the
+// lines with aead unless it is nil. It returns the offset after the last complete line.
+func readCheckpoint(r io.Reader, aead cipher.AEAD, header *checkpointHeader, fn func(checkpointChunk) error) (int64, error) {
+	br := bufio.NewReader(r)
+	var offset int64
+	for first := true; ; first = false {
+		line, err := br.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// A line without its newline was cut short by a crash.
+			return offset, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("postgrid: %w", err)
+		}
+		plaintext, err := openCheckpointLine(aead, line)
+		if err != nil {
+			return 0, err
+		}
+
+		if first {
+			if err := json.Unmarshal(plaintext, header); err != nil {
+				return 0, fmt.Errorf("postgrid: invalid checkpoint header: %w", err)
+			}
+		} else {
+			var record checkpointChunk
+			if err := json.Unmarshal(plaintext, &record); err != nil {
+				return 0, fmt.Errorf("postgrid: invalid checkpoint chunk: %w", err)
+			}
+			if err := fn(record); err != nil {
+				return 0, err
+			}
+		}
+		offset += int64(len(line))
+	}
+}
+
+// writeCheckpointLine appends v as a JSON line, encrypted and base64 encoded unless aead is nil, and flushes
+// it to stable storage.
+func writeCheckpointLine(f *os.File, aead cipher.AEAD, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("postgrid: %w", err)
+	}
+	if aead != nil {
+		nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(b)+aead.Overhead())
+		if _, err := rand.Read(nonce); err != nil {
+			return fmt.Errorf("postgrid: %w", err)
+		}
+		b = []byte(base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, b, checkpointAdditionalData)))
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("postgrid: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("postgrid: %w", err)
+	}
+
+	return nil
+}
+
+// openCheckpointLine returns the JSON of a line written by writeCheckpointLine.
+func openCheckpointLine(aead cipher.AEAD, line []byte) ([]byte, error) {
+	if aead == nil {
+		return line, nil
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(string(bytes.TrimSuffix(line, []byte("\n"))))
+	if err != nil || len(ciphertext) < aead.NonceSize() {
+		return nil, ErrCheckpointDecrypt
+	}
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, sealed, checkpointAdditionalData)
+	if err != nil {
+		return nil, ErrCheckpointDecrypt
+	}
+
+	return plaintext, nil
+}
+
+func (s *BatchJobSummary) add(result BatchJobResult) {
+	s.Processed++
+	s.StatusCounts[result.VerifiedAddress.Status]++
+	if result.VerifiedAddress.Status == VerificationStatusFailed {
+		s.Failures = append(s.Failures, result)
+	}
+}
+
+func hashAddresses(addresses []Address) string {
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	for _, a := range addresses {
+		// Encoding an Address cannot fail.
+		_ = enc.Encode(a)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
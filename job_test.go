@@ -0,0 +1,221 @@
+package postgrid
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+// newJobTestServer returns a server failing the verification of addresses with Line1 "X" and failing the
+// request numbered failCall, counting every request made.
+func newJobTestServer(tb testing.TB, failCall int32) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == failCall {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"status":"error","message":"boom"}`))
+			return
+		}
+
+		var req BatchVerifyAddressesRequest
+		require.NoError(tb, json.NewDecoder(r.Body).Decode(&req))
+
+		var resp BatchVerifyAddressesResponse
+		for _, a := range req.Addresses {
+			status := VerificationStatusVerified
+			if a.Line1 == "X" {
+				status = VerificationStatusFailed
+			}
+			resp.Results = append(resp.Results, VerifiedAddressResponse{VerifiedAddress: VerifiedAddress{Line1: a.Line1, Status: status}})
+		}
+		writeTestResponse(tb, w, resp)
+	}))
+	tb.Cleanup(srv.Close)
+
+	return srv, &calls
+}
+
+func TestBatchJob_Run(t *testing.T) {
+	addresses := []Address{{Line1: "A"}, {Line1: "X"}, {Line1: "C"}, {Line1: "D"}, {Line1: "E"}}
+	path := filepath.Join(t.TempDir(), "job.checkpoint")
+
+	// The second chunk fails, stopping the job.
+	srv, calls := newJobTestServer(t, 2)
+	client := NewClient("", srv.URL, WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Inf, 0)))
+	job := NewBatchJob(client, path, WithJobChunkSize(2))
+
+	summary, err := job.Run(context.Background(), addresses)
+	assert.ErrorContains(t, err, "chunk 1")
+	assert.Equal(t, 2, summary.Processed)
+	assert.Equal(t, int32(2), calls.Load())
+
+	// Resuming verifies only the remaining chunks.
+	summary, err = job.Run(context.Background(), addresses)
+	require.NoError(t, err)
+	assert.Equal(t, int32(4), calls.Load())
+	assert.Equal(t, 5, summary.Total)
+	assert.Equal(t, 5, summary.Processed)
+	assert.Equal(t, 2, summary.Resumed)
+	assert.Equal(t, map[string]int{VerificationStatusVerified: 4, VerificationStatusFailed: 1}, summary.StatusCounts)
+	require.Len(t, summary.Failures, 1)
+	assert.Equal(t, 1, summary.Failures[0].Index)
+
+	// A completed job makes no further calls.
+	summary, err = job.Run(context.Background(), addresses)
+	require.NoError(t, err)
+	assert.Equal(t, int32(4), calls.Load())
+	assert.Equal(t, 5, summary.Resumed)
+
+	var got []string
+	require.NoError(t, job.Results(func(r BatchJobResult) error {
+		assert.Equal(t, addresses[r.Index], r.Input)
+		got = append(got, r.VerifiedAddress.Line1)
+		return nil
+	}))
+	assert.Equal(t, []string{"A", "X", "C", "D", "E"}, got)
+}
+
+func TestBatchJob_Checkpoint(t *testing.T) {
+	addresses := []Address{{Line1: "A"}, {Line1: "B"}, {Line1: "C"}}
+
+	tests := []struct {
+		name      string
+		corrupt   func(t *testing.T, path string)
+		input     []Address
+		chunkSize int
+		wantCalls int32
+		wantErr   error
+	}{
+		{
+			name: "truncated trailing line is verified again",
+			corrupt: func(t *testing.T, path string) {
+				b, err := os.ReadFile(path)
+				require.NoError(t, err)
+				require.NoError(t, os.WriteFile(path, b[:len(b)-5], 0o600))
+			},
+			input:     addresses,
+			chunkSize: 2,
+			wantCalls: 1,
+		},
+		{
+			name:      "different input",
+			input:     addresses[:2],
+			chunkSize: 2,
+			wantErr:   ErrCheckpointMismatch,
+		},
+		{
+			name:      "different chunk size",
+			input:     addresses,
+			chunkSize: 1,
+			wantErr:   ErrCheckpointMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "job.checkpoint")
+			srv, calls := newJobTestServer(t, 0)
+			client := NewClient("", srv.URL, WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Inf, 0)))
+
+			_, err := NewBatchJob(client, path, WithJobChunkSize(2)).Run(context.Background(), addresses)
+			require.NoError(t, err)
+			if tt.corrupt != nil {
+				tt.corrupt(t, path)
+			}
+			calls.Store(0)
+
+			summary, err := NewBatchJob(client, path, WithJobChunkSize(tt.chunkSize)).Run(context.Background(), tt.input)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				// Nothing is resumed from the checkpoint of other addresses.
+				assert.Zero(t, summary.Processed)
+				assert.Zero(t, summary.Resumed)
+				assert.Empty(t, summary.StatusCounts)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCalls, calls.Load())
+			assert.Equal(t, len(tt.input), summary.Processed)
+
+			var n int
+			require.NoError(t, NewBatchJob(client, path).Results(func(r BatchJobResult) error {
+				assert.Equal(t, n, r.Index, fmt.Sprint(r))
+				n++
+				return nil
+			}))
+			assert.Equal(t, len(tt.input), n)
+		})
+	}
+}
+
+func TestBatchJob_ResumeFreeform(t *testing.T) {
+	addresses := []Address{{String: "1 a st, toronto on"}, {Line1: "B"}, {String: "3 c st, toronto on"}}
+	path := filepath.Join(t.TempDir(), "job.checkpoint")
+
+	srv, calls := newJobTestServer(t, 2)
+	client := NewClient("", srv.URL, WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Inf, 0)))
+	job := NewBatchJob(client, path, WithJobChunkSize(2))
+
+	_, err := job.Run(context.Background(), addresses)
+	assert.ErrorContains(t, err, "chunk 1")
+
+	// The chunk holding a freeform address is restored from the checkpoint.
+	summary, err := job.Run(context.Background(), addresses)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), calls.Load())
+	assert.Equal(t, 2, summary.Resumed)
+
+	var got []Address
+	require.NoError(t, job.Results(func(r BatchJobResult) error {
+		got = append(got, r.Input)
+		return nil
+	}))
+	assert.Equal(t, addresses, got)
+}
+
+func TestBatchJob_CheckpointKey(t *testing.T) {
+	addresses := []Address{{Line1: "1 secret st"}, {String: "2 secret st, toronto on"}, {Line1: "C"}}
+	path := filepath.Join(t.TempDir(), "job.checkpoint")
+	key := bytes.Repeat([]byte{0x42}, 32)
+
+	srv, calls := newJobTestServer(t, 0)
+	client := NewClient("", srv.URL, WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Inf, 0)))
+	_, err := NewBatchJob(client, path, WithJobChunkSize(2), WithCheckpointKey(key)).Run(context.Background(), addresses)
+	require.NoError(t, err)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "secret")
+
+	summary, err := NewBatchJob(client, path, WithJobChunkSize(2), WithCheckpointKey(key)).Run(context.Background(), addresses)
+	require.NoError(t, err)
+	assert.Equal(t, 3, summary.Resumed)
+	assert.Equal(t, int32(2), calls.Load())
+
+	var got []Address
+	require.NoError(t, NewBatchJob(client, path, WithCheckpointKey(key)).Results(func(r BatchJobResult) error {
+		got = append(got, r.Input)
+		return nil
+	}))
+	assert.Equal(t, addresses, got)
+
+	_, err = NewBatchJob(client, path, WithJobChunkSize(2), WithCheckpointKey(bytes.Repeat([]byte{0x43}, 32))).Run(context.Background(), addresses)
+	assert.ErrorIs(t, err, ErrCheckpointDecrypt)
+	_, err = NewBatchJob(client, path, WithJobChunkSize(2)).Run(context.Background(), addresses)
+	assert.Error(t, err)
+	_, err = NewBatchJob(client, path, WithCheckpointKey([]byte("short"))).Run(context.Background(), addresses)
+	assert.Error(t, err)
+}
@@ -0,0 +1,146 @@
+// Package jsonl reads addresses from and writes verification results to JSON Lines streams.
+package jsonl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	postgrid "github.com/bloomcredit/bloomcredit-postgrid-sdk"
+)
+
+// MaxLineSize is the longest line a Reader accepts.
+const MaxLineSize = 1 << 20
+
+// Reader decodes one postgrid.Address per line, either as an object with the structured fields or as a
+// string holding the freeform address, the two forms produced by postgrid.Address.MarshalJSON. Blank lines
+// are skipped.
+type Reader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewReader returns a Reader reading from r.
+func NewReader(r io.Reader) *Reader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), MaxLineSize)
+
+	return &Reader{scanner: scanner}
+}
+
+// Read returns the next address, or io.EOF when there are no more.
+func (r *Reader) Read() (postgrid.Address, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := bytes.TrimSpace(r.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var a postgrid.Address
+		if err := json.Unmarshal(line, &a); err != nil {
+			return postgrid.Address{}, fmt.Errorf("jsonl: line %d: %w", r.line, err)
+		}
+		return a, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return postgrid.Address{}, fmt.Errorf("jsonl: line %d: %w", r.line+1, err)
+	}
+
+	return postgrid.Address{}, io.EOF
+}
+
+// ReadAll returns the remaining addresses, e.g. as the input of a postgrid.BatchJob.
+func (r *Reader) ReadAll() ([]postgrid.Address, error) {
+	var addresses []postgrid.Address
+	for {
+		a, err := r.Read()
+		if err == io.EOF {
+			return addresses, nil
+		}
+		if err != nil {
+			return addresses, err
+		}
+		addresses = append(addresses, a)
+	}
+}
+
+// Send sends the remaining addresses to ch, e.g. as the input of postgrid.Client.StreamVerifyAddresses,
+// and closes ch once they are all sent, reading fails or ctx is done.
+func (r *Reader) Send(ctx context.Context, ch chan<- postgrid.Address) error {
+	defer close(ch)
+
+	for {
+		a, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		select {
+		case ch <- a:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Result is a single line written by a Writer.
+type Result struct {
+	InputID         string                    `json:"inputID,omitempty"`
+	Input           postgrid.Address          `json:"input"`
+	Status          string                    `json:"status,omitempty"`
+	VerifiedAddress *postgrid.VerifiedAddress `json:"verifiedAddress,omitempty"`
+	Error           string                    `json:"error,omitempty"`
+}
+
+// Writer encodes one Result per line.
+type Writer struct {
+	enc *json.Encoder
+}
+
+// NewWriter returns a Writer writing to w. Every result is written to w with a single call, so wrap w in a
+// bufio.Writer when writing to a file.
+func NewWriter(w io.Writer) *Writer {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	return &Writer{enc: enc}
+}
+
+// Write writes a single result.
+func (w *Writer) Write(res Result) error {
+	if err := w.enc.Encode(res); err != nil {
+		return fmt.Errorf("jsonl: %w", err)
+	}
+
+	return nil
+}
+
+// WriteVerified writes the result of verifying input, which failed when err is not nil.
+func (w *Writer) WriteVerified(input postgrid.Address, v postgrid.VerifiedAddress, err error) error {
+	res := Result{InputID: input.InputID, Input: input}
+	if err != nil {
+		res.Error = err.Error()
+	} else {
+		res.Status = v.Status
+		res.VerifiedAddress = &v
+	}
+
+	return w.Write(res)
+}
+
+// WriteStreamResult writes a result of postgrid.Client.StreamVerifyAddresses.
+func (w *Writer) WriteStreamResult(res postgrid.StreamResult) error {
+	return w.WriteVerified(res.Input, res.VerifiedAddress, res.Err)
+}
+
+// WriteBatchJobResult writes a result of postgrid.BatchJob.
+func (w *Writer) WriteBatchJobResult(res postgrid.BatchJobResult) error {
+	return w.WriteVerified(res.Input, res.VerifiedAddress, nil)
+}
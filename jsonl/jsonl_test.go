@@ -0,0 +1,106 @@
+package jsonl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	postgrid "github.com/bloomcredit/bloomcredit-postgrid-sdk"
+)
+
+func TestReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []postgrid.Address
+		wantErr string
+	}{
+		{
+			name:  "object and string forms",
+			input: "{\"line1\":\"1 main st\",\"city\":\"brooklyn\",\"inputID\":\"a\"}\n\n\"1 main st, brooklyn ny\"\n",
+			want: []postgrid.Address{
+				{Line1: "1 main st", City: "brooklyn", InputID: "a"},
+				{String: "1 main st, brooklyn ny"},
+			},
+		},
+		{
+			name:  "no trailing newline",
+			input: `"a"`,
+			want:  []postgrid.Address{{String: "a"}},
+		},
+		{
+			name:    "invalid line",
+			input:   "\"a\"\n[1]\n",
+			want:    []postgrid.Address{{String: "a"}},
+			wantErr: "jsonl: line 2",
+		},
+		{
+			name:    "line too long",
+			input:   `"` + strings.Repeat("a", MaxLineSize) + `"`,
+			wantErr: "token too long",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewReader(strings.NewReader(tt.input)).ReadAll()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestReader_Send(t *testing.T) {
+	r := NewReader(strings.NewReader("\"a\"\n\"b\"\n"))
+	ch := make(chan postgrid.Address)
+	errc := make(chan error, 1)
+	go func() { errc <- r.Send(context.Background(), ch) }()
+
+	var got []string
+	for a := range ch {
+		got = append(got, a.String)
+	}
+	require.NoError(t, <-errc)
+	assert.Equal(t, []string{"a", "b"}, got)
+
+	_, err := r.Read()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+
+	require.NoError(t, w.WriteStreamResult(postgrid.StreamResult{
+		Input:           postgrid.Address{Line1: "1 main st", InputID: "a"},
+		VerifiedAddress: postgrid.VerifiedAddress{Line1: "1 MAIN ST", Status: postgrid.VerificationStatusVerified},
+	}))
+	require.NoError(t, w.WriteStreamResult(postgrid.StreamResult{
+		Input: postgrid.Address{String: "1 main st & co"},
+		Err:   errors.New("boom"),
+	}))
+	require.NoError(t, w.WriteBatchJobResult(postgrid.BatchJobResult{
+		Input:           postgrid.Address{String: "x"},
+		VerifiedAddress: postgrid.VerifiedAddress{Status: postgrid.VerificationStatusFailed},
+	}))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[0], `"inputID":"a","input":{"line1":"1 main st","inputID":"a"},"status":"verified","verifiedAddress":{"line1":"1 MAIN ST"`)
+	assert.Equal(t, `{"input":"1 main st & co","error":"boom"}`, lines[1])
+	assert.Contains(t, lines[2], `{"input":"x","status":"failed",`)
+
+	// The results can be read back as input.
+	got, err := NewReader(strings.NewReader(`{"line1":"1 main st","inputID":"a"}`)).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, []postgrid.Address{{Line1: "1 main st", InputID: "a"}}, got)
+}
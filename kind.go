@@ -0,0 +1,110 @@
+package postgrid
+
+import (
+	"regexp"
+	"strings"
+)
+
+// AddressKind classifies the type of delivery point an address refers to.
+type AddressKind string
+
+// All possible values for AddressKind.
+const (
+	AddressKindUnknown         AddressKind = "unknown"
+	AddressKindStreet          AddressKind = "street"
+	AddressKindPOBox           AddressKind = "po_box"
+	AddressKindMilitary        AddressKind = "military"
+	AddressKindRuralRoute      AddressKind = "rural_route"
+	AddressKindGeneralDelivery AddressKind = "general_delivery"
+	AddressKindFirm            AddressKind = "firm"
+)
+
+var (
+	militaryLineRegex    = regexp.MustCompile(`(?i)(^|,)\s*((PSC|CMR)\s*#?\s*\d+|UNIT\s*#?\s*\d+\s*,?\s*BOX\s*#?\s*\d+|(USS|USNS)\s+\w+)`)
+	militaryCityRegex    = regexp.MustCompile(`(?i)^\s*[AFD]PO\s*$`)
+	militaryStringRegex  = regexp.MustCompile(`(?i)\b[AFD]PO\b[\s,]+A[AEP]\b`)
+	generalDeliveryRegex = regexp.MustCompile(`(?i)(^|,)\s*(GENERAL\s+DELIVERY|POSTE\s+RESTANTE|GD)\b`)
+	ruralRouteRegex      = regexp.MustCompile(`(?i)(^|,)\s*(R\.?\s*R\.?|RURAL\s+ROUTE|RFD|HCR?|HIGHWAY\s+CONTRACT(\s+ROUTE)?|STAR\s+ROUTE)\s*#?\s*\d`)
+	poBoxRegex           = regexp.MustCompile(`(?i)(^|,)\s*(P\.?\s*O\.?\s*BOX|POST\s+OFFICE\s+BOX|POB|BOX|C\.?\s*P\.?|CASE\s+POSTALE)\s*#?\s*\d`)
+	militaryStates       = map[string]bool{"AA": true, "AE": true, "AP": true}
+)
+
+// Kind classifies the verified address using the delivery details returned by postgrid, falling back to
+// the address lines when the details are not populated.
+func (v VerifiedAddress) Kind() AddressKind {
+	d := v.Details
+	switch {
+	case isMilitary(v.City, v.ProvinceOrState) || militaryLineRegex.MatchString(v.Line1):
+		return AddressKindMilitary
+	case d.RuralRouteNumber != "" || d.RuralRouteType != "":
+		return AddressKindRuralRoute
+	case d.BoxID != "":
+		return AddressKindPOBox
+	case d.DeliveryInstallationType != "" && d.StreetNumber == "":
+		// Mail held at a delivery installation, such as a Canadian STN, RPO or SUCC, without a box, route or
+		// civic number is general delivery.
+		return AddressKindGeneralDelivery
+	case generalDeliveryRegex.MatchString(v.Line1):
+		return AddressKindGeneralDelivery
+	case ruralRouteRegex.MatchString(v.Line1):
+		return AddressKindRuralRoute
+	case poBoxRegex.MatchString(v.Line1):
+		return AddressKindPOBox
+	case v.FirmName != "":
+		return AddressKindFirm
+	case v.Line1 != "":
+		return AddressKindStreet
+	}
+
+	return AddressKindUnknown
+}
+
+// Kind classifies an unverified address from its lines, or from its string representation when String is
+// set. Firms cannot be recognised before verification, so they are reported as AddressKindStreet.
+func (a Address) Kind() AddressKind {
+	if a.String != "" {
+		return classifyString(a.String)
+	}
+
+	lines := a.Line1
+	if a.Line2 != "" {
+		lines += ", " + a.Line2
+	}
+	switch {
+	case isMilitary(a.City, a.ProvinceOrState) || militaryLineRegex.MatchString(lines):
+		return AddressKindMilitary
+	case generalDeliveryRegex.MatchString(lines):
+		return AddressKindGeneralDelivery
+	case ruralRouteRegex.MatchString(lines):
+		return AddressKindRuralRoute
+	case poBoxRegex.MatchString(lines):
+		return AddressKindPOBox
+	case strings.TrimSpace(lines) != "":
+		return AddressKindStreet
+	}
+
+	return AddressKindUnknown
+}
+
+func classifyString(s string) AddressKind {
+	switch {
+	case militaryStringRegex.MatchString(s) || militaryLineRegex.MatchString(s):
+		return AddressKindMilitary
+	case generalDeliveryRegex.MatchString(s):
+		return AddressKindGeneralDelivery
+	case ruralRouteRegex.MatchString(s):
+		return AddressKindRuralRoute
+	case poBoxRegex.MatchString(s):
+		return AddressKindPOBox
+	case strings.TrimSpace(s) != "":
+		return AddressKindStreet
+	}
+
+	return AddressKindUnknown
+}
+
+// isMilitary reports whether the city is an APO, FPO or DPO, or the state is one of the armed forces
+// regions AA, AE or AP.
+func isMilitary(city, state string) bool {
+	return militaryCityRegex.MatchString(city) || militaryStates[strings.ToUpper(strings.TrimSpace(state))]
+}
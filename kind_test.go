@@ -0,0 +1,185 @@
+package postgrid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifiedAddress_Kind(t *testing.T) {
+	tests := []struct {
+		name string
+		v    VerifiedAddress
+		want AddressKind
+	}{
+		{
+			name: "street",
+			v:    VerifiedAddress{Line1: "251 E 13TH ST FRNT A", City: "NEW YORK", ProvinceOrState: "NY"},
+			want: AddressKindStreet,
+		},
+		{
+			name: "firm",
+			v:    VerifiedAddress{Line1: "251 E 13TH ST FRNT A", FirmName: "MILK BAR"},
+			want: AddressKindFirm,
+		},
+		{
+			name: "po box from details",
+			v:    VerifiedAddress{Line1: "PO BOX 4567", Details: VerifiedAddressDetails{BoxID: "4567"}},
+			want: AddressKindPOBox,
+		},
+		{
+			name: "po box from line",
+			v:    VerifiedAddress{Line1: "P.O. Box 4567"},
+			want: AddressKindPOBox,
+		},
+		{
+			name: "firm with po box",
+			v:    VerifiedAddress{Line1: "PO BOX 4567", FirmName: "ACME", Details: VerifiedAddressDetails{BoxID: "4567"}},
+			want: AddressKindPOBox,
+		},
+		{
+			name: "rural route from details",
+			v:    VerifiedAddress{Line1: "RR 2 BOX 15", Details: VerifiedAddressDetails{RuralRouteNumber: "2", RuralRouteType: "RR", BoxID: "15"}},
+			want: AddressKindRuralRoute,
+		},
+		{
+			name: "highway contract from line",
+			v:    VerifiedAddress{Line1: "HC 1 BOX 50"},
+			want: AddressKindRuralRoute,
+		},
+		{
+			name: "military by state",
+			v:    VerifiedAddress{Line1: "PSC 1234 BOX 5678", City: "APO", ProvinceOrState: "AE", Details: VerifiedAddressDetails{BoxID: "5678"}},
+			want: AddressKindMilitary,
+		},
+		{
+			name: "military by line",
+			v:    VerifiedAddress{Line1: "UNIT 2050 BOX 4190"},
+			want: AddressKindMilitary,
+		},
+		{
+			name: "general delivery",
+			v:    VerifiedAddress{Line1: "GD STN MAIN", City: "WHITEHORSE", ProvinceOrState: "YT"},
+			want: AddressKindGeneralDelivery,
+		},
+		{
+			name: "poste restante from delivery installation",
+			v: VerifiedAddress{Line1: "PR SUCC CENTRE-VILLE", City: "MONTREAL", ProvinceOrState: "QC", Details: VerifiedAddressDetails{
+				DeliveryInstallationType: "SUCC", DeliveryInstallationAreaName: "CENTRE-VILLE",
+			}},
+			want: AddressKindGeneralDelivery,
+		},
+		{
+			name: "general delivery from delivery installation",
+			v: VerifiedAddress{Line1: "STN MAIN", City: "WHITEHORSE", ProvinceOrState: "YT", Details: VerifiedAddressDetails{
+				DeliveryInstallationType: "STN", DeliveryInstallationAreaName: "MAIN",
+			}},
+			want: AddressKindGeneralDelivery,
+		},
+		{
+			name: "casier at delivery installation",
+			v: VerifiedAddress{Line1: "CASIER 45 SUCC A", City: "SHERBROOKE", ProvinceOrState: "QC", Details: VerifiedAddressDetails{
+				BoxID: "45", DeliveryInstallationType: "SUCC", DeliveryInstallationAreaName: "A",
+			}},
+			want: AddressKindPOBox,
+		},
+		{
+			name: "suburban service at delivery installation",
+			v: VerifiedAddress{Line1: "SS 1 SITE 5 COMP 10", City: "RED DEER", ProvinceOrState: "AB", Details: VerifiedAddressDetails{
+				RuralRouteType: "SS", RuralRouteNumber: "1", DeliveryInstallationType: "STN", DeliveryInstallationAreaName: "MAIN",
+			}},
+			want: AddressKindRuralRoute,
+		},
+		{
+			name: "civic address with delivery installation",
+			v: VerifiedAddress{Line1: "100 RUE PRINCIPALE", City: "GRANBY", ProvinceOrState: "QC", Details: VerifiedAddressDetails{
+				StreetNumber: "100", StreetName: "PRINCIPALE", DeliveryInstallationType: "SUCC",
+			}},
+			want: AddressKindStreet,
+		},
+		{
+			name: "unknown",
+			v:    VerifiedAddress{},
+			want: AddressKindUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.v.Kind())
+		})
+	}
+}
+
+func TestAddress_Kind(t *testing.T) {
+	tests := []struct {
+		name string
+		a    Address
+		want AddressKind
+	}{
+		{
+			name: "street",
+			a:    Address{Line1: "123 Main St", Line2: "Apt 4", City: "Springfield", ProvinceOrState: "IL"},
+			want: AddressKindStreet,
+		},
+		{
+			name: "street containing box word",
+			a:    Address{Line1: "123 Boxwood Ln"},
+			want: AddressKindStreet,
+		},
+		{
+			name: "po box in line2",
+			a:    Address{Line1: "Acme Corp", Line2: "PO Box 12"},
+			want: AddressKindPOBox,
+		},
+		{
+			name: "canadian case postale",
+			a:    Address{Line1: "CP 123 Succ Centre-Ville", City: "Montreal", ProvinceOrState: "QC"},
+			want: AddressKindPOBox,
+		},
+		{
+			name: "rural route",
+			a:    Address{Line1: "Rural Route 3", City: "Fargo", ProvinceOrState: "ND"},
+			want: AddressKindRuralRoute,
+		},
+		{
+			name: "military city",
+			a:    Address{Line1: "USS Nimitz", City: "FPO", ProvinceOrState: "AP"},
+			want: AddressKindMilitary,
+		},
+		{
+			name: "general delivery",
+			a:    Address{Line1: "General Delivery", City: "Whitehorse", ProvinceOrState: "YT"},
+			want: AddressKindGeneralDelivery,
+		},
+		{
+			name: "string street",
+			a:    Address{String: "123 Main St, Springfield, IL 62704"},
+			want: AddressKindStreet,
+		},
+		{
+			name: "string po box",
+			a:    Address{String: "Jane Doe, P.O. Box 12, Austin, TX 78701"},
+			want: AddressKindPOBox,
+		},
+		{
+			name: "string military",
+			a:    Address{String: "PSC 1234 Box 5678 APO AE 09001"},
+			want: AddressKindMilitary,
+		},
+		{
+			name: "string rural route",
+			a:    Address{String: "RR 2 Box 15, Mountain View, AR 72560"},
+			want: AddressKindRuralRoute,
+		},
+		{
+			name: "empty",
+			a:    Address{},
+			want: AddressKindUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.a.Kind())
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package postgrid
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// LogRedaction selects the address fields redacted from logs.
+type LogRedaction int
+
+// All possible values for LogRedaction.
+const (
+	// LogRedactAddressLines redacts the address lines, firm name and freeform address, keeping the city,
+	// province or state, postal code and country.
+	LogRedactAddressLines LogRedaction = iota
+	// LogRedactAddresses redacts every address field.
+	LogRedactAddresses
+	// LogRedactNone logs addresses as sent.
+	LogRedactNone
+)
+
+// redacted replaces redacted values in logs.
+const redacted = "[REDACTED]"
+
+// redactedHeaders are the request headers whose values are never logged.
+var redactedHeaders = map[string]bool{"X-Api-Key": true, "Authorization": true}
+
+// addressLineFields are the form fields of an address redacted by LogRedactAddressLines.
+var addressLineFields = map[string]bool{"address": true, "address[line1]": true, "address[line2]": true}
+
+// logExchange logs a single attempt of an http request.
+func (c *Client) logExchange(req *http.Request, ex exchange, err error) {
+	if c.logger == nil {
+		return
+	}
+
+	ctx := req.Context()
+	level := slog.LevelDebug
+	if err != nil {
+		level = slog.LevelWarn
+	}
+	if !c.logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("path", req.URL.Path),
+		slog.Int("attempt", ex.attempt),
+		slog.Duration("latency", ex.latency),
+		slog.Duration("rate_limit_wait", ex.wait),
+	}
+	if ex.status != 0 {
+		attrs = append(attrs, slog.Int("status", ex.status))
+	}
+	if ex.message != "" {
+		attrs = append(attrs, slog.String("message", ex.message))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", RedactedError(err)))
+	}
+	if c.logger.Enabled(ctx, slog.LevelDebug) {
+		attrs = append(attrs, c.headerAttr(req.Header))
+		if attr, ok := c.addressAttr(req); ok {
+			attrs = append(attrs, attr)
+		}
+	}
+
+	c.logger.LogAttrs(ctx, level, "postgrid request", attrs...)
+}
+
+// RedactedError returns the text of err with the response body of a DecodeError redacted. The body is
+// only kept for responses that are not JSON, but a gateway page may still echo the request. The client
+// logs errors with it, and so should tracing and error reporting.
+func RedactedError(err error) string {
+	text := err.Error()
+	var decodeErr *DecodeError
+	if errors.As(err, &decodeErr) && decodeErr.Body != "" {
+		text = strings.Replace(text, decodeErr.Error(), decodeErr.message(redacted), 1)
+	}
+
+	return text
+}
+
+func (c *Client) headerAttr(h http.Header) slog.Attr {
+	attrs := make([]any, 0, len(h))
+	for _, name := range sortedKeys(h) {
+		value := redacted
+		if !redactedHeaders[name] {
+			value = h.Get(name)
+		}
+		attrs = append(attrs, slog.String(name, value))
+	}
+
+	return slog.Group("headers", attrs...)
+}
+
+// addressAttr describes the address of a Verify Address request, or the size of a Batch Verify Addresses
+// request whose addresses are never logged.
+func (c *Client) addressAttr(req *http.Request) (slog.Attr, bool) {
+	if req.GetBody == nil {
+		return slog.Attr{}, false
+	}
+	r, err := req.GetBody()
+	if err != nil {
+		return slog.Attr{}, false
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return slog.Attr{}, false
+	}
+
+	if req.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+		var batch BatchVerifyAddressesRequest
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return slog.Attr{}, false
+		}
+		return slog.Int("batch_size", len(batch.Addresses)), true
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return slog.Attr{}, false
+	}
+	attrs := make([]any, 0, len(form))
+	for _, field := range sortedKeys(form) {
+		value := form.Get(field)
+		if value == "" {
+			continue
+		}
+		if c.logRedaction == LogRedactAddresses || c.logRedaction == LogRedactAddressLines && addressLineFields[field] {
+			value = redacted
+		}
+		attrs = append(attrs, slog.String(field, value))
+	}
+
+	return slog.Group("address", attrs...), true
+}
+
+func sortedKeys[M ~map[string][]string](m M) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,140 @@
+package postgrid
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestClient_WithLogger(t *testing.T) {
+	address := Address{Line1: "1 main st", Line2: "apt 2", City: "Brooklyn", PostalOrZip: "11201"}
+
+	tests := []struct {
+		name        string
+		opts        []Option
+		fail        bool
+		wantLevel   string
+		wantAddress map[string]any
+	}{
+		{
+			name:      "address lines redacted by default",
+			wantLevel: "DEBUG",
+			wantAddress: map[string]any{
+				"address[line1]": redacted, "address[line2]": redacted, "address[city]": "Brooklyn", "address[postalOrZip]": "11201",
+			},
+		},
+		{
+			name:      "all address fields redacted",
+			opts:      []Option{WithLogRedaction(LogRedactAddresses)},
+			wantLevel: "DEBUG",
+			wantAddress: map[string]any{
+				"address[line1]": redacted, "address[line2]": redacted, "address[city]": redacted, "address[postalOrZip]": redacted,
+			},
+		},
+		{
+			name:      "nothing redacted",
+			opts:      []Option{WithLogRedaction(LogRedactNone)},
+			wantLevel: "DEBUG",
+			wantAddress: map[string]any{
+				"address[line1]": "1 main st", "address[line2]": "apt 2", "address[city]": "Brooklyn", "address[postalOrZip]": "11201",
+			},
+		},
+		{
+			name:      "failures logged as warnings",
+			fail:      true,
+			wantLevel: "WARN",
+			wantAddress: map[string]any{
+				"address[line1]": redacted, "address[line2]": redacted, "address[city]": "Brooklyn", "address[postalOrZip]": "11201",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.fail {
+					w.WriteHeader(http.StatusBadRequest)
+					w.Write([]byte(`{"status":"error","message":"Invalid address."}`))
+					return
+				}
+				writeTestResponse(t, w, VerifiedAddress{})
+			}))
+			t.Cleanup(srv.Close)
+
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+			opts := append([]Option{WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Inf, 0)), WithLogger(logger)}, tt.opts...)
+			client := NewClient("secret-key", srv.URL, opts...)
+
+			_, err := client.VerifyAddress(context.Background(), VerifyAddressRequest{Address: address})
+			assert.Equal(t, tt.fail, err != nil)
+			assert.NotContains(t, buf.String(), "secret-key")
+
+			var got map[string]any
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+			assert.Equal(t, tt.wantLevel, got["level"])
+			assert.Equal(t, "postgrid request", got["msg"])
+			assert.Equal(t, http.MethodPost, got["method"])
+			assert.Equal(t, "/addver/verifications", got["path"])
+			assert.Equal(t, float64(1), got["attempt"])
+			assert.Contains(t, got, "latency")
+			assert.Contains(t, got, "rate_limit_wait")
+			assert.Equal(t, redacted, got["headers"].(map[string]any)["X-Api-Key"])
+			assert.Equal(t, tt.wantAddress, got["address"])
+			if tt.fail {
+				assert.Equal(t, float64(http.StatusBadRequest), got["status"])
+				assert.Equal(t, "Invalid address.", got["message"])
+				assert.Equal(t, "postgrid error: Invalid address.", got["error"])
+			} else {
+				assert.Equal(t, float64(http.StatusOK), got["status"])
+			}
+		})
+	}
+}
+
+func TestClient_WithLogger_MalformedBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The page of a gateway echoing the request.
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body>Cannot route 9880 LAKE RD APT 15</body></html>`))
+	}))
+	t.Cleanup(srv.Close)
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	client := NewClient("secret-key", srv.URL, WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Inf, 0)), WithLogger(logger))
+
+	_, err := client.VerifyAddress(context.Background(), VerifyAddressRequest{Address: Address{Line1: "9880 lake rd"}})
+	require.ErrorContains(t, err, "9880 LAKE RD")
+	assert.Contains(t, buf.String(), "received string response: "+redacted)
+	assert.NotContains(t, buf.String(), "LAKE RD")
+	assert.NotContains(t, buf.String(), "APT 15")
+}
+
+func TestClient_WithLogger_Batch(t *testing.T) {
+	srv, _ := newBatchTestServer(t, false)
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	client := NewClient("secret-key", srv.URL, WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Inf, 0)), WithLogger(logger))
+
+	_, err := client.BatchVerifyAddresses(context.Background(), BatchVerifyAddressesRequest{Addresses: []Address{{Line1: "1 main st"}, {String: "2 main st"}}})
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), "batch_size=2")
+	assert.NotContains(t, buf.String(), "main st")
+
+	// Successful requests are not logged above debug level.
+	buf.Reset()
+	client = NewClient("secret-key", srv.URL, WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Inf, 0)), WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+	_, err = client.BatchVerifyAddresses(context.Background(), BatchVerifyAddressesRequest{Addresses: []Address{{Line1: "1 main st"}}})
+	require.NoError(t, err)
+	assert.Empty(t, strings.TrimSpace(buf.String()))
+}
@@ -0,0 +1,50 @@
+package postgrid
+
+import "time"
+
+// Metrics receives the measurements of the client, for dashboards of lookup volume and verification
+// outcomes. Endpoints are EndpointVerify or EndpointBatchVerify, whatever the path of the base url. Methods
+// are called synchronously and may be called concurrently, so implementations must be fast and safe for
+// concurrent use.
+//
+// Methods are added as the client measures more, so implementations should embed NopMetrics.
+type Metrics interface {
+	// ObserveRequest is called after every http request attempt with its status code, 0 when no response
+	// was received, and the duration of the http exchange.
+	ObserveRequest(endpoint string, statusCode int, latency time.Duration)
+	// ObserveRateLimitWait is called for every http request attempt with the time it waited for the rate
+	// limiter.
+	ObserveRateLimitWait(endpoint string, wait time.Duration)
+	// ObserveResult is called with the VerifiedAddress.Status of every address verified by the client,
+	// including results served from the cache. Bulk operations report their results under the batch
+	// endpoint.
+	ObserveResult(endpoint string, status string)
+	// ObserveCacheLookup is called for every address looked up in the cache of a client configured
+	// WithCache.
+	ObserveCacheLookup(hit bool)
+}
+
+// NopMetrics is a Metrics discarding every measurement. It is the default of a client.
+type NopMetrics struct{}
+
+// ObserveRequest does nothing.
+func (NopMetrics) ObserveRequest(string, int, time.Duration) {}
+
+// ObserveRateLimitWait does nothing.
+func (NopMetrics) ObserveRateLimitWait(string, time.Duration) {}
+
+// ObserveResult does nothing.
+func (NopMetrics) ObserveResult(string, string) {}
+
+// ObserveCacheLookup does nothing.
+func (NopMetrics) ObserveCacheLookup(bool) {}
+
+var _ Metrics = NopMetrics{}
+
+// observeExchange reports a single attempt of an http request to the metrics.
+func (c *Client) observeExchange(ex exchange) {
+	c.metrics.ObserveRateLimitWait(ex.endpoint, ex.wait)
+	if ex.sent {
+		c.metrics.ObserveRequest(ex.endpoint, ex.status, ex.latency)
+	}
+}
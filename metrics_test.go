@@ -0,0 +1,112 @@
+package postgrid
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+// recordingMetrics records the measurements of a client.
+type recordingMetrics struct {
+	NopMetrics
+
+	mu       sync.Mutex
+	requests []string
+	waits    []string
+	results  []string
+	lookups  []bool
+}
+
+func (m *recordingMetrics) ObserveRequest(endpoint string, statusCode int, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, endpoint+" "+http.StatusText(statusCode))
+}
+
+func (m *recordingMetrics) ObserveRateLimitWait(endpoint string, wait time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.waits = append(m.waits, endpoint)
+}
+
+func (m *recordingMetrics) ObserveResult(endpoint string, status string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.results = append(m.results, endpoint+" "+status)
+}
+
+func (m *recordingMetrics) ObserveCacheLookup(hit bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lookups = append(m.lookups, hit)
+}
+
+func TestClient_WithMetrics(t *testing.T) {
+	var calls atomic.Int32
+	// The base url has a path, like BaseURL.
+	srv := httptest.NewServer(http.StripPrefix("/v1", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"status":"error","message":"unavailable"}`))
+			return
+		}
+		if r.URL.Path == "/addver/verifications/batch" {
+			var req BatchVerifyAddressesRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			var resp BatchVerifyAddressesResponse
+			for range req.Addresses {
+				resp.Results = append(resp.Results, VerifiedAddressResponse{VerifiedAddress: VerifiedAddress{Status: VerificationStatusFailed}})
+			}
+			writeTestResponse(t, w, resp)
+			return
+		}
+		writeTestResponse(t, w, VerifiedAddress{Status: VerificationStatusVerified})
+	})))
+	t.Cleanup(srv.Close)
+
+	metrics := &recordingMetrics{}
+	client := NewClient("", srv.URL+"/v1", WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Inf, 0)),
+		WithRetry(2, time.Millisecond), WithCache(NewLRUCache(10)), WithMetrics(metrics))
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		_, err := client.VerifyAddress(ctx, VerifyAddressRequest{Address: Address{Line1: "A"}})
+		require.NoError(t, err)
+	}
+	_, err := client.BatchVerifyAddresses(ctx, BatchVerifyAddressesRequest{Addresses: []Address{{Line1: "A"}, {Line1: "B"}}})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"/addver/verifications Service Unavailable",
+		"/addver/verifications OK",
+		"/addver/verifications/batch OK",
+	}, metrics.requests)
+	assert.Equal(t, []string{"/addver/verifications", "/addver/verifications", "/addver/verifications/batch"}, metrics.waits)
+	assert.Equal(t, []string{
+		"/addver/verifications verified",
+		"/addver/verifications verified",
+		"/addver/verifications/batch verified",
+		"/addver/verifications/batch failed",
+	}, metrics.results)
+	assert.Equal(t, []bool{false, true, true, false}, metrics.lookups)
+}
+
+func TestClient_WithMetrics_Nil(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeTestResponse(t, w, VerifiedAddress{})
+	}))
+	t.Cleanup(srv.Close)
+
+	client := NewClient("", srv.URL, WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Inf, 0)), WithMetrics(nil))
+	_, err := client.VerifyAddress(context.Background(), VerifyAddressRequest{Address: Address{Line1: "A"}})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,266 @@
+// Package metro2 maps postgrid verified addresses to the address fields of the Metro 2 Base Segment and
+// J1/J2 Segments used for consumer credit reporting.
+package metro2
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	postgrid "github.com/bloomcredit/bloomcredit-postgrid-sdk"
+)
+
+// Maximum field lengths defined by the Metro 2 format.
+const (
+	FirstLineLength  = 32
+	SecondLineLength = 32
+	CityLength       = 20
+	StateLength      = 2
+	PostalCodeLength = 9
+)
+
+// Names of the Metro 2 address fields, used in FieldError and Address.Truncated.
+const (
+	FieldFirstLine        = "First Line of Address"
+	FieldSecondLine       = "Second Line of Address"
+	FieldCity             = "City"
+	FieldState            = "State"
+	FieldPostalCode       = "Postal/Zip Code"
+	FieldAddressIndicator = "Address Indicator"
+	FieldResidenceCode    = "Residence Code"
+)
+
+// AddressIndicator is the Metro 2 Address Indicator.
+type AddressIndicator string
+
+// All possible values for AddressIndicator.
+const (
+	AddressIndicatorConfirmed      AddressIndicator = "C"
+	AddressIndicatorKnownPrimary   AddressIndicator = "Y"
+	AddressIndicatorNotConfirmed   AddressIndicator = "N"
+	AddressIndicatorMilitary       AddressIndicator = "M"
+	AddressIndicatorSecondary      AddressIndicator = "S"
+	AddressIndicatorBusiness       AddressIndicator = "B"
+	AddressIndicatorNonDeliverable AddressIndicator = "U"
+	AddressIndicatorDefault        AddressIndicator = "D"
+	AddressIndicatorBillPayer      AddressIndicator = "P"
+)
+
+// ResidenceCode is the Metro 2 Residence Code.
+type ResidenceCode string
+
+// All possible values for ResidenceCode.
+const (
+	ResidenceCodeUnknown ResidenceCode = ""
+	ResidenceCodeOwns    ResidenceCode = "O"
+	ResidenceCodeRents   ResidenceCode = "R"
+)
+
+// Validation errors wrapped by FieldError.
+var (
+	ErrRequired           = errors.New("field is required")
+	ErrTooLong            = errors.New("field exceeds maximum length")
+	ErrInvalidCharacters  = errors.New("field contains characters not allowed by metro 2")
+	ErrInvalidState       = errors.New("not a valid US state, territory or military state code")
+	ErrInvalidPostalCode  = errors.New("postal code must be 5 or 9 digits")
+	ErrInvalidIndicator   = errors.New("not a valid address indicator")
+	ErrInvalidResidence   = errors.New("not a valid residence code")
+	ErrUnsupportedCountry = errors.New("metro 2 only supports US addresses")
+)
+
+// FieldError describes a Metro 2 address field that failed validation.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("metro2: %s: %v", e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Address holds the Metro 2 address fields of a consumer.
+type Address struct {
+	FirstLine        string
+	SecondLine       string
+	City             string
+	State            string
+	PostalCode       string
+	AddressIndicator AddressIndicator
+	ResidenceCode    ResidenceCode
+
+	// Truncated lists the fields that did not fit their Metro 2 length even after abbreviation and were
+	// cut. Truncated addresses may need manual review before reporting.
+	Truncated []string
+}
+
+// Options holds data furnisher knowledge that cannot be derived from a verified address.
+type Options struct {
+	// ResidenceCode is reported for street addresses only; it is left blank for PO boxes, military and
+	// business addresses.
+	ResidenceCode ResidenceCode
+	// Secondary reports the address with AddressIndicatorSecondary when it is otherwise confirmed.
+	Secondary bool
+}
+
+var (
+	postalCodeRegex  = regexp.MustCompile(`^(\d{5}|\d{9})$`)
+	disallowedRegex  = regexp.MustCompile(`[^A-Z0-9 \-/#&'.,]`)
+	whitespaceRegex  = regexp.MustCompile(`\s+`)
+	validIndicators  = map[AddressIndicator]bool{"C": true, "Y": true, "N": true, "M": true, "S": true, "B": true, "U": true, "D": true, "P": true}
+	validResidences  = map[ResidenceCode]bool{"": true, "O": true, "R": true}
+	supportedCountry = map[string]bool{"": true, "US": true, "USA": true, "UNITED STATES": true}
+)
+
+// FromVerifiedAddress maps a postgrid verified address to Metro 2 address fields. Fields that exceed their
+// Metro 2 length are abbreviated, then truncated. The returned error joins a *FieldError for every field
+// that fails Validate.
+//
+// The address indicator is derived in order: military addresses report M, addresses postgrid could not
+// verify report N, firms and non-residential street addresses report B, and the rest report C, or S when
+// Options.Secondary is set. Non-residential detection relies on the details returned by postgrid.
+func FromVerifiedAddress(v postgrid.VerifiedAddress, opts Options) (Address, error) {
+	if !supportedCountry[strings.ToUpper(strings.TrimSpace(v.Country))] {
+		return Address{}, &FieldError{Field: FieldState, Err: ErrUnsupportedCountry}
+	}
+
+	a := Address{
+		FirstLine:  sanitize(v.Line1),
+		SecondLine: sanitize(v.Line2),
+		City:       sanitize(v.City),
+		State:      sanitize(v.ProvinceOrState),
+		PostalCode: postalCode(v.PostalOrZip, v.ZipPlus4),
+	}
+
+	// First Line of Address is required, so promote a lone second line.
+	if a.FirstLine == "" && a.SecondLine != "" {
+		a.FirstLine, a.SecondLine = a.SecondLine, ""
+	}
+
+	a.fitLines(v.Details)
+	a.City = a.fit(FieldCity, a.City, CityLength)
+
+	kind := v.Kind()
+	a.AddressIndicator = addressIndicator(v, kind, opts)
+	if kind == postgrid.AddressKindStreet {
+		a.ResidenceCode = opts.ResidenceCode
+	}
+
+	return a, a.Validate()
+}
+
+// addressIndicator derives the address indicator from the verification status and address kind.
+func addressIndicator(v postgrid.VerifiedAddress, kind postgrid.AddressKind, opts Options) AddressIndicator {
+	switch {
+	case kind == postgrid.AddressKindMilitary:
+		return AddressIndicatorMilitary
+	case v.Status != postgrid.VerificationStatusVerified && v.Status != postgrid.VerificationStatusCorrected:
+		return AddressIndicatorNotConfirmed
+	case kind == postgrid.AddressKindFirm, kind == postgrid.AddressKindStreet && !v.Details.Residential:
+		return AddressIndicatorBusiness
+	case opts.Secondary:
+		return AddressIndicatorSecondary
+	}
+
+	return AddressIndicatorConfirmed
+}
+
+// fitLines abbreviates the first and second lines to their Metro 2 length. When the first line is still
+// too long, its secondary unit is moved to an empty second line before truncating.
+func (a *Address) fitLines(details postgrid.VerifiedAddressDetails) {
+	a.FirstLine = abbreviate(a.FirstLine, FirstLineLength)
+
+	unit := abbreviate(sanitize(details.SuiteKey+" "+details.SuiteID), 0)
+	if len(a.FirstLine) > FirstLineLength && a.SecondLine == "" && unit != "" && strings.HasSuffix(a.FirstLine, " "+unit) {
+		a.FirstLine = strings.TrimSuffix(a.FirstLine, " "+unit)
+		a.SecondLine = unit
+	}
+
+	a.FirstLine = a.fit(FieldFirstLine, a.FirstLine, FirstLineLength)
+	a.SecondLine = a.fit(FieldSecondLine, a.SecondLine, SecondLineLength)
+}
+
+// fit abbreviates s and truncates it to n characters at a word boundary where possible, recording the field
+// in a.Truncated when characters are dropped.
+func (a *Address) fit(field, s string, n int) string {
+	s = abbreviate(s, n)
+	if len(s) <= n {
+		return s
+	}
+
+	a.Truncated = append(a.Truncated, field)
+	cut := s[:n]
+	if i := strings.LastIndexByte(cut, ' '); i > 0 && s[n] != ' ' {
+		cut = cut[:i]
+	}
+
+	return strings.TrimSpace(cut)
+}
+
+// Validate checks the fields against the Metro 2 length, character set and value rules. The returned
+// error joins a *FieldError for every invalid field.
+func (a Address) Validate() error {
+	var errs []error
+	check := func(field string, err error) {
+		errs = append(errs, &FieldError{Field: field, Err: err})
+	}
+
+	for _, f := range []struct {
+		name     string
+		value    string
+		max      int
+		required bool
+	}{
+		{FieldFirstLine, a.FirstLine, FirstLineLength, true},
+		{FieldSecondLine, a.SecondLine, SecondLineLength, false},
+		{FieldCity, a.City, CityLength, true},
+	} {
+		switch {
+		case f.required && f.value == "":
+			check(f.name, ErrRequired)
+		case len(f.value) > f.max:
+			check(f.name, ErrTooLong)
+		case disallowedRegex.MatchString(f.value):
+			check(f.name, ErrInvalidCharacters)
+		}
+	}
+
+	if !states[a.State] {
+		check(FieldState, ErrInvalidState)
+	}
+	if !postalCodeRegex.MatchString(a.PostalCode) {
+		check(FieldPostalCode, ErrInvalidPostalCode)
+	}
+	if !validIndicators[a.AddressIndicator] {
+		check(FieldAddressIndicator, ErrInvalidIndicator)
+	}
+	if !validResidences[a.ResidenceCode] {
+		check(FieldResidenceCode, ErrInvalidResidence)
+	}
+
+	return errors.Join(errs...)
+}
+
+// sanitize upper cases s, replaces characters Metro 2 does not allow with spaces and collapses whitespace.
+func sanitize(s string) string {
+	s = disallowedRegex.ReplaceAllString(strings.ToUpper(s), " ")
+
+	return strings.TrimSpace(whitespaceRegex.ReplaceAllString(s, " "))
+}
+
+func postalCode(zip, plus4 string) string {
+	digits := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, s)
+	}
+
+	return digits(zip) + digits(plus4)
+}
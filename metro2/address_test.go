@@ -0,0 +1,271 @@
+package metro2
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	postgrid "github.com/bloomcredit/bloomcredit-postgrid-sdk"
+)
+
+func TestFromVerifiedAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       postgrid.VerifiedAddress
+		opts    Options
+		want    Address
+		wantErr []error
+	}{
+		{
+			name: "residential street address",
+			v: postgrid.VerifiedAddress{
+				Line1:           "251 E 13th St",
+				Line2:           "Apt 4",
+				City:            "New York",
+				ProvinceOrState: "NY",
+				PostalOrZip:     "10003",
+				ZipPlus4:        "5646",
+				Country:         "us",
+				Status:          "verified",
+				Details:         postgrid.VerifiedAddressDetails{Residential: true},
+			},
+			opts: Options{ResidenceCode: ResidenceCodeRents},
+			want: Address{
+				FirstLine:        "251 E 13TH ST",
+				SecondLine:       "APT 4",
+				City:             "NEW YORK",
+				State:            "NY",
+				PostalCode:       "100035646",
+				AddressIndicator: AddressIndicatorConfirmed,
+				ResidenceCode:    ResidenceCodeRents,
+			},
+		},
+		{
+			name: "secondary address",
+			v: postgrid.VerifiedAddress{
+				Line1:           "251 E 13TH ST",
+				City:            "NEW YORK",
+				ProvinceOrState: "NY",
+				PostalOrZip:     "10003",
+				Country:         "us",
+				Status:          "corrected",
+				Details:         postgrid.VerifiedAddressDetails{Residential: true},
+			},
+			opts: Options{Secondary: true},
+			want: Address{
+				FirstLine:        "251 E 13TH ST",
+				City:             "NEW YORK",
+				State:            "NY",
+				PostalCode:       "10003",
+				AddressIndicator: AddressIndicatorSecondary,
+			},
+		},
+		{
+			name: "firm is reported as business without residence code",
+			v: postgrid.VerifiedAddress{
+				Line1:           "251 E 13TH ST FRNT A",
+				City:            "NEW YORK",
+				ProvinceOrState: "NY",
+				PostalOrZip:     "10003",
+				ZipPlus4:        "5646",
+				FirmName:        "MILK BAR",
+				Country:         "us",
+				Status:          "verified",
+			},
+			opts: Options{ResidenceCode: ResidenceCodeOwns},
+			want: Address{
+				FirstLine:        "251 E 13TH ST FRNT A",
+				City:             "NEW YORK",
+				State:            "NY",
+				PostalCode:       "100035646",
+				AddressIndicator: AddressIndicatorBusiness,
+			},
+		},
+		{
+			name: "military",
+			v: postgrid.VerifiedAddress{
+				Line1:           "PSC 1234 BOX 5678",
+				City:            "APO",
+				ProvinceOrState: "AE",
+				PostalOrZip:     "09001",
+				Country:         "us",
+				Status:          "verified",
+				Details:         postgrid.VerifiedAddressDetails{BoxID: "5678"},
+			},
+			opts: Options{ResidenceCode: ResidenceCodeOwns},
+			want: Address{
+				FirstLine:        "PSC 1234 BOX 5678",
+				City:             "APO",
+				State:            "AE",
+				PostalCode:       "09001",
+				AddressIndicator: AddressIndicatorMilitary,
+			},
+		},
+		{
+			name: "po box",
+			v: postgrid.VerifiedAddress{
+				Line1:           "PO BOX 4567",
+				City:            "AUSTIN",
+				ProvinceOrState: "TX",
+				PostalOrZip:     "78701",
+				ZipPlus4:        "0123",
+				Country:         "us",
+				Status:          "verified",
+				Details:         postgrid.VerifiedAddressDetails{BoxID: "4567"},
+			},
+			opts: Options{ResidenceCode: ResidenceCodeOwns},
+			want: Address{
+				FirstLine:        "PO BOX 4567",
+				City:             "AUSTIN",
+				State:            "TX",
+				PostalCode:       "787010123",
+				AddressIndicator: AddressIndicatorConfirmed,
+			},
+		},
+		{
+			name: "unverified",
+			v: postgrid.VerifiedAddress{
+				Line1:           "1 NOWHERE RD",
+				City:            "SPRINGFIELD",
+				ProvinceOrState: "IL",
+				PostalOrZip:     "62704",
+				Country:         "us",
+				Status:          "failed",
+			},
+			want: Address{
+				FirstLine:        "1 NOWHERE RD",
+				City:             "SPRINGFIELD",
+				State:            "IL",
+				PostalCode:       "62704",
+				AddressIndicator: AddressIndicatorNotConfirmed,
+			},
+		},
+		{
+			name: "long lines are abbreviated and the unit moved to the second line",
+			v: postgrid.VerifiedAddress{
+				Line1:           "12345 North Martin Luther King Junior Boulevard Suite 1200",
+				City:            "Saint Petersburg Beach Heights",
+				ProvinceOrState: "FL",
+				PostalOrZip:     "33706",
+				Country:         "us",
+				Status:          "verified",
+				Details: postgrid.VerifiedAddressDetails{
+					SuiteKey:    "SUITE",
+					SuiteID:     "1200",
+					Residential: true,
+				},
+			},
+			want: Address{
+				FirstLine:        "12345 N MARTIN LUTHER KING JR",
+				SecondLine:       "STE 1200",
+				City:             "ST PETERSBURG BCH",
+				State:            "FL",
+				PostalCode:       "33706",
+				AddressIndicator: AddressIndicatorConfirmed,
+				Truncated:        []string{FieldFirstLine, FieldCity},
+			},
+		},
+		{
+			name: "canadian address",
+			v: postgrid.VerifiedAddress{
+				Line1:           "345 QUEEN ST W",
+				City:            "TORONTO",
+				ProvinceOrState: "ON",
+				PostalOrZip:     "M5V 2A4",
+				Country:         "ca",
+				Status:          "verified",
+			},
+			wantErr: []error{ErrUnsupportedCountry},
+		},
+		{
+			name: "missing fields",
+			v: postgrid.VerifiedAddress{
+				ProvinceOrState: "XX",
+				PostalOrZip:     "123",
+				Country:         "us",
+				Status:          "verified",
+			},
+			want: Address{
+				State:            "XX",
+				PostalCode:       "123",
+				AddressIndicator: AddressIndicatorConfirmed,
+			},
+			wantErr: []error{ErrRequired, ErrInvalidState, ErrInvalidPostalCode},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromVerifiedAddress(tt.v, tt.opts)
+			if len(tt.wantErr) == 0 {
+				assert.NoError(t, err)
+			}
+			for _, wantErr := range tt.wantErr {
+				assert.ErrorIs(t, err, wantErr)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAddress_Validate(t *testing.T) {
+	valid := Address{
+		FirstLine:        "251 E 13TH ST",
+		City:             "NEW YORK",
+		State:            "NY",
+		PostalCode:       "10003",
+		AddressIndicator: AddressIndicatorConfirmed,
+	}
+
+	tests := []struct {
+		name      string
+		modify    func(*Address)
+		wantField string
+		wantErr   error
+	}{
+		{
+			name:   "valid",
+			modify: func(*Address) {},
+		},
+		{
+			name:      "first line too long",
+			modify:    func(a *Address) { a.FirstLine = "123456789012345678901234567890123" },
+			wantField: FieldFirstLine,
+			wantErr:   ErrTooLong,
+		},
+		{
+			name:      "city with invalid characters",
+			modify:    func(a *Address) { a.City = "NEW YORK!" },
+			wantField: FieldCity,
+			wantErr:   ErrInvalidCharacters,
+		},
+		{
+			name:      "invalid indicator",
+			modify:    func(a *Address) { a.AddressIndicator = "Z" },
+			wantField: FieldAddressIndicator,
+			wantErr:   ErrInvalidIndicator,
+		},
+		{
+			name:      "invalid residence code",
+			modify:    func(a *Address) { a.ResidenceCode = "X" },
+			wantField: FieldResidenceCode,
+			wantErr:   ErrInvalidResidence,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := valid
+			tt.modify(&a)
+			err := a.Validate()
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			var fieldErr *FieldError
+			if assert.ErrorAs(t, err, &fieldErr) {
+				assert.Equal(t, tt.wantField, fieldErr.Field)
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
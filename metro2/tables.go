@@ -0,0 +1,44 @@
+package metro2
+
+import "strings"
+
+// abbreviations maps words to their USPS Publication 28 abbreviation. They are applied to lines and
+// cities that exceed their Metro 2 length.
+var abbreviations = map[string]string{
+	"APARTMENT": "APT", "AVENUE": "AVE", "BOULEVARD": "BLVD", "BUILDING": "BLDG", "CIRCLE": "CIR",
+	"COURT": "CT", "DEPARTMENT": "DEPT", "DRIVE": "DR", "EXPRESSWAY": "EXPY", "FLOOR": "FL",
+	"FREEWAY": "FWY", "HIGHWAY": "HWY", "LANE": "LN", "PARKWAY": "PKWY", "PLACE": "PL", "ROAD": "RD",
+	"ROOM": "RM", "SQUARE": "SQ", "STREET": "ST", "SUITE": "STE", "TERRACE": "TER", "TRAIL": "TRL",
+	"NORTH": "N", "SOUTH": "S", "EAST": "E", "WEST": "W",
+	"NORTHEAST": "NE", "NORTHWEST": "NW", "SOUTHEAST": "SE", "SOUTHWEST": "SW",
+	"SAINT": "ST", "FORT": "FT", "MOUNT": "MT", "HEIGHTS": "HTS", "SPRINGS": "SPGS", "JUNCTION": "JCT",
+	"JUNIOR":  "JR",
+	"VILLAGE": "VLG", "BEACH": "BCH", "CENTER": "CTR", "ISLAND": "IS", "LAKE": "LK", "POINT": "PT",
+	"VALLEY": "VLY",
+}
+
+// abbreviate replaces words in s with their abbreviation until s fits n characters or no more words can
+// be abbreviated. Words are abbreviated from the end, keeping the leading words readable.
+func abbreviate(s string, n int) string {
+	words := strings.Fields(s)
+	for i := len(words) - 1; i >= 0 && len(strings.Join(words, " ")) > n; i-- {
+		if abbr, ok := abbreviations[words[i]]; ok {
+			words[i] = abbr
+		}
+	}
+
+	return strings.Join(words, " ")
+}
+
+// states holds the US state, territory and military state codes accepted in the Metro 2 State field.
+var states = map[string]bool{
+	"AL": true, "AK": true, "AZ": true, "AR": true, "CA": true, "CO": true, "CT": true, "DE": true,
+	"DC": true, "FL": true, "GA": true, "HI": true, "ID": true, "IL": true, "IN": true, "IA": true,
+	"KS": true, "KY": true, "LA": true, "ME": true, "MD": true, "MA": true, "MI": true, "MN": true,
+	"MS": true, "MO": true, "MT": true, "NE": true, "NV": true, "NH": true, "NJ": true, "NM": true,
+	"NY": true, "NC": true, "ND": true, "OH": true, "OK": true, "OR": true, "PA": true, "RI": true,
+	"SC": true, "SD": true, "TN": true, "TX": true, "UT": true, "VT": true, "VA": true, "WA": true,
+	"WV": true, "WI": true, "WY": true,
+	"AS": true, "FM": true, "GU": true, "MH": true, "MP": true, "PR": true, "PW": true, "VI": true,
+	"AA": true, "AE": true, "AP": true,
+}
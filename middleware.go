@@ -0,0 +1,117 @@
+package postgrid
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RequestIDHeader is the header set by the RequestID middleware.
+const RequestIDHeader = "X-Request-Id"
+
+// modulePath is the path of this module, used to find its version in the build info.
+const modulePath = "github.com/bloomcredit/bloomcredit-postgrid-sdk"
+
+// Doer sends an http request and returns its response, like http.Client.Do.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// DoerFunc adapts a function to a Doer.
+type DoerFunc func(req *http.Request) (*http.Response, error)
+
+// Do calls f(req).
+func (f DoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware wraps the http exchange of the client, e.g. to add headers or audit requests. A middleware
+// must return the response of next or close its body.
+type Middleware func(next Doer) Doer
+
+// chain wraps doer with the middlewares, the first middleware being the outermost.
+func chain(doer Doer, middlewares []Middleware) Doer {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		doer = middlewares[i](doer)
+	}
+
+	return doer
+}
+
+// Version returns the version of this module as recorded in the build info of the binary, or "devel" when
+// it is unknown, e.g. in tests or when the module is replaced.
+func Version() string {
+	versionOnce.Do(func() {
+		version = "devel"
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath && dep.Version != "" && dep.Replace == nil {
+				version = dep.Version
+				return
+			}
+		}
+	})
+
+	return version
+}
+
+var (
+	versionOnce sync.Once
+	version     string
+)
+
+// UserAgent returns a middleware setting the User-Agent header to product followed by the name and Version
+// of this sdk, e.g. "checkout/1.2 bloomcredit-postgrid-sdk/v1.0.0". product may be empty.
+func UserAgent(product string) Middleware {
+	ua := strings.TrimSpace(product + " bloomcredit-postgrid-sdk/" + Version())
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			req.Header.Set("User-Agent", ua)
+			return next.Do(req)
+		})
+	}
+}
+
+// RequestID returns a middleware setting the RequestIDHeader of requests that do not have one to the
+// result of generate, or to 16 random bytes in hex when generate is nil. Retries of a request keep its id.
+func RequestID(generate func() string) Middleware {
+	if generate == nil {
+		generate = randomID
+	}
+
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get(RequestIDHeader) == "" {
+				req.Header.Set(RequestIDHeader, generate())
+			}
+			return next.Do(req)
+		})
+	}
+}
+
+// randomID returns 16 random bytes in hex.
+func randomID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Timing returns a middleware calling observe after every http exchange with the time until the response
+// headers were received or the exchange failed.
+func Timing(observe func(req *http.Request, resp *http.Response, d time.Duration, err error)) Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.Do(req)
+			observe(req, resp, time.Since(start), err)
+			return resp, err
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package postgrid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestClient_WithMiddleware(t *testing.T) {
+	var calls atomic.Int32
+	var mu sync.Mutex
+	var headers []http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		headers = append(headers, r.Header.Clone())
+		mu.Unlock()
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		writeTestResponse(t, w, VerifiedAddress{})
+	}))
+	t.Cleanup(srv.Close)
+
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next Doer) Doer {
+			return DoerFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name+" "+req.Header.Get("x-api-key"))
+				req.Header.Set("X-Egress-Auth", "token")
+				return next.Do(req)
+			})
+		}
+	}
+	var timings []int
+	timing := Timing(func(req *http.Request, resp *http.Response, d time.Duration, err error) {
+		require.NoError(t, err)
+		assert.Positive(t, d)
+		timings = append(timings, resp.StatusCode)
+	})
+	var ids atomic.Int32
+	requestID := RequestID(func() string {
+		ids.Add(1)
+		return "req-1"
+	})
+
+	client := NewClient("key", srv.URL, WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Inf, 0)),
+		WithRetry(2, time.Millisecond), WithMiddleware(trace("outer"), UserAgent("checkout/1.0")), WithMiddleware(requestID, timing))
+
+	_, err := client.VerifyAddress(context.Background(), VerifyAddressRequest{Address: Address{Line1: "A"}})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"outer key", "outer key"}, order)
+	assert.Equal(t, []int{http.StatusServiceUnavailable, http.StatusOK}, timings)
+	assert.Equal(t, int32(1), ids.Load())
+
+	require.Len(t, headers, 2)
+	for _, h := range headers {
+		assert.Equal(t, "token", h.Get("X-Egress-Auth"))
+		assert.Equal(t, "req-1", h.Get(RequestIDHeader))
+		assert.Equal(t, "checkout/1.0 bloomcredit-postgrid-sdk/"+Version(), h.Get("User-Agent"))
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var got []string
+	doer := RequestID(nil)(DoerFunc(func(req *http.Request) (*http.Response, error) {
+		got = append(got, req.Header.Get(RequestIDHeader))
+		return nil, nil
+	}))
+
+	for _, id := range []string{"", "", "caller-id"} {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		if id != "" {
+			req.Header.Set(RequestIDHeader, id)
+		}
+		_, err := doer.Do(req)
+		require.NoError(t, err)
+	}
+
+	require.Len(t, got, 3)
+	assert.Len(t, got[0], 32)
+	assert.NotEqual(t, got[0], got[1])
+	assert.Equal(t, "caller-id", got[2])
+}
+
+func TestUserAgent(t *testing.T) {
+	var got string
+	doer := UserAgent("")(DoerFunc(func(req *http.Request) (*http.Response, error) {
+		got = req.Header.Get("User-Agent")
+		return nil, nil
+	}))
+
+	_, err := doer.Do(httptest.NewRequest(http.MethodPost, "/", nil))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(got, "bloomcredit-postgrid-sdk/"), got)
+}
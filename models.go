@@ -14,6 +14,13 @@ const (
 	ResponseStatusError   = "error"
 )
 
+// All possible values for VerifiedAddress.Status.
+const (
+	VerificationStatusVerified  = "verified"
+	VerificationStatusCorrected = "corrected"
+	VerificationStatusFailed    = "failed"
+)
+
 // MaxBatchSize is the max size for a batch address verification request.
 const MaxBatchSize = 2000
 
@@ -47,6 +54,20 @@ func (a Address) MarshalJSON() ([]byte, error) {
 	return json.Marshal(Alias(a))
 }
 
+// UnmarshalJSON decodes both forms produced by MarshalJSON: a JSON string is decoded into String and an
+// object into the structured fields. Either form replaces the whole address.
+func (a *Address) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && (data[0] == '"' || data[0] == '{') {
+		*a = Address{}
+	}
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &a.String)
+	}
+
+	type Alias Address
+	return json.Unmarshal(data, (*Alias)(a))
+}
+
 // VerifyAddressRequest represents the request model to be sent to the Verify Address endpoint.
 type VerifyAddressRequest struct {
 	Address Address
@@ -78,13 +99,14 @@ type BatchVerifyAddressesResponse struct {
 	Results []VerifiedAddressResponse `json:"results"`
 }
 
-// GeocodeResult represents ...
+// GeocodeResult represents the geocoded location of a verified address.
 type GeocodeResult struct {
 	Location     GeocodeLocation `json:"location"`
 	Accuracy     float32         `json:"accuracy"`
-	AccuracyType string          `json:"accuracyType"`
+	AccuracyType AccuracyType    `json:"accuracyType"`
 }
 
+// GeocodeLocation represents a latitude and longitude in degrees.
 type GeocodeLocation struct {
 	Latitude  float64 `json:"lat"`
 	Longitude float64 `json:"lng"`
@@ -104,6 +126,10 @@ type VerifiedAddress struct {
 	Status          string                 `json:"status"`
 	Details         VerifiedAddressDetails `json:"details"`
 	GeocodeResult   GeocodeResult          `json:"geocodeResult"`
+
+	// CacheInfo is set when the result was served from the client's cache. It is not part of the postgrid
+	// api response.
+	CacheInfo *CacheInfo `json:"-"`
 }
 type VerifiedAddressDetails struct {
 	StreetName                         string `json:"streetName"`
@@ -151,7 +177,10 @@ type VerifiedAddressDetails struct {
 	USMailingsLACSReturnCode           string `json:"usMailingsLACSReturnCode"`
 }
 
-// VerifiedAddressResponse ...
+// VerifiedAddressResponse represents a single result of the Batch Verify Addresses endpoint.
 type VerifiedAddressResponse struct {
 	VerifiedAddress VerifiedAddress `json:"verifiedAddress"`
+	// InputID echoes the InputID of the corresponding request address, when postgrid returns it. Results
+	// served from the cache or shared by duplicate addresses always carry the InputID of their address.
+	InputID string `json:"inputID,omitempty"`
 }
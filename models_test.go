@@ -61,3 +61,62 @@ func TestAddress_MarshalJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestAddress_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		into    Address
+		data    string
+		want    Address
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "success with string",
+			data:    `"101 place st, NY"`,
+			want:    Address{String: "101 place st, NY"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "success with object",
+			data:    `{"line1":"101 place st","city":"NY","inputID":"1"}`,
+			want:    Address{Line1: "101 place st", City: "NY", InputID: "1"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "string into a reused address",
+			into:    Address{Line1: "old", InputID: "0"},
+			data:    `"101 place st, NY"`,
+			want:    Address{String: "101 place st, NY"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "object into a reused address",
+			into:    Address{String: "old", Line2: "old"},
+			data:    `{"line1":"101 place st","city":"NY","inputID":"1"}`,
+			want:    Address{Line1: "101 place st", City: "NY", InputID: "1"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "invalid",
+			data:    `[1]`,
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.into
+			err := got.UnmarshalJSON([]byte(tt.data))
+			if !tt.wantErr(t, err, "UnmarshalJSON()") {
+				return
+			}
+			assert.Equal(t, tt.want, got)
+
+			// Round trips through MarshalJSON.
+			if err == nil {
+				b, err := got.MarshalJSON()
+				assert.NoError(t, err)
+				assert.JSONEq(t, tt.data, string(b))
+			}
+		})
+	}
+}
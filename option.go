@@ -1,14 +1,32 @@
 package postgrid
 
 import (
+	"log/slog"
 	"net/http"
+	"time"
 
 	"golang.org/x/time/rate"
 )
 
 type options struct {
-	httpClient  *http.Client
-	rateLimiter *rate.Limiter
+	httpClient           *http.Client
+	middlewares          []Middleware
+	rateLimiter          *rate.Limiter
+	endpointRateLimiters map[string]*rate.Limiter
+	adaptive             bool
+	adaptiveMin          rate.Limit
+	adaptiveRampUp       rate.Limit
+	cache                Cache
+	cacheTTL             time.Duration
+	cacheNegativeTTL     time.Duration
+	coalescing           bool
+	retryAttempts        int
+	retryBackoff         time.Duration
+	progressHooks        []ProgressHook
+	requestHooks         []RequestHook
+	metrics              Metrics
+	logger               *slog.Logger
+	logRedaction         LogRedaction
 }
 
 // Option represents optional arguments for constructing a postgrid client.
@@ -29,6 +47,25 @@ func WithHTTPClient(client *http.Client) Option {
 	return httpClientOption{client: client}
 }
 
+type middlewareOption struct {
+	middlewares []Middleware
+}
+
+func (m middlewareOption) apply(opts *options) {
+	opts.middlewares = append(opts.middlewares, m.middlewares...)
+}
+
+// WithMiddleware wraps the http exchange of the client with the middlewares, such as UserAgent, RequestID
+// and Timing. It may be given several times; the first middleware given is the outermost.
+//
+// Middlewares run once per attempt, after the rate limiter wait and with the api key header already set,
+// and wrap the http.Client configured WithHTTPClient. A retried request runs through the middlewares
+// again, with the headers set by the previous attempt. Responses are decoded, logged and reported to
+// hooks and metrics after the middlewares return.
+func WithMiddleware(middlewares ...Middleware) Option {
+	return middlewareOption{middlewares: middlewares}
+}
+
 type rateLimiterOption struct {
 	limiter *rate.Limiter
 }
@@ -37,7 +74,202 @@ func (r rateLimiterOption) apply(opts *options) {
 	opts.rateLimiter = r.limiter
 }
 
-// WithRateLimiter configures the postgrid client to use the given rate limiter.
+// WithRateLimiter configures the postgrid client to use the given rate limiter. Waiting requests are
+// granted its tokens by Priority, see WithPriority.
 func WithRateLimiter(limiter *rate.Limiter) Option {
 	return rateLimiterOption{limiter: limiter}
 }
+
+type endpointRateLimiterOption struct {
+	endpoint string
+	limiter  *rate.Limiter
+}
+
+func (e endpointRateLimiterOption) apply(opts *options) {
+	if opts.endpointRateLimiters == nil {
+		opts.endpointRateLimiters = map[string]*rate.Limiter{}
+	}
+	opts.endpointRateLimiters[e.endpoint] = e.limiter
+}
+
+// WithEndpointRateLimiter configures a rate limiter for the requests to endpoint, such as
+// EndpointBatchVerify, in addition to the rate limiter of the client which keeps bounding all requests.
+//
+// Requests waiting for a rate limiter are sent by Priority, then in order of arrival, so that
+// interactive requests preempt queued background requests. The priority defaults to PriorityInteractive
+// for VerifyAddress and PriorityBackground for BatchVerifyAddresses, and is set with WithPriority.
+func WithEndpointRateLimiter(endpoint string, limiter *rate.Limiter) Option {
+	return endpointRateLimiterOption{endpoint: endpoint, limiter: limiter}
+}
+
+type adaptiveRateLimitOption struct {
+	minLimit rate.Limit
+	rampUp   rate.Limit
+}
+
+func (a adaptiveRateLimitOption) apply(opts *options) {
+	opts.adaptive = true
+	opts.adaptiveMin = a.minLimit
+	opts.adaptiveRampUp = a.rampUp
+}
+
+// WithAdaptiveRateLimit configures the client to adjust the rate of its rate limiter from the responses of
+// postgrid. On a 429 status, or when the rate limit headers report no remaining requests, the rate is
+// multiplied by AdaptiveDecrease down to minLimit and requests are paused until the time given by the
+// Retry-After or rate limit reset header. The rate then increases by rampUp requests per second every
+// second, back up to the initial rate of the limiter. Use RateLimit to observe the current rate; reductions
+// are also reported as ProgressRateLimitReduced events.
+//
+// The client adjusts its own copy of the limiter configured WithRateLimiter, which is left unchanged and is
+// no longer shared with other clients using it.
+func WithAdaptiveRateLimit(minLimit, rampUp rate.Limit) Option {
+	return adaptiveRateLimitOption{minLimit: minLimit, rampUp: rampUp}
+}
+
+type cacheOption struct {
+	cache Cache
+}
+
+func (c cacheOption) apply(opts *options) {
+	opts.cache = c.cache
+}
+
+// WithCache configures the postgrid client to serve repeated verifications of the same address from the
+// given cache. Results are cached for DefaultCacheTTL, or DefaultCacheNegativeTTL for failed verifications,
+// unless configured otherwise with WithCacheTTL.
+func WithCache(cache Cache) Option {
+	return cacheOption{cache: cache}
+}
+
+type cacheTTLOption struct {
+	ttl         time.Duration
+	negativeTTL time.Duration
+}
+
+func (c cacheTTLOption) apply(opts *options) {
+	opts.cacheTTL = c.ttl
+	opts.cacheNegativeTTL = c.negativeTTL
+}
+
+// WithCacheTTL configures how long verification results are cached. negativeTTL applies to results with
+// a failed status. A TTL of zero disables caching of the corresponding results.
+func WithCacheTTL(ttl, negativeTTL time.Duration) Option {
+	return cacheTTLOption{ttl: ttl, negativeTTL: negativeTTL}
+}
+
+type requestCoalescingOption struct {
+	enabled bool
+}
+
+func (r requestCoalescingOption) apply(opts *options) {
+	opts.coalescing = r.enabled
+}
+
+// WithRequestCoalescing configures whether concurrent identical verification requests share a single
+// in-flight api call and its result. It is disabled by default. Callers sharing a result share its Errors
+// map and must not modify it.
+//
+// The shared call runs with the values of the context of its first caller, such as its request hooks and
+// trace, and is only canceled once every caller has given up. It is scheduled with the highest priority of
+// its waiting callers, raised when an interactive caller joins a background call, and canceled once the
+// latest deadline of its waiting callers has passed. Its context carries neither, as they change while
+// callers join and leave, so hooks and middleware see the context of the first caller without a deadline.
+func WithRequestCoalescing(enabled bool) Option {
+	return requestCoalescingOption{enabled: enabled}
+}
+
+type retryOption struct {
+	maxAttempts int
+	backoff     time.Duration
+}
+
+func (r retryOption) apply(opts *options) {
+	opts.retryAttempts = r.maxAttempts
+	opts.retryBackoff = r.backoff
+}
+
+// WithRetry configures the client to retry requests failing with a network error, a postgrid timeout or a
+// 429, 502, 503 or 504 status, making at most maxAttempts attempts in total. The wait before a retry starts
+// at backoff and doubles after every attempt, up to a minute or backoff when longer. Retries are disabled
+// by default.
+//
+// Verification requests are POST requests that are billed once processed by postgrid. A request failing
+// with a network error or a timeout may have been processed, so retrying it may bill the verification
+// twice.
+func WithRetry(maxAttempts int, backoff time.Duration) Option {
+	return retryOption{maxAttempts: maxAttempts, backoff: backoff}
+}
+
+type progressHookOption struct {
+	hook ProgressHook
+}
+
+func (p progressHookOption) apply(opts *options) {
+	opts.progressHooks = append(opts.progressHooks, p.hook)
+}
+
+// WithProgressHook registers a hook receiving the ProgressEvents of the client. It may be given several
+// times to register several hooks.
+func WithProgressHook(hook ProgressHook) Option {
+	return progressHookOption{hook: hook}
+}
+
+type requestHookOption struct {
+	hook RequestHook
+}
+
+func (r requestHookOption) apply(opts *options) {
+	opts.requestHooks = append(opts.requestHooks, r.hook)
+}
+
+// WithRequestHook registers a hook receiving the RequestInfo of every http request attempt, for
+// instrumentation such as tracing. It may be given several times to register several hooks.
+func WithRequestHook(hook RequestHook) Option {
+	return requestHookOption{hook: hook}
+}
+
+type metricsOption struct {
+	metrics Metrics
+}
+
+func (m metricsOption) apply(opts *options) {
+	opts.metrics = m.metrics
+}
+
+// WithMetrics configures the client to report its request counts, latencies, rate limiter waits,
+// verification results and cache lookups to metrics. It defaults to NopMetrics, also used when metrics is
+// nil.
+func WithMetrics(metrics Metrics) Option {
+	return metricsOption{metrics: metrics}
+}
+
+type loggerOption struct {
+	logger *slog.Logger
+}
+
+func (l loggerOption) apply(opts *options) {
+	opts.logger = l.logger
+}
+
+// WithLogger configures the client to log every http request attempt: at debug level when it succeeds and
+// at warn level when it fails. Requests are logged with their method, path, status code, latency, rate
+// limit wait and envelope message, and at debug level with their headers and address. The api key and the
+// response bodies of errors are never logged, see RedactedError, and addresses are redacted according to
+// WithLogRedaction.
+func WithLogger(logger *slog.Logger) Option {
+	return loggerOption{logger: logger}
+}
+
+type logRedactionOption struct {
+	redaction LogRedaction
+}
+
+func (l logRedactionOption) apply(opts *options) {
+	opts.logRedaction = l.redaction
+}
+
+// WithLogRedaction configures which address fields are redacted from logs. It defaults to
+// LogRedactAddressLines.
+func WithLogRedaction(redaction LogRedaction) Option {
+	return logRedactionOption{redaction: redaction}
+}
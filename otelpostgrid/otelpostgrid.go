@@ -0,0 +1,242 @@
+// Package otelpostgrid instruments the postgrid client with OpenTelemetry tracing. It is a separate module
+// so that the postgrid module does not depend on OpenTelemetry.
+//
+// Tracing creates a span for every VerifyAddress and BatchVerifyAddresses call of the verifier it wraps and
+// records every http request attempt of the client on the span of its call:
+//
+//	tracing := otelpostgrid.New(otelpostgrid.WithTracerProvider(tp))
+//	client := postgrid.NewClient(apiKey, postgrid.BaseURL, tracing.ClientOption())
+//	verifier := tracing.Wrap(client)
+package otelpostgrid
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	postgrid "github.com/bloomcredit/bloomcredit-postgrid-sdk"
+)
+
+// ScopeName is the instrumentation scope name of the tracer.
+const ScopeName = "github.com/bloomcredit/bloomcredit-postgrid-sdk/otelpostgrid"
+
+// Attribute keys set on spans and request events.
+const (
+	// AttrEndpoint is the endpoint of the call, postgrid.EndpointVerify or postgrid.EndpointBatchVerify.
+	AttrEndpoint = attribute.Key("postgrid.endpoint")
+	// AttrBatchSize is the number of addresses of a BatchVerifyAddresses call.
+	AttrBatchSize = attribute.Key("postgrid.batch_size")
+	// AttrStatusPrefix prefixes the number of results of a call per VerifiedAddress.Status, e.g.
+	// postgrid.status.verified.
+	AttrStatusPrefix = "postgrid.status."
+	// AttrCacheHits is the number of results of a call served from the client's cache.
+	AttrCacheHits = attribute.Key("postgrid.cache_hits")
+	// AttrAttempts is the number of http request attempts of a call and AttrRetries the number of retries
+	// among them.
+	AttrAttempts = attribute.Key("postgrid.attempts")
+	AttrRetries  = attribute.Key("postgrid.retries")
+	// AttrRateLimitWait is the time in milliseconds spent waiting for the client's rate limiter.
+	AttrRateLimitWait = attribute.Key("postgrid.rate_limit_wait_ms")
+	// AttrLatency is the duration in milliseconds of an http exchange.
+	AttrLatency = attribute.Key("postgrid.latency_ms")
+	// AttrAttempt is the number of an http request attempt, starting at 1.
+	AttrAttempt = attribute.Key("postgrid.attempt")
+	// AttrStatusCode is the http status code of the last attempt of a call.
+	AttrStatusCode = attribute.Key("http.response.status_code")
+)
+
+// RequestEvent is the name of the span event recorded for every http request attempt.
+const RequestEvent = "postgrid.request"
+
+// Tracing creates spans for postgrid calls.
+type Tracing struct {
+	tracer trace.Tracer
+}
+
+type options struct {
+	tracerProvider trace.TracerProvider
+}
+
+// Option configures Tracing.
+type Option interface {
+	apply(*options)
+}
+
+type tracerProviderOption struct {
+	provider trace.TracerProvider
+}
+
+func (t tracerProviderOption) apply(opts *options) {
+	opts.tracerProvider = t.provider
+}
+
+// WithTracerProvider configures the tracer provider spans are created with. It defaults to the global
+// tracer provider.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return tracerProviderOption{provider: provider}
+}
+
+// New constructs a Tracing.
+func New(opts ...Option) *Tracing {
+	options := options{}
+	for _, opt := range opts {
+		opt.apply(&options)
+	}
+	if options.tracerProvider == nil {
+		options.tracerProvider = otel.GetTracerProvider()
+	}
+
+	return &Tracing{tracer: options.tracerProvider.Tracer(ScopeName)}
+}
+
+// ClientOption returns the postgrid client option recording the http request attempts of the client on
+// the span of the context of the request, as a RequestEvent. Spans created by Wrap also get the number of
+// attempts, retries and the rate limiter wait of their call.
+//
+// A call sharing the in-flight request of a concurrent identical call, see postgrid.WithRequestCoalescing,
+// records no attempts as they are recorded by the call that made the request.
+func (t *Tracing) ClientOption() postgrid.Option {
+	return postgrid.WithRequestHook(t.recordRequest)
+}
+
+// Wrap returns an AddressVerifier creating a span for every call of v. The span is a child of the span of
+// the caller's context and is passed to v through the context.
+func (t *Tracing) Wrap(v postgrid.AddressVerifier) postgrid.AddressVerifier {
+	return &verifier{AddressVerifier: v, tracing: t}
+}
+
+type verifier struct {
+	postgrid.AddressVerifier
+	tracing *Tracing
+}
+
+// VerifyAddress calls VerifyAddress of the wrapped verifier within a span.
+func (v *verifier) VerifyAddress(ctx context.Context, req postgrid.VerifyAddressRequest) (postgrid.VerifiedAddress, error) {
+	ctx, call := v.tracing.start(ctx, "postgrid.VerifyAddress", postgrid.EndpointVerify)
+	resp, err := v.AddressVerifier.VerifyAddress(ctx, req)
+	if err == nil {
+		call.addResult(resp)
+	}
+	call.end(err)
+
+	return resp, err
+}
+
+// BatchVerifyAddresses calls BatchVerifyAddresses of the wrapped verifier within a span.
+func (v *verifier) BatchVerifyAddresses(ctx context.Context, req postgrid.BatchVerifyAddressesRequest) (postgrid.BatchVerifyAddressesResponse, error) {
+	ctx, call := v.tracing.start(ctx, "postgrid.BatchVerifyAddresses", postgrid.EndpointBatchVerify,
+		AttrBatchSize.Int(len(req.Addresses)))
+	resp, err := v.AddressVerifier.BatchVerifyAddresses(ctx, req)
+	if err == nil {
+		for _, result := range resp.Results {
+			call.addResult(result.VerifiedAddress)
+		}
+	}
+	call.end(err)
+
+	return resp, err
+}
+
+// callKey is the context key of the call of a span created by Wrap.
+type callKey struct{}
+
+// call accumulates the results and request attempts of a span created by Wrap.
+type call struct {
+	span trace.Span
+
+	statusCounts map[string]int
+	cacheHits    int
+
+	mu       sync.Mutex
+	attempts int
+	wait     time.Duration
+	status   int
+}
+
+// start starts the span of a call.
+func (t *Tracing) start(ctx context.Context, name, endpoint string, attrs ...attribute.KeyValue) (context.Context, *call) {
+	attrs = append([]attribute.KeyValue{AttrEndpoint.String(endpoint)}, attrs...)
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+
+	c := &call{span: span, statusCounts: map[string]int{}}
+	return context.WithValue(ctx, callKey{}, c), c
+}
+
+// recordRequest records a single http request attempt.
+func (t *Tracing) recordRequest(ctx context.Context, info postgrid.RequestInfo) {
+	span := trace.SpanFromContext(ctx)
+	if span.IsRecording() {
+		attrs := []attribute.KeyValue{
+			AttrEndpoint.String(info.Endpoint),
+			AttrAttempt.Int(info.Attempt),
+			AttrRateLimitWait.Float64(milliseconds(info.RateLimitWait)),
+			AttrLatency.Float64(milliseconds(info.Latency)),
+		}
+		if info.StatusCode != 0 {
+			attrs = append(attrs, AttrStatusCode.Int(info.StatusCode))
+		}
+		if info.Err != nil {
+			attrs = append(attrs, attribute.String("error", postgrid.RedactedError(info.Err)))
+		}
+		span.AddEvent(RequestEvent, trace.WithAttributes(attrs...))
+	}
+
+	if c, ok := ctx.Value(callKey{}).(*call); ok {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.attempts++
+		c.wait += info.RateLimitWait
+		c.status = info.StatusCode
+	}
+}
+
+// addResult counts a result of the call.
+func (c *call) addResult(v postgrid.VerifiedAddress) {
+	c.statusCounts[v.Status]++
+	if v.CacheInfo != nil {
+		c.cacheHits++
+	}
+}
+
+// end sets the attributes of the call and ends its span.
+func (c *call) end(err error) {
+	c.mu.Lock()
+	attrs := []attribute.KeyValue{
+		AttrAttempts.Int(c.attempts),
+		AttrRetries.Int(max(c.attempts-1, 0)),
+		AttrRateLimitWait.Float64(milliseconds(c.wait)),
+	}
+	if c.status != 0 {
+		attrs = append(attrs, AttrStatusCode.Int(c.status))
+	}
+	c.mu.Unlock()
+
+	if err != nil {
+		// The error is recorded like span.RecordError, with the response body of its text redacted.
+		message := postgrid.RedactedError(err)
+		c.span.AddEvent(semconv.ExceptionEventName, trace.WithAttributes(
+			semconv.ExceptionType(fmt.Sprintf("%T", err)),
+			semconv.ExceptionMessage(message),
+		))
+		c.span.SetStatus(codes.Error, message)
+	} else {
+		for status, n := range c.statusCounts {
+			attrs = append(attrs, attribute.Int(AttrStatusPrefix+status, n))
+		}
+		attrs = append(attrs, AttrCacheHits.Int(c.cacheHits))
+	}
+
+	c.span.SetAttributes(attrs...)
+	c.span.End()
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,159 @@
+package otelpostgrid
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	postgrid "github.com/bloomcredit/bloomcredit-postgrid-sdk"
+	"github.com/bloomcredit/bloomcredit-postgrid-sdk/postgridtest"
+)
+
+// newTestVerifier returns a traced verifier for srv and the recorder of its spans.
+func newTestVerifier(t *testing.T, srv *postgridtest.Server) (postgrid.AddressVerifier, *tracetest.SpanRecorder, trace.Tracer) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	tracing := New(WithTracerProvider(provider))
+	client := srv.Client(postgrid.WithRetry(3, time.Millisecond), tracing.ClientOption())
+
+	return tracing.Wrap(client), recorder, provider.Tracer("test")
+}
+
+// spanAttributes returns the attributes of the span by key.
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+
+	return attrs
+}
+
+func TestTracing_VerifyAddress(t *testing.T) {
+	dataset := postgridtest.GenerateAddresses(1, 10)
+	srv := postgridtest.NewServer(postgridtest.WithAddresses(dataset...))
+	t.Cleanup(srv.Close)
+	srv.InjectFaults(postgridtest.FaultBadGateway)
+
+	verifier, recorder, tracer := newTestVerifier(t, srv)
+	ctx, parent := tracer.Start(context.Background(), "parent")
+
+	v := dataset[0]
+	_, err := verifier.VerifyAddress(ctx, postgrid.VerifyAddressRequest{Address: postgrid.Address{
+		Line1: v.Line1, Line2: v.Line2, City: v.City, ProvinceOrState: v.ProvinceOrState, PostalOrZip: v.PostalOrZip,
+	}})
+	require.NoError(t, err)
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	span := spans[0]
+	assert.Equal(t, "postgrid.VerifyAddress", span.Name())
+	assert.Equal(t, trace.SpanKindClient, span.SpanKind())
+	assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+	assert.Equal(t, codes.Unset, span.Status().Code)
+
+	attrs := spanAttributes(span)
+	assert.Equal(t, "/addver/verifications", attrs[AttrEndpoint].AsString())
+	assert.Equal(t, int64(2), attrs[AttrAttempts].AsInt64())
+	assert.Equal(t, int64(1), attrs[AttrRetries].AsInt64())
+	assert.Equal(t, int64(200), attrs[AttrStatusCode].AsInt64())
+	assert.Equal(t, int64(1), attrs[AttrStatusPrefix+postgrid.VerificationStatusVerified].AsInt64())
+	assert.Equal(t, int64(0), attrs[AttrCacheHits].AsInt64())
+	assert.Contains(t, attrs, AttrRateLimitWait)
+
+	events := span.Events()
+	require.Len(t, events, 2)
+	for i, ev := range events {
+		assert.Equal(t, RequestEvent, ev.Name)
+		assert.Contains(t, ev.Attributes, AttrAttempt.Int(i+1))
+		assert.Contains(t, ev.Attributes, AttrEndpoint.String("/addver/verifications"))
+	}
+	assert.Contains(t, events[0].Attributes, AttrStatusCode.Int(502))
+}
+
+func TestTracing_BatchVerifyAddresses(t *testing.T) {
+	dataset := postgridtest.GenerateAddresses(1, 10)
+	srv := postgridtest.NewServer(postgridtest.WithAddresses(dataset...))
+	t.Cleanup(srv.Close)
+
+	verifier, recorder, _ := newTestVerifier(t, srv)
+
+	var addresses []postgrid.Address
+	for _, v := range dataset[:3] {
+		addresses = append(addresses, postgrid.Address{
+			Line1: v.Line1, Line2: v.Line2, City: v.City, ProvinceOrState: v.ProvinceOrState, PostalOrZip: v.PostalOrZip,
+		})
+	}
+	addresses = append(addresses, postgrid.Address{Line1: "1 nowhere rd", City: "Nowhere", ProvinceOrState: "ZZ"})
+
+	_, err := verifier.BatchVerifyAddresses(context.Background(), postgrid.BatchVerifyAddressesRequest{Addresses: addresses})
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "postgrid.BatchVerifyAddresses", spans[0].Name())
+
+	attrs := spanAttributes(spans[0])
+	assert.Equal(t, "/addver/verifications/batch", attrs[AttrEndpoint].AsString())
+	assert.Equal(t, int64(4), attrs[AttrBatchSize].AsInt64())
+	assert.Equal(t, int64(1), attrs[AttrAttempts].AsInt64())
+	assert.Equal(t, int64(0), attrs[AttrRetries].AsInt64())
+	assert.Equal(t, int64(3), attrs[AttrStatusPrefix+postgrid.VerificationStatusVerified].AsInt64())
+	assert.Equal(t, int64(1), attrs[AttrStatusPrefix+postgrid.VerificationStatusFailed].AsInt64())
+}
+
+func TestTracing_Error(t *testing.T) {
+	srv := postgridtest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.InjectFaults(postgridtest.FaultErrorEnvelope)
+
+	verifier, recorder, _ := newTestVerifier(t, srv)
+
+	_, err := verifier.VerifyAddress(context.Background(), postgrid.VerifyAddressRequest{Address: postgrid.Address{Line1: "A"}})
+	require.Error(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Equal(t, err.Error(), spans[0].Status().Description)
+	assert.Equal(t, int64(1), spanAttributes(spans[0])[AttrAttempts].AsInt64())
+}
+
+func TestTracing_TruncatedBody(t *testing.T) {
+	srv := postgridtest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.InjectFaults(postgridtest.FaultTruncatedBody)
+
+	verifier, recorder, _ := newTestVerifier(t, srv)
+
+	address := postgrid.Address{Line1: "9880 Lake Rd", Line2: "Apt 15", City: "Cleveland", ProvinceOrState: "OH", PostalOrZip: "44102"}
+	_, err := verifier.VerifyAddress(context.Background(), postgrid.VerifyAddressRequest{Address: address})
+	var decodeErr *postgrid.DecodeError
+	require.ErrorAs(t, err, &decodeErr)
+	assert.Empty(t, decodeErr.Body)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.NotContains(t, strings.ToUpper(spans[0].Status().Description), "LAKE RD")
+	events := spans[0].Events()
+	require.NotEmpty(t, events)
+	assert.Equal(t, "exception", events[len(events)-1].Name)
+	for _, event := range events {
+		for _, attr := range event.Attributes {
+			assert.NotContains(t, strings.ToUpper(attr.Value.Emit()), "LAKE RD", "attribute %s of event %s", attr.Key, event.Name)
+		}
+	}
+}
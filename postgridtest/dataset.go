@@ -0,0 +1,73 @@
+package postgridtest
+
+import (
+	"fmt"
+	"math/rand"
+
+	postgrid "github.com/bloomcredit/bloomcredit-postgrid-sdk"
+)
+
+type place struct {
+	city, state, zip, county string
+	lat, lng                 float64
+}
+
+var (
+	places = []place{
+		{"NEW YORK", "NY", "10003", "NEW YORK", 40.7322, -73.9874},
+		{"BROOKLYN", "NY", "11201", "KINGS", 40.6943, -73.9903},
+		{"CHICAGO", "IL", "60601", "COOK", 41.8857, -87.6228},
+		{"AUSTIN", "TX", "78701", "TRAVIS", 30.2711, -97.7437},
+		{"SEATTLE", "WA", "98101", "KING", 47.6114, -122.3305},
+		{"DENVER", "CO", "80202", "DENVER", 39.7528, -104.9992},
+	}
+	streetNames = []string{"MAIN", "OAK", "PINE", "MAPLE", "CEDAR", "ELM", "WASHINGTON", "LAKE", "HILL", "PARK"}
+	streetTypes = []string{"ST", "AVE", "BLVD", "DR", "LN", "RD"}
+)
+
+// GenerateAddresses returns n synthetic US verified addresses. The same seed always generates the same
+// addresses, so tests can derive request addresses from them.
+func GenerateAddresses(seed int64, n int) []postgrid.VerifiedAddress {
+	r := rand.New(rand.NewSource(seed))
+
+	addresses := make([]postgrid.VerifiedAddress, n)
+	for i := range addresses {
+		p := places[r.Intn(len(places))]
+		number := 1 + r.Intn(9999)
+		name := streetNames[r.Intn(len(streetNames))]
+		typ := streetTypes[r.Intn(len(streetTypes))]
+
+		v := postgrid.VerifiedAddress{
+			Line1:           fmt.Sprintf("%d %s %s", number, name, typ),
+			City:            p.city,
+			ProvinceOrState: p.state,
+			PostalOrZip:     p.zip,
+			ZipPlus4:        fmt.Sprintf("%04d", r.Intn(10000)),
+			Country:         "us",
+			Details: postgrid.VerifiedAddressDetails{
+				StreetNumber:            fmt.Sprint(number),
+				StreetName:              name,
+				StreetType:              typ,
+				County:                  p.county,
+				Residential:             r.Intn(4) != 0,
+				USMailingsDeliveryPoint: fmt.Sprintf("%02d", number%100),
+				USMailingsCheckDigit:    fmt.Sprint(r.Intn(10)),
+			},
+			GeocodeResult: postgrid.GeocodeResult{
+				Location: postgrid.GeocodeLocation{
+					Latitude:  p.lat + (r.Float64()-0.5)/50,
+					Longitude: p.lng + (r.Float64()-0.5)/50,
+				},
+				Accuracy:     1,
+				AccuracyType: postgrid.AccuracyTypeRooftop,
+			},
+		}
+		if r.Intn(5) == 0 {
+			v.Line2 = fmt.Sprintf("APT %d", 1+r.Intn(30))
+			v.Details.SuiteKey, v.Details.SuiteID = "APT", v.Line2[4:]
+		}
+		addresses[i] = v
+	}
+
+	return addresses
+}
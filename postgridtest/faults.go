@@ -0,0 +1,204 @@
+package postgridtest
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// FaultTransport is an http.RoundTripper injecting faults between the postgrid client and postgrid, for
+// use with postgrid.WithHTTPClient. Each request first takes the next fault of the sequence, then, once the
+// sequence is exhausted, a fault drawn at the configured rates.
+type FaultTransport struct {
+	next http.RoundTripper
+
+	mu       sync.Mutex
+	rng      *rand.Rand
+	sequence []Fault
+	rates    []faultRate
+	latency  time.Duration
+	jitter   time.Duration
+	injected map[Fault]int
+}
+
+type faultRate struct {
+	fault Fault
+	rate  float64
+}
+
+// FaultOption configures a FaultTransport.
+type FaultOption interface {
+	applyFault(*FaultTransport)
+}
+
+type faultSequenceOption []Fault
+
+func (o faultSequenceOption) applyFault(t *FaultTransport) {
+	t.sequence = append(t.sequence, o...)
+}
+
+// WithFaultSequence injects the faults, one per request, in order. FaultNone lets a request through.
+func WithFaultSequence(faults ...Fault) FaultOption {
+	return faultSequenceOption(faults)
+}
+
+type faultRateOption faultRate
+
+func (o faultRateOption) applyFault(t *FaultTransport) {
+	t.rates = append(t.rates, faultRate(o))
+}
+
+// WithFaultRate injects the fault into the given fraction of requests, between 0 and 1. The rates of
+// several faults add up.
+func WithFaultRate(fault Fault, rate float64) FaultOption {
+	return faultRateOption{fault: fault, rate: rate}
+}
+
+type latencyOption struct {
+	latency time.Duration
+	jitter  time.Duration
+}
+
+func (o latencyOption) applyFault(t *FaultTransport) {
+	t.latency = o.latency
+	t.jitter = o.jitter
+}
+
+// WithLatency delays every request by latency plus a random duration up to jitter.
+func WithLatency(latency, jitter time.Duration) FaultOption {
+	return latencyOption{latency: latency, jitter: jitter}
+}
+
+type faultSeedOption int64
+
+func (o faultSeedOption) applyFault(t *FaultTransport) {
+	t.rng = rand.New(rand.NewSource(int64(o)))
+}
+
+// WithFaultSeed seeds the random draws of faults and jitter. It defaults to 1, so runs are reproducible.
+func WithFaultSeed(seed int64) FaultOption {
+	return faultSeedOption(seed)
+}
+
+// NewFaultTransport returns a FaultTransport sending the requests it lets through with next, or
+// http.DefaultTransport when next is nil.
+func NewFaultTransport(next http.RoundTripper, opts ...FaultOption) *FaultTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	t := &FaultTransport{
+		next:     next,
+		rng:      rand.New(rand.NewSource(1)),
+		injected: map[Fault]int{},
+	}
+	for _, opt := range opts {
+		opt.applyFault(t)
+	}
+
+	return t
+}
+
+// Client returns an http.Client using the FaultTransport.
+func (t *FaultTransport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
+
+// Injected returns the number of requests that received each fault, including FaultNone.
+func (t *FaultTransport) Injected() map[Fault]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	injected := make(map[Fault]int, len(t.injected))
+	for fault, n := range t.injected {
+		injected[fault] = n
+	}
+
+	return injected
+}
+
+// RoundTrip injects the next fault into the request.
+func (t *FaultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fault, delay := t.draw()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			closeBody(req)
+			return nil, req.Context().Err()
+		}
+	}
+
+	switch fault {
+	case FaultNone:
+		return t.next.RoundTrip(req)
+	case FaultConnectionReset:
+		closeBody(req)
+		return nil, &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+	case FaultTruncatedBody:
+		resp, err := t.next.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body[:len(body)/2]))
+		resp.ContentLength = int64(len(body) / 2)
+		resp.Header.Del("Content-Length")
+		return resp, nil
+	}
+
+	closeBody(req)
+	rec := httptest.NewRecorder()
+	writeFault(rec, fault)
+	resp := rec.Result()
+	resp.Request = req
+
+	return resp, nil
+}
+
+// draw draws the fault and latency of the next request.
+func (t *FaultTransport) draw() (Fault, time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fault := FaultNone
+	if len(t.sequence) > 0 {
+		fault, t.sequence = t.sequence[0], t.sequence[1:]
+	} else if len(t.rates) > 0 {
+		draw := t.rng.Float64()
+		for _, r := range t.rates {
+			if draw < r.rate {
+				fault = r.fault
+				break
+			}
+			draw -= r.rate
+		}
+	}
+	t.injected[fault]++
+
+	delay := t.latency
+	if t.jitter > 0 {
+		delay += time.Duration(t.rng.Int63n(int64(t.jitter)))
+	}
+
+	return fault, delay
+}
+
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
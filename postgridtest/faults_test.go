@@ -0,0 +1,145 @@
+package postgridtest
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+
+	postgrid "github.com/bloomcredit/bloomcredit-postgrid-sdk"
+)
+
+func TestFaultTransport(t *testing.T) {
+	tests := []struct {
+		fault   Fault
+		wantErr string
+	}{
+		{FaultTimeout, "postgrid timeout status 524"},
+		{FaultTooManyRequests, "Too many requests"},
+		{FaultMalformedEnvelope, "error decoding response envelope"},
+		{FaultErrorEnvelope, "Injected error"},
+		{FaultBadGateway, "received string response: <html>"},
+		{FaultConnectionReset, "connection reset by peer"},
+		{FaultTruncatedBody, "unexpected end of JSON input"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fault.String(), func(t *testing.T) {
+			srv := NewServer()
+			t.Cleanup(srv.Close)
+
+			ft := NewFaultTransport(srv.HTTPClient().Transport, WithFaultSequence(tt.fault))
+			client := srv.Client(postgrid.WithHTTPClient(ft.Client()))
+
+			_, err := client.VerifyAddress(context.Background(), postgrid.VerifyAddressRequest{Address: postgrid.Address{String: "a"}})
+			assert.ErrorContains(t, err, tt.wantErr)
+			if tt.fault == FaultConnectionReset {
+				assert.ErrorIs(t, err, syscall.ECONNRESET)
+			}
+
+			// The sequence is exhausted, so the next request goes through.
+			_, err = client.VerifyAddress(context.Background(), postgrid.VerifyAddressRequest{Address: postgrid.Address{String: "a"}})
+			assert.NoError(t, err)
+			assert.Equal(t, map[Fault]int{tt.fault: 1, FaultNone: 1}, ft.Injected())
+		})
+	}
+}
+
+func TestFaultTransport_TruncatedBody(t *testing.T) {
+	srv := NewServer()
+	t.Cleanup(srv.Close)
+
+	ft := NewFaultTransport(srv.HTTPClient().Transport, WithFaultSequence(FaultTruncatedBody))
+	client := srv.Client(postgrid.WithHTTPClient(ft.Client()))
+
+	addresses := []postgrid.Address{
+		{Line1: "9880 Lake Rd", Line2: "Apt 15", City: "Cleveland", ProvinceOrState: "OH", PostalOrZip: "44102"},
+		{Line1: "251 E 13th St", City: "New York", ProvinceOrState: "NY", PostalOrZip: "10003"},
+	}
+	_, err := client.BatchVerifyAddresses(context.Background(), postgrid.BatchVerifyAddressesRequest{Addresses: addresses})
+	var decodeErr *postgrid.DecodeError
+	require.ErrorAs(t, err, &decodeErr)
+	assert.Empty(t, decodeErr.Body)
+	for _, text := range []string{"LAKE RD", "APT 15", "13TH ST", "CLEVELAND"} {
+		assert.NotContains(t, strings.ToUpper(err.Error()), text)
+	}
+}
+
+func TestFaultTransport_ProgressReporters(t *testing.T) {
+	srv := NewServer()
+	t.Cleanup(srv.Close)
+
+	var terminal, log bytes.Buffer
+	reporter := postgrid.NewTerminalReporter(&terminal)
+	jsonLog := postgrid.NewJSONProgressLog(&log)
+	ft := NewFaultTransport(srv.HTTPClient().Transport, WithFaultSequence(FaultBadGateway, FaultTruncatedBody))
+	client := srv.Client(postgrid.WithHTTPClient(ft.Client()), postgrid.WithRetry(2, time.Millisecond),
+		postgrid.WithProgressHook(func(ev postgrid.ProgressEvent) {
+			reporter.Handle(ev)
+			jsonLog(ev)
+		}))
+
+	addresses := []postgrid.Address{{Line1: "9880 Lake Rd", Line2: "Apt 15", City: "Cleveland", ProvinceOrState: "OH", PostalOrZip: "44102"}}
+	_, err := client.BatchVerifyAddresses(context.Background(), postgrid.BatchVerifyAddressesRequest{Addresses: addresses})
+	require.Error(t, err)
+
+	for name, out := range map[string]string{"terminal": terminal.String(), "json": log.String()} {
+		// The page of the gateway is redacted from the retry, and the truncated response from the failed chunk.
+		assert.Contains(t, out, "received string response: [REDACTED]", name)
+		assert.NotContains(t, out, "<html>", name)
+		assert.Contains(t, out, "unexpected end of JSON input", name)
+		for _, text := range []string{"LAKE RD", "APT 15", "CLEVELAND"} {
+			assert.NotContains(t, strings.ToUpper(out), text, name)
+		}
+	}
+}
+
+func TestFaultTransport_Retry(t *testing.T) {
+	srv := NewServer()
+	t.Cleanup(srv.Close)
+
+	ft := NewFaultTransport(srv.HTTPClient().Transport, WithFaultSequence(FaultConnectionReset, FaultTimeout, FaultBadGateway))
+	client := srv.Client(postgrid.WithHTTPClient(ft.Client()), postgrid.WithRetry(4, time.Millisecond))
+
+	_, err := client.VerifyAddress(context.Background(), postgrid.VerifyAddressRequest{Address: postgrid.Address{String: "a"}})
+	require.NoError(t, err)
+	assert.Len(t, srv.Requests(), 1)
+}
+
+func TestFaultTransport_Rates(t *testing.T) {
+	srv := NewServer()
+	t.Cleanup(srv.Close)
+
+	run := func() map[Fault]int {
+		ft := NewFaultTransport(srv.HTTPClient().Transport, WithFaultRate(FaultBadGateway, 0.3), WithFaultRate(FaultErrorEnvelope, 0.2), WithFaultSeed(42))
+		client := srv.Client(postgrid.WithHTTPClient(ft.Client()))
+		for i := 0; i < 200; i++ {
+			_, _ = client.VerifyAddress(context.Background(), postgrid.VerifyAddressRequest{Address: postgrid.Address{String: "a"}})
+		}
+		return ft.Injected()
+	}
+
+	got := run()
+	assert.Equal(t, got, run())
+	assert.InDelta(t, 60, got[FaultBadGateway], 25)
+	assert.InDelta(t, 40, got[FaultErrorEnvelope], 20)
+	assert.Equal(t, 200, got[FaultNone]+got[FaultBadGateway]+got[FaultErrorEnvelope])
+}
+
+func TestFaultTransport_Latency(t *testing.T) {
+	srv := NewServer()
+	t.Cleanup(srv.Close)
+
+	ft := NewFaultTransport(srv.HTTPClient().Transport, WithLatency(time.Hour, 0))
+	client := postgrid.NewClient(DefaultAPIKey, srv.URL(), postgrid.WithHTTPClient(ft.Client()), postgrid.WithRateLimiter(rate.NewLimiter(rate.Inf, 0)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := client.VerifyAddress(ctx, postgrid.VerifyAddressRequest{Address: postgrid.Address{String: "a"}})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,247 @@
+package postgridtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	postgrid "github.com/bloomcredit/bloomcredit-postgrid-sdk"
+)
+
+// ErrUnexpectedCall is returned by a MockVerifier for a call matching no expectation.
+var ErrUnexpectedCall = errors.New("postgridtest: unexpected call")
+
+// Names of the mocked methods, used in Call.Method.
+const (
+	MethodVerifyAddress        = "VerifyAddress"
+	MethodBatchVerifyAddresses = "BatchVerifyAddresses"
+)
+
+// Call is a call received by a MockVerifier.
+type Call struct {
+	Method string
+	// VerifyRequest is set for MethodVerifyAddress calls, BatchRequest for MethodBatchVerifyAddresses.
+	VerifyRequest postgrid.VerifyAddressRequest
+	BatchRequest  postgrid.BatchVerifyAddressesRequest
+	// Expected reports whether the call matched an expectation.
+	Expected bool
+}
+
+var _ postgrid.AddressVerifier = (*MockVerifier)(nil)
+
+// MockVerifier is a programmable in-memory postgrid.AddressVerifier. Calls are answered by the first
+// expectation, in the order they were set, that matches the call and has calls left; other calls fail with
+// ErrUnexpectedCall. It is safe for concurrent use.
+type MockVerifier struct {
+	mu           sync.Mutex
+	expectations []*Expectation
+	calls        []Call
+}
+
+// NewMockVerifier returns a MockVerifier without expectations.
+func NewMockVerifier() *MockVerifier {
+	return &MockVerifier{}
+}
+
+// Expectation is an expected call of a MockVerifier and its scripted responses. It may be scripted while
+// the mock is in use.
+type Expectation struct {
+	// mu is the mutex of the MockVerifier, guarding the other fields.
+	mu           *sync.Mutex
+	method       string
+	matchVerify  func(postgrid.VerifyAddressRequest) bool
+	matchBatch   func(postgrid.BatchVerifyAddressesRequest) bool
+	responses    []response
+	verifyFunc   func(context.Context, postgrid.VerifyAddressRequest) (postgrid.VerifiedAddress, error)
+	batchFunc    func(context.Context, postgrid.BatchVerifyAddressesRequest) (postgrid.BatchVerifyAddressesResponse, error)
+	times, calls int
+}
+
+type response struct {
+	verified postgrid.VerifiedAddress
+	batch    postgrid.BatchVerifyAddressesResponse
+	err      error
+}
+
+// ExpectVerifyAddress expects VerifyAddress calls whose request satisfies match, or any call when match
+// is nil.
+func (m *MockVerifier) ExpectVerifyAddress(match func(postgrid.VerifyAddressRequest) bool) *Expectation {
+	return m.expect(&Expectation{method: MethodVerifyAddress, matchVerify: match})
+}
+
+// ExpectBatchVerifyAddresses expects BatchVerifyAddresses calls whose request satisfies match, or any call
+// when match is nil.
+func (m *MockVerifier) ExpectBatchVerifyAddresses(match func(postgrid.BatchVerifyAddressesRequest) bool) *Expectation {
+	return m.expect(&Expectation{method: MethodBatchVerifyAddresses, matchBatch: match})
+}
+
+func (m *MockVerifier) expect(e *Expectation) *Expectation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e.mu = &m.mu
+	m.expectations = append(m.expectations, e)
+
+	return e
+}
+
+// AddressEquals matches VerifyAddress requests for the given address.
+func AddressEquals(a postgrid.Address) func(postgrid.VerifyAddressRequest) bool {
+	return func(req postgrid.VerifyAddressRequest) bool {
+		return req.Address == a
+	}
+}
+
+// Return scripts the response of the next call of a VerifyAddress expectation. Scripted responses are
+// returned in order and the last one is repeated.
+func (e *Expectation) Return(v postgrid.VerifiedAddress, err error) *Expectation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.responses = append(e.responses, response{verified: v, err: err})
+
+	return e
+}
+
+// ReturnBatch scripts the response of the next call of a BatchVerifyAddresses expectation. Scripted
+// responses are returned in order and the last one is repeated.
+func (e *Expectation) ReturnBatch(resp postgrid.BatchVerifyAddressesResponse, err error) *Expectation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.responses = append(e.responses, response{batch: resp, err: err})
+
+	return e
+}
+
+// Do answers the calls of a VerifyAddress expectation with fn once its scripted responses are exhausted.
+func (e *Expectation) Do(fn func(context.Context, postgrid.VerifyAddressRequest) (postgrid.VerifiedAddress, error)) *Expectation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.verifyFunc = fn
+
+	return e
+}
+
+// DoBatch answers the calls of a BatchVerifyAddresses expectation with fn once its scripted responses are
+// exhausted.
+func (e *Expectation) DoBatch(fn func(context.Context, postgrid.BatchVerifyAddressesRequest) (postgrid.BatchVerifyAddressesResponse, error)) *Expectation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.batchFunc = fn
+
+	return e
+}
+
+// Times limits the expectation to n calls and requires them all for AssertExpectations. Without Times,
+// an expectation matches any number of calls, including none.
+func (e *Expectation) Times(n int) *Expectation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.times = n
+
+	return e
+}
+
+// next returns the response of the next call, preferring scripted responses over the Do functions.
+func (e *Expectation) next() (response, bool) {
+	i := e.calls
+	e.calls++
+	switch {
+	case i < len(e.responses):
+		return e.responses[i], true
+	case e.verifyFunc != nil || e.batchFunc != nil:
+		return response{}, false
+	case len(e.responses) > 0:
+		return e.responses[len(e.responses)-1], true
+	}
+
+	return response{}, true
+}
+
+// VerifyAddress answers the call from the first matching expectation.
+func (m *MockVerifier) VerifyAddress(ctx context.Context, req postgrid.VerifyAddressRequest) (postgrid.VerifiedAddress, error) {
+	m.mu.Lock()
+	e := m.find(MethodVerifyAddress, func(e *Expectation) bool { return e.matchVerify == nil || e.matchVerify(req) })
+	m.calls = append(m.calls, Call{Method: MethodVerifyAddress, VerifyRequest: req, Expected: e != nil})
+	if e == nil {
+		m.mu.Unlock()
+		return postgrid.VerifiedAddress{}, fmt.Errorf("%w: VerifyAddress(%+v)", ErrUnexpectedCall, req.Address)
+	}
+	resp, scripted := e.next()
+	fn := e.verifyFunc
+	m.mu.Unlock()
+
+	if !scripted {
+		return fn(ctx, req)
+	}
+
+	return resp.verified, resp.err
+}
+
+// BatchVerifyAddresses answers the call from the first matching expectation.
+func (m *MockVerifier) BatchVerifyAddresses(ctx context.Context, req postgrid.BatchVerifyAddressesRequest) (postgrid.BatchVerifyAddressesResponse, error) {
+	m.mu.Lock()
+	e := m.find(MethodBatchVerifyAddresses, func(e *Expectation) bool { return e.matchBatch == nil || e.matchBatch(req) })
+	m.calls = append(m.calls, Call{Method: MethodBatchVerifyAddresses, BatchRequest: req, Expected: e != nil})
+	if e == nil {
+		m.mu.Unlock()
+		return postgrid.BatchVerifyAddressesResponse{}, fmt.Errorf("%w: BatchVerifyAddresses with %d addresses", ErrUnexpectedCall, len(req.Addresses))
+	}
+	resp, scripted := e.next()
+	fn := e.batchFunc
+	m.mu.Unlock()
+
+	if !scripted {
+		return fn(ctx, req)
+	}
+
+	return resp.batch, resp.err
+}
+
+func (m *MockVerifier) find(method string, match func(*Expectation) bool) *Expectation {
+	for _, e := range m.expectations {
+		if e.method == method && (e.times == 0 || e.calls < e.times) && match(e) {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// Calls returns the calls received so far, in order.
+func (m *MockVerifier) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Call(nil), m.calls...)
+}
+
+// AssertExpectations fails the test when an expectation set with Times was not called enough or a call
+// was unexpected.
+func (m *MockVerifier) AssertExpectations(t testing.TB) bool {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ok := true
+	for i, e := range m.expectations {
+		if e.times > 0 && e.calls < e.times {
+			t.Errorf("postgridtest: expectation %d of %s called %d of %d times", i, e.method, e.calls, e.times)
+			ok = false
+		}
+	}
+	for _, call := range m.calls {
+		if !call.Expected {
+			t.Errorf("postgridtest: unexpected %s call", call.Method)
+			ok = false
+		}
+	}
+
+	return ok
+}
@@ -0,0 +1,133 @@
+package postgridtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	postgrid "github.com/bloomcredit/bloomcredit-postgrid-sdk"
+)
+
+func TestMockVerifier_VerifyAddress(t *testing.T) {
+	a := postgrid.Address{Line1: "1 main st"}
+	boom := errors.New("boom")
+
+	m := NewMockVerifier()
+	m.ExpectVerifyAddress(AddressEquals(a)).
+		Return(postgrid.VerifiedAddress{Status: postgrid.VerificationStatusCorrected}, nil).
+		Return(postgrid.VerifiedAddress{}, boom).
+		Times(3)
+	m.ExpectVerifyAddress(nil).Do(func(_ context.Context, req postgrid.VerifyAddressRequest) (postgrid.VerifiedAddress, error) {
+		return postgrid.VerifiedAddress{Line1: req.Address.String}, nil
+	})
+
+	ctx := context.Background()
+	got, err := m.VerifyAddress(ctx, postgrid.VerifyAddressRequest{Address: a})
+	require.NoError(t, err)
+	assert.Equal(t, postgrid.VerificationStatusCorrected, got.Status)
+
+	// The last scripted response repeats until Times is reached.
+	for i := 0; i < 2; i++ {
+		_, err = m.VerifyAddress(ctx, postgrid.VerifyAddressRequest{Address: a})
+		assert.ErrorIs(t, err, boom)
+	}
+
+	// Then the catch-all expectation answers.
+	got, err = m.VerifyAddress(ctx, postgrid.VerifyAddressRequest{Address: a})
+	require.NoError(t, err)
+	assert.Equal(t, postgrid.VerifiedAddress{}, got)
+	got, err = m.VerifyAddress(ctx, postgrid.VerifyAddressRequest{Address: postgrid.Address{String: "x"}})
+	require.NoError(t, err)
+	assert.Equal(t, "x", got.Line1)
+
+	calls := m.Calls()
+	require.Len(t, calls, 5)
+	assert.Equal(t, MethodVerifyAddress, calls[0].Method)
+	assert.Equal(t, a, calls[0].VerifyRequest.Address)
+	assert.True(t, m.AssertExpectations(t))
+}
+
+func TestMockVerifier_BatchVerifyAddresses(t *testing.T) {
+	m := NewMockVerifier()
+	m.ExpectBatchVerifyAddresses(func(req postgrid.BatchVerifyAddressesRequest) bool { return len(req.Addresses) == 2 }).
+		ReturnBatch(postgrid.BatchVerifyAddressesResponse{Results: make([]postgrid.VerifiedAddressResponse, 2)}, nil).
+		Times(1)
+
+	got, err := m.BatchVerifyAddresses(context.Background(), postgrid.BatchVerifyAddressesRequest{Addresses: make([]postgrid.Address, 2)})
+	require.NoError(t, err)
+	assert.Len(t, got.Results, 2)
+
+	_, err = m.BatchVerifyAddresses(context.Background(), postgrid.BatchVerifyAddressesRequest{Addresses: make([]postgrid.Address, 2)})
+	assert.ErrorIs(t, err, ErrUnexpectedCall)
+	_, err = m.VerifyAddress(context.Background(), postgrid.VerifyAddressRequest{})
+	assert.ErrorIs(t, err, ErrUnexpectedCall)
+
+	ft := &fakeTB{TB: t}
+	assert.False(t, m.AssertExpectations(ft))
+	assert.Equal(t, 2, ft.errors)
+}
+
+func TestMockVerifier_AssertExpectations(t *testing.T) {
+	m := NewMockVerifier()
+	m.ExpectVerifyAddress(nil).Times(2)
+	_, err := m.VerifyAddress(context.Background(), postgrid.VerifyAddressRequest{})
+	require.NoError(t, err)
+
+	ft := &fakeTB{TB: t}
+	assert.False(t, m.AssertExpectations(ft))
+	assert.Equal(t, 1, ft.errors)
+}
+
+func TestMockVerifier_Batcher(t *testing.T) {
+	m := NewMockVerifier()
+	m.ExpectBatchVerifyAddresses(nil).DoBatch(func(_ context.Context, req postgrid.BatchVerifyAddressesRequest) (postgrid.BatchVerifyAddressesResponse, error) {
+		var resp postgrid.BatchVerifyAddressesResponse
+		for _, a := range req.Addresses {
+			resp.Results = append(resp.Results, postgrid.VerifiedAddressResponse{VerifiedAddress: postgrid.VerifiedAddress{Line1: a.Line1}, InputID: a.InputID})
+		}
+		return resp, nil
+	})
+
+	b := postgrid.NewBatcher(m)
+	t.Cleanup(func() { b.Close() })
+	got, err := b.VerifyAddress(context.Background(), postgrid.VerifyAddressRequest{Address: postgrid.Address{Line1: "A"}})
+	require.NoError(t, err)
+	assert.Equal(t, "A", got.Line1)
+}
+
+// fakeTB counts the errors reported to it instead of failing the test.
+type fakeTB struct {
+	testing.TB
+	errors int
+}
+
+func (f *fakeTB) Helper() {}
+
+func (f *fakeTB) Errorf(string, ...any) {
+	f.errors++
+}
+
+func TestMockVerifier_ScriptConcurrently(t *testing.T) {
+	m := NewMockVerifier()
+	e := m.ExpectVerifyAddress(nil)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_, _ = m.VerifyAddress(context.Background(), postgrid.VerifyAddressRequest{})
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		e.Return(postgrid.VerifiedAddress{}, nil).Times(1000)
+	}
+	e.Do(func(context.Context, postgrid.VerifyAddressRequest) (postgrid.VerifiedAddress, error) {
+		return postgrid.VerifiedAddress{}, nil
+	})
+	<-done
+
+	assert.Len(t, m.Calls(), 100)
+}
@@ -0,0 +1,387 @@
+package postgridtest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces scrubbed values in cassettes.
+const Redacted = "[REDACTED]"
+
+// DefaultScrubFields are the request and response fields scrubbed from cassettes unless configured
+// otherwise with WithScrubFields: the address lines, firm names and freeform addresses, in both the form
+// encoded and JSON bodies, and the verified details and rooftop location from which the address lines
+// could be rebuilt.
+var DefaultScrubFields = []string{
+	"line1", "line2", "firmName", "address", "addresses",
+	"address[line1]", "address[line2]",
+	"streetNumber", "streetName", "streetType", "suiteID", "suiteKey", "boxID", "zipPlus4",
+	"usMailingsDeliveryPoint", "usMailingsCheckDigit", "location",
+}
+
+// scrubbedHeaders are the headers whose values are never written to cassettes.
+var scrubbedHeaders = []string{"X-Api-Key", "Authorization"}
+
+// ErrUnmatchedRequest is returned by a replaying Recorder for a request missing from its cassette.
+var ErrUnmatchedRequest = errors.New("postgridtest: no recorded interaction matches request")
+
+// Mode selects whether a Recorder records or replays.
+type Mode int
+
+// All possible values for Mode.
+const (
+	// ModeReplay serves responses from the cassette and never touches the network.
+	ModeReplay Mode = iota
+	// ModeRecord sends requests to the network and records them, to be written with Save.
+	ModeRecord
+)
+
+// Cassette holds recorded interactions.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is the scrubbed request of an Interaction. Hash is a keyed hash of the unscrubbed url and
+// body, recorded when the Recorder is configured WithHashKey, so requests differing only in scrubbed values
+// are told apart.
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+	Hash   string      `json:"hash,omitempty"`
+}
+
+// RecordedResponse is the scrubbed response of an Interaction.
+type RecordedResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Recorder is an http.RoundTripper recording postgrid interactions to a cassette file or replaying them,
+// for use with postgrid.WithHTTPClient. Requests are matched on method, path, query and scrubbed body, and
+// on their scrubbed values through the hash of the request when configured WithHashKey, in recorded order,
+// so a cassette can hold identical requests with different responses.
+type Recorder struct {
+	path    string
+	mode    Mode
+	next    http.RoundTripper
+	scrub   scrubFields
+	hashKey []byte
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// RecorderOption configures a Recorder.
+type RecorderOption interface {
+	applyRecorder(*Recorder)
+}
+
+type transportOption struct {
+	next http.RoundTripper
+}
+
+func (o transportOption) applyRecorder(r *Recorder) {
+	r.next = o.next
+}
+
+// WithTransport configures the transport a recording Recorder sends requests with. It defaults to
+// http.DefaultTransport.
+func WithTransport(next http.RoundTripper) RecorderOption {
+	return transportOption{next: next}
+}
+
+type scrubFieldsOption []string
+
+func (o scrubFieldsOption) applyRecorder(r *Recorder) {
+	r.scrub = scrubFields{}
+	for _, field := range o {
+		r.scrub[field] = true
+	}
+}
+
+// WithScrubFields replaces DefaultScrubFields with the given form and JSON field names. Scrubbed fields
+// are replaced with Redacted in both requests and responses. In JSON, the whole value of a scrubbed field
+// is scrubbed, including nested objects and arrays: strings are replaced with Redacted, and numbers and
+// booleans with zero values so that replayed responses still decode. Headers carrying credentials are
+// always scrubbed.
+func WithScrubFields(fields ...string) RecorderOption {
+	return scrubFieldsOption(fields)
+}
+
+type hashKeyOption []byte
+
+func (o hashKeyOption) applyRecorder(r *Recorder) {
+	r.hashKey = o
+}
+
+// WithHashKey configures the secret key of the request hashes recorded in cassettes, which match requests
+// on their scrubbed values too. Without a key no hashes are recorded, as a hash keyed with a known value
+// lets the scrubbed values be guessed. Cassettes recorded with a key replay only with the same key.
+func WithHashKey(key []byte) RecorderOption {
+	return hashKeyOption(key)
+}
+
+// NewRecorder returns a Recorder for the cassette file at path. Replaying requires the file to exist.
+func NewRecorder(path string, mode Mode, opts ...RecorderOption) (*Recorder, error) {
+	r := &Recorder{path: path, mode: mode, next: http.DefaultTransport}
+	scrubFieldsOption(DefaultScrubFields).applyRecorder(r)
+	for _, opt := range opts {
+		opt.applyRecorder(r)
+	}
+
+	if mode == ModeReplay {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("postgridtest: %w", err)
+		}
+		if err := json.Unmarshal(b, &r.cassette); err != nil {
+			return nil, fmt.Errorf("postgridtest: invalid cassette %s: %w", path, err)
+		}
+		r.used = make([]bool, len(r.cassette.Interactions))
+	}
+
+	return r, nil
+}
+
+// Client returns an http.Client using the Recorder as its transport.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// RoundTrip records or replays a single request.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	recorded := RecordedRequest{
+		Method: req.Method,
+		URL:    r.scrub.url(req.URL),
+		Header: r.scrubHeader(req.Header),
+		Body:   r.scrub.body(req.Header.Get("Content-Type"), body),
+		Hash:   r.hash(req.URL, req.Header.Get("Content-Type"), body),
+	}
+
+	if r.mode == ModeReplay {
+		return r.replay(req, recorded)
+	}
+
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	resp, err := r.next.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request: recorded,
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     r.scrubHeader(resp.Header),
+			Body:       r.scrub.body(resp.Header.Get("Content-Type"), respBody),
+		},
+	})
+
+	return resp, nil
+}
+
+func (r *Recorder) replay(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, interaction := range r.cassette.Interactions {
+		if r.used[i] || !matches(interaction.Request, recorded) {
+			continue
+		}
+		r.used[i] = true
+
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+			StatusCode:    interaction.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        interaction.Response.Header.Clone(),
+			Body:          io.NopCloser(strings.NewReader(interaction.Response.Body)),
+			ContentLength: int64(len(interaction.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s %s %s", ErrUnmatchedRequest, recorded.Method, recorded.URL, recorded.Body)
+}
+
+// matches reports whether the recorded request a matches b. Requests of cassettes recorded without hashes
+// are matched on their scrubbed values only.
+func matches(a, b RecordedRequest) bool {
+	return a.Method == b.Method && a.URL == b.URL && a.Body == b.Body && (a.Hash == "" || a.Hash == b.Hash)
+}
+
+// hash returns the keyed hash of the unscrubbed url and body of a request, or nothing without a key.
+func (r *Recorder) hash(u *url.URL, contentType string, body []byte) string {
+	if len(r.hashKey) == 0 {
+		return ""
+	}
+	mac := hmac.New(sha256.New, r.hashKey)
+	_, _ = io.WriteString(mac, scrubFields(nil).url(u)+"\n"+scrubFields(nil).body(contentType, body))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Save writes the recorded interactions to the cassette file. It does nothing when replaying.
+func (r *Recorder) Save() error {
+	if r.mode == ModeReplay {
+		return nil
+	}
+
+	r.mu.Lock()
+	b, err := json.MarshalIndent(r.cassette, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("postgridtest: %w", err)
+	}
+	if err := os.WriteFile(r.path, append(b, '\n'), 0o600); err != nil {
+		return fmt.Errorf("postgridtest: %w", err)
+	}
+
+	return nil
+}
+
+// Unused returns the number of replayable interactions not requested yet, so tests can check that every
+// recorded request was made.
+func (r *Recorder) Unused() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for _, used := range r.used {
+		if !used {
+			n++
+		}
+	}
+
+	return n
+}
+
+// scrubFields is the set of field names scrubbed from cassettes.
+type scrubFields map[string]bool
+
+// url returns the path and query of u, without the host so cassettes replay against any base url.
+func (f scrubFields) url(u *url.URL) string {
+	query := u.Query()
+	for key := range query {
+		if f[key] {
+			query[key] = []string{Redacted}
+		}
+	}
+	if len(query) == 0 {
+		return u.Path
+	}
+
+	return u.Path + "?" + query.Encode()
+}
+
+func (r *Recorder) scrubHeader(h http.Header) http.Header {
+	h = h.Clone()
+	for _, name := range scrubbedHeaders {
+		if h.Get(name) != "" {
+			h.Set(name, Redacted)
+		}
+	}
+
+	return h
+}
+
+// body scrubs form encoded and JSON bodies. The bodies are re-encoded, so equal bodies scrub to equal
+// strings regardless of field order.
+func (f scrubFields) body(contentType string, body []byte) string {
+	switch {
+	case len(body) == 0:
+		return ""
+	case strings.HasPrefix(contentType, "application/x-www-form-urlencoded"):
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return string(body)
+		}
+		for key := range form {
+			if f[key] {
+				form[key] = []string{Redacted}
+			}
+		}
+		return form.Encode()
+	case strings.HasPrefix(contentType, "application/json"):
+		var v any
+		if err := json.Unmarshal(body, &v); err != nil {
+			return string(body)
+		}
+		b, err := json.Marshal(f.json(v, false))
+		if err != nil {
+			return string(body)
+		}
+		return string(b)
+	}
+
+	return string(body)
+}
+
+// json scrubs the scrubbed fields of v, and every value of v when redact is set.
+func (f scrubFields) json(v any, redact bool) any {
+	switch v := v.(type) {
+	case string:
+		if redact {
+			return Redacted
+		}
+	case float64:
+		if redact {
+			return 0
+		}
+	case bool:
+		if redact {
+			return false
+		}
+	case map[string]any:
+		for key, value := range v {
+			v[key] = f.json(value, redact || f[key])
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = f.json(value, redact)
+		}
+	}
+
+	return v
+}
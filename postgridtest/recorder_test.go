@@ -0,0 +1,147 @@
+package postgridtest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+
+	postgrid "github.com/bloomcredit/bloomcredit-postgrid-sdk"
+)
+
+func TestRecorder(t *testing.T) {
+	dataset := GenerateAddresses(1, 5)
+	srv := NewServer(WithAddresses(dataset...))
+	t.Cleanup(srv.Close)
+
+	single := postgrid.VerifyAddressRequest{Address: toAddress(dataset[0])}
+	batch := postgrid.BatchVerifyAddressesRequest{Addresses: []postgrid.Address{
+		{String: "1 secret st"},
+		toAddress(dataset[1]),
+	}}
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	// Record against the fake server.
+	rec, err := NewRecorder(path, ModeRecord, WithTransport(srv.HTTPClient().Transport))
+	require.NoError(t, err)
+	client := postgrid.NewClient(DefaultAPIKey, srv.URL(), postgrid.WithHTTPClient(rec.Client()), postgrid.WithRateLimiter(rate.NewLimiter(rate.Inf, 0)))
+
+	wantSingle, err := client.VerifyAddress(context.Background(), single)
+	require.NoError(t, err)
+	assert.Equal(t, dataset[0].Line1, wantSingle.Line1)
+	wantBatch, err := client.BatchVerifyAddresses(context.Background(), batch)
+	require.NoError(t, err)
+	require.NoError(t, rec.Save())
+
+	cassette, err := os.ReadFile(path)
+	require.NoError(t, err)
+	for _, secret := range []string{DefaultAPIKey, dataset[0].Line1, dataset[1].Line1, "1 secret st", "1 SECRET ST"} {
+		assert.NotContains(t, string(cassette), secret)
+	}
+	for _, v := range dataset[:2] {
+		for _, detail := range []string{
+			`"` + v.Details.StreetName + `"`, `"` + v.ZipPlus4 + `"`, `"` + v.Details.StreetNumber + `"`,
+			fmt.Sprint(v.GeocodeResult.Location.Latitude), fmt.Sprint(v.GeocodeResult.Location.Longitude),
+		} {
+			assert.NotContains(t, string(cassette), detail)
+		}
+	}
+	assert.Contains(t, string(cassette), dataset[0].City)
+	assert.NotContains(t, string(cassette), `"hash"`, "no hashes are recorded without a key")
+
+	// Replay without a server, against any base url.
+	rep, err := NewRecorder(path, ModeReplay)
+	require.NoError(t, err)
+	client = postgrid.NewClient("other-key", "http://postgrid.invalid", postgrid.WithHTTPClient(rep.Client()), postgrid.WithRateLimiter(rate.NewLimiter(rate.Inf, 0)))
+	assert.Equal(t, 2, rep.Unused())
+
+	gotBatch, err := client.BatchVerifyAddresses(context.Background(), batch)
+	require.NoError(t, err)
+	gotSingle, err := client.VerifyAddress(context.Background(), single)
+	require.NoError(t, err)
+	assert.Equal(t, 0, rep.Unused())
+
+	assert.Equal(t, Redacted, gotSingle.Line1)
+	assert.Equal(t, Redacted, gotSingle.ZipPlus4)
+	assert.Equal(t, Redacted, gotSingle.Details.StreetName)
+	assert.Equal(t, wantSingle.Details.County, gotSingle.Details.County)
+	require.Len(t, gotBatch.Results, 2)
+	gotGeocode := gotBatch.Results[1].VerifiedAddress.GeocodeResult
+	assert.Equal(t, postgrid.GeocodeLocation{}, gotGeocode.Location)
+	assert.Equal(t, wantBatch.Results[1].VerifiedAddress.GeocodeResult.AccuracyType, gotGeocode.AccuracyType)
+
+	// Every interaction is replayed once.
+	_, err = client.VerifyAddress(context.Background(), single)
+	assert.ErrorIs(t, err, ErrUnmatchedRequest)
+}
+
+func TestScrubFields_JSON(t *testing.T) {
+	scrub := scrubFields{"location": true, "line1": true}
+	got := scrub.body("application/json", []byte(`{"line1":"1 main st","city":"Brooklyn","geocodeResult":{"location":{"lat":40.7,"lng":-73.9,"tags":["a"]},"accuracy":1}}`))
+	assert.JSONEq(t, `{"line1":"[REDACTED]","city":"Brooklyn","geocodeResult":{"location":{"lat":0,"lng":0,"tags":["[REDACTED]"]},"accuracy":1}}`, got)
+}
+
+func TestRecorder_Unmatched(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"interactions":[]}`), 0o600))
+
+	rep, err := NewRecorder(path, ModeReplay)
+	require.NoError(t, err)
+	client := postgrid.NewClient("", "http://postgrid.invalid", postgrid.WithHTTPClient(rep.Client()))
+
+	_, err = client.VerifyAddress(context.Background(), postgrid.VerifyAddressRequest{Address: postgrid.Address{String: "a"}})
+	assert.ErrorIs(t, err, ErrUnmatchedRequest)
+	assert.ErrorContains(t, err, "POST /addver/verifications?geocode=true&includeDetails=true")
+
+	_, err = NewRecorder(filepath.Join(t.TempDir(), "missing.json"), ModeReplay)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestRecorder_MatchesScrubbedValues(t *testing.T) {
+	dataset := GenerateAddresses(1, 2)
+	srv := NewServer(WithAddresses(dataset...))
+	t.Cleanup(srv.Close)
+
+	recorded := postgrid.VerifyAddressRequest{Address: toAddress(dataset[0])}
+	other := recorded
+	other.Address.Line1 = dataset[1].Line1
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	rec, err := NewRecorder(path, ModeRecord, WithTransport(srv.HTTPClient().Transport), WithHashKey([]byte("secret")))
+	require.NoError(t, err)
+	client := postgrid.NewClient(DefaultAPIKey, srv.URL(), postgrid.WithHTTPClient(rec.Client()), postgrid.WithRateLimiter(rate.NewLimiter(rate.Inf, 0)))
+	_, err = client.VerifyAddress(context.Background(), recorded)
+	require.NoError(t, err)
+	require.NoError(t, rec.Save())
+
+	tests := []struct {
+		name    string
+		key     []byte
+		req     postgrid.VerifyAddressRequest
+		wantErr error
+	}{
+		{name: "same address", key: []byte("secret"), req: recorded},
+		{name: "different address", key: []byte("secret"), req: other, wantErr: ErrUnmatchedRequest},
+		{name: "different key", key: []byte("other"), req: recorded, wantErr: ErrUnmatchedRequest},
+		{name: "no key", req: recorded, wantErr: ErrUnmatchedRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep, err := NewRecorder(path, ModeReplay, WithHashKey(tt.key))
+			require.NoError(t, err)
+			client := postgrid.NewClient("", "http://postgrid.invalid", postgrid.WithHTTPClient(rep.Client()), postgrid.WithRateLimiter(rate.NewLimiter(rate.Inf, 0)))
+
+			_, err = client.VerifyAddress(context.Background(), tt.req)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,441 @@
+// Package postgridtest provides a fake postgrid server for testing code built on the postgrid client.
+//
+// The server answers the verification, batch verification and autocomplete endpoints from an in-memory
+// dataset of verified addresses, validates the x-api-key header and can inject failures on demand.
+package postgridtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+
+	postgrid "github.com/bloomcredit/bloomcredit-postgrid-sdk"
+	"github.com/bloomcredit/bloomcredit-postgrid-sdk/addressparser"
+)
+
+// DefaultAPIKey is the api key accepted by a Server unless configured otherwise with WithAPIKey.
+const DefaultAPIKey = "test-api-key"
+
+// MatchThreshold is the addressparser.Similarity score above which a request address matches an address
+// of the dataset.
+const MatchThreshold = 0.9
+
+// Fault is a failure injected into a single response.
+type Fault int
+
+// All possible values for Fault.
+const (
+	// FaultNone answers the request normally.
+	FaultNone Fault = iota
+	// FaultTimeout responds with postgrid's 524 timeout status.
+	FaultTimeout
+	// FaultTooManyRequests responds with a 429 status and a Retry-After header of one second.
+	FaultTooManyRequests
+	// FaultMalformedEnvelope responds with a 200 status and a body that is not valid JSON.
+	FaultMalformedEnvelope
+	// FaultErrorEnvelope responds with a 200 status and an envelope with status error.
+	FaultErrorEnvelope
+	// FaultBadGateway responds with a 502 status and an html body, as a proxy in front of postgrid would.
+	FaultBadGateway
+	// FaultConnectionReset closes the connection without responding.
+	FaultConnectionReset
+	// FaultTruncatedBody answers the request but cuts the response body in half.
+	FaultTruncatedBody
+)
+
+var faultNames = map[Fault]string{
+	FaultNone:              "none",
+	FaultTimeout:           "timeout",
+	FaultTooManyRequests:   "too_many_requests",
+	FaultMalformedEnvelope: "malformed_envelope",
+	FaultErrorEnvelope:     "error_envelope",
+	FaultBadGateway:        "bad_gateway",
+	FaultConnectionReset:   "connection_reset",
+	FaultTruncatedBody:     "truncated_body",
+}
+
+func (f Fault) String() string {
+	if name, ok := faultNames[f]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Fault(%d)", int(f))
+}
+
+// Request is a request received by a Server.
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	APIKey string
+	// Addresses holds the addresses of a verification request.
+	Addresses []postgrid.Address
+}
+
+// Completion is a single result of the autocomplete endpoint.
+type Completion struct {
+	Preview CompletionPreview `json:"preview"`
+}
+
+// CompletionPreview is the address preview of a Completion.
+type CompletionPreview struct {
+	Address string `json:"address"`
+	City    string `json:"city"`
+	Prov    string `json:"prov"`
+	PC      string `json:"pc"`
+}
+
+// Server is a stateful fake postgrid server. It is safe for concurrent use.
+type Server struct {
+	srv    *httptest.Server
+	apiKey string
+
+	mu        sync.Mutex
+	addresses []postgrid.VerifiedAddress
+	faults    []Fault
+	requests  []Request
+}
+
+// Option configures a Server.
+type Option interface {
+	apply(*Server)
+}
+
+type apiKeyOption string
+
+func (o apiKeyOption) apply(s *Server) {
+	s.apiKey = string(o)
+}
+
+// WithAPIKey configures the api key the server accepts. It defaults to DefaultAPIKey.
+func WithAPIKey(key string) Option {
+	return apiKeyOption(key)
+}
+
+type addressesOption []postgrid.VerifiedAddress
+
+func (o addressesOption) apply(s *Server) {
+	s.addresses = append(s.addresses, o...)
+}
+
+// WithAddresses seeds the dataset of the server, e.g. with GenerateAddresses.
+func WithAddresses(addresses ...postgrid.VerifiedAddress) Option {
+	return addressesOption(addresses)
+}
+
+// NewServer starts a Server. Close must be called to shut it down.
+func NewServer(opts ...Option) *Server {
+	s := &Server{apiKey: DefaultAPIKey}
+	for _, opt := range opts {
+		opt.apply(s)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/addver/verifications", s.handleVerify)
+	mux.HandleFunc("/addver/verifications/batch", s.handleBatchVerify)
+	mux.HandleFunc("/addver/completions", s.handleCompletions)
+	s.srv = httptest.NewServer(s.middleware(mux))
+
+	return s
+}
+
+// URL returns the base url of the server, to be passed to postgrid.NewClient.
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// HTTPClient returns an http.Client configured for the server.
+func (s *Server) HTTPClient() *http.Client {
+	return s.srv.Client()
+}
+
+// Client returns a postgrid client for the server with its api key and an unlimited rate limiter. The
+// options are applied after these defaults.
+func (s *Server) Client(opts ...postgrid.Option) *postgrid.Client {
+	opts = append([]postgrid.Option{
+		postgrid.WithHTTPClient(s.HTTPClient()),
+		postgrid.WithRateLimiter(rate.NewLimiter(rate.Inf, 0)),
+	}, opts...)
+
+	return postgrid.NewClient(s.apiKey, s.URL(), opts...)
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// AddAddresses adds addresses to the dataset.
+func (s *Server) AddAddresses(addresses ...postgrid.VerifiedAddress) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.addresses = append(s.addresses, addresses...)
+}
+
+// InjectFaults queues faults to be returned, one per request, in order, before requests are answered
+// normally again.
+func (s *Server) InjectFaults(faults ...Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = append(s.faults, faults...)
+}
+
+// Requests returns the requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Request(nil), s.requests...)
+}
+
+// middleware validates the api key and injects queued faults.
+func (s *Server) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != s.apiKey {
+			s.record(r, nil)
+			writeError(w, http.StatusUnauthorized, "Invalid API key.")
+			return
+		}
+
+		s.mu.Lock()
+		var fault Fault
+		if len(s.faults) > 0 {
+			fault, s.faults = s.faults[0], s.faults[1:]
+		}
+		s.mu.Unlock()
+
+		if fault == FaultNone {
+			next.ServeHTTP(w, r)
+			return
+		}
+		switch fault {
+		case FaultConnectionReset:
+			s.record(r, nil)
+			if hj, ok := w.(http.Hijacker); ok {
+				if conn, _, err := hj.Hijack(); err == nil {
+					conn.Close()
+					return
+				}
+			}
+			panic(http.ErrAbortHandler)
+		case FaultTruncatedBody:
+			rec := httptest.NewRecorder()
+			next.ServeHTTP(rec, r)
+			body := rec.Body.Bytes()
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(rec.Code)
+			_, _ = w.Write(body[:len(body)/2])
+		default:
+			s.record(r, nil)
+			writeFault(w, fault)
+		}
+	})
+}
+
+// writeFault writes the response of a fault that does not depend on the request.
+func writeFault(w http.ResponseWriter, fault Fault) {
+	switch fault {
+	case FaultTimeout:
+		w.WriteHeader(524)
+	case FaultTooManyRequests:
+		w.Header().Set("Retry-After", "1")
+		writeError(w, http.StatusTooManyRequests, "Too many requests.")
+	case FaultMalformedEnvelope:
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":`))
+	case FaultErrorEnvelope:
+		writeError(w, http.StatusOK, "Injected error.")
+	case FaultBadGateway:
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte("<html><body><h1>502 Bad Gateway</h1></body></html>"))
+	}
+}
+
+func (s *Server) handleVerify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	a := postgrid.Address{
+		String:          r.PostForm.Get("address"),
+		Line1:           r.PostForm.Get("address[line1]"),
+		Line2:           r.PostForm.Get("address[line2]"),
+		City:            r.PostForm.Get("address[city]"),
+		ProvinceOrState: r.PostForm.Get("address[provinceOrState]"),
+		PostalOrZip:     r.PostForm.Get("address[postalOrZip]"),
+		Country:         r.PostForm.Get("address[country]"),
+	}
+	s.record(r, []postgrid.Address{a})
+
+	writeData(w, s.verify(a, r.URL.Query()))
+}
+
+func (s *Server) handleBatchVerify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+		return
+	}
+
+	var req postgrid.BatchVerifyAddressesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON body.")
+		return
+	}
+	s.record(r, req.Addresses)
+	if len(req.Addresses) > postgrid.MaxBatchSize {
+		writeError(w, http.StatusBadRequest, "Too many addresses.")
+		return
+	}
+
+	resp := postgrid.BatchVerifyAddressesResponse{Results: make([]postgrid.VerifiedAddressResponse, len(req.Addresses))}
+	for i, a := range req.Addresses {
+		resp.Results[i] = postgrid.VerifiedAddressResponse{VerifiedAddress: s.verify(a, r.URL.Query()), InputID: a.InputID}
+	}
+	writeData(w, resp)
+}
+
+func (s *Server) handleCompletions(w http.ResponseWriter, r *http.Request) {
+	s.record(r, nil)
+	partial := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("partialStreet")))
+	if partial == "" {
+		writeError(w, http.StatusBadRequest, "partialStreet is required.")
+		return
+	}
+	country := strings.ToUpper(r.URL.Query().Get("countryFilter"))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	completions := []Completion{}
+	for _, v := range s.addresses {
+		if !strings.HasPrefix(strings.ToUpper(v.Line1), partial) || country != "" && !strings.EqualFold(v.Country, country) {
+			continue
+		}
+		completions = append(completions, Completion{Preview: CompletionPreview{
+			Address: v.Line1,
+			City:    v.City,
+			Prov:    v.ProvinceOrState,
+			PC:      v.PostalOrZip,
+		}})
+		if len(completions) == 10 {
+			break
+		}
+	}
+	writeData(w, completions)
+}
+
+// verify returns the dataset address most similar to a, with status verified when a matches it exactly
+// and corrected otherwise, or a failed result when nothing matches.
+func (s *Server) verify(a postgrid.Address, query url.Values) postgrid.VerifiedAddress {
+	s.mu.Lock()
+	best, score := -1, MatchThreshold
+	for i, v := range s.addresses {
+		if sim := addressparser.Similarity(a, toAddress(v)); sim >= score {
+			best, score = i, sim
+			if sim == 1 {
+				break
+			}
+		}
+	}
+	var v postgrid.VerifiedAddress
+	if best >= 0 {
+		v = s.addresses[best]
+	}
+	s.mu.Unlock()
+
+	if best < 0 {
+		return postgrid.VerifiedAddress{
+			Line1:           strings.ToUpper(a.Line1),
+			Line2:           strings.ToUpper(a.Line2),
+			City:            strings.ToUpper(a.City),
+			ProvinceOrState: strings.ToUpper(a.ProvinceOrState),
+			PostalOrZip:     strings.ToUpper(a.PostalOrZip),
+			Country:         strings.ToLower(a.Country),
+			Errors:          map[string][]string{"line1": {"Could not find a match for this address."}},
+			Status:          postgrid.VerificationStatusFailed,
+		}
+	}
+
+	v.Status = postgrid.VerificationStatusCorrected
+	if exactMatch(a, v) {
+		v.Status = postgrid.VerificationStatusVerified
+	}
+	v.Errors = map[string][]string{}
+	if query.Get("includeDetails") != "true" {
+		v.Details = postgrid.VerifiedAddressDetails{}
+	}
+	if query.Get("geocode") != "true" {
+		v.GeocodeResult = postgrid.GeocodeResult{}
+	}
+
+	return v
+}
+
+func (s *Server) record(r *http.Request, addresses []postgrid.Address) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, Request{
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Query:     r.URL.Query(),
+		APIKey:    r.Header.Get("x-api-key"),
+		Addresses: addresses,
+	})
+}
+
+func toAddress(v postgrid.VerifiedAddress) postgrid.Address {
+	return postgrid.Address{
+		Line1:           v.Line1,
+		Line2:           v.Line2,
+		City:            v.City,
+		ProvinceOrState: v.ProvinceOrState,
+		PostalOrZip:     v.PostalOrZip,
+		Country:         v.Country,
+	}
+}
+
+func exactMatch(a postgrid.Address, v postgrid.VerifiedAddress) bool {
+	if a.String != "" {
+		return false
+	}
+	b := toAddress(v)
+
+	return strings.EqualFold(a.Line1, b.Line1) && strings.EqualFold(a.Line2, b.Line2) &&
+		strings.EqualFold(a.City, b.City) && strings.EqualFold(a.ProvinceOrState, b.ProvinceOrState) &&
+		strings.EqualFold(a.PostalOrZip, b.PostalOrZip)
+}
+
+func writeData(w http.ResponseWriter, data any) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, postgrid.Response{Status: postgrid.ResponseStatusSuccess, Data: raw})
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, postgrid.Response{Status: postgrid.ResponseStatusError, Message: message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
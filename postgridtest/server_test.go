@@ -0,0 +1,150 @@
+package postgridtest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	postgrid "github.com/bloomcredit/bloomcredit-postgrid-sdk"
+)
+
+func TestGenerateAddresses(t *testing.T) {
+	assert.Equal(t, GenerateAddresses(7, 20), GenerateAddresses(7, 20))
+	assert.NotEqual(t, GenerateAddresses(7, 20), GenerateAddresses(8, 20))
+}
+
+func TestServer_VerifyAddress(t *testing.T) {
+	dataset := GenerateAddresses(1, 50)
+	v := dataset[3]
+
+	tests := []struct {
+		name       string
+		address    postgrid.Address
+		wantStatus string
+		wantLine1  string
+	}{
+		{
+			name:       "exact match",
+			address:    postgrid.Address{Line1: strings.ToLower(v.Line1), City: v.City, ProvinceOrState: v.ProvinceOrState, PostalOrZip: v.PostalOrZip, Line2: v.Line2},
+			wantStatus: postgrid.VerificationStatusVerified,
+			wantLine1:  v.Line1,
+		},
+		{
+			name:       "freeform match is corrected",
+			address:    postgrid.Address{String: v.Line1 + " " + v.Line2 + ", " + v.City + ", " + v.ProvinceOrState + " " + v.PostalOrZip},
+			wantStatus: postgrid.VerificationStatusCorrected,
+			wantLine1:  v.Line1,
+		},
+		{
+			name:       "no match",
+			address:    postgrid.Address{Line1: "1 nowhere rd", City: "Nowhere", ProvinceOrState: "ZZ"},
+			wantStatus: postgrid.VerificationStatusFailed,
+			wantLine1:  "1 NOWHERE RD",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewServer(WithAddresses(dataset...))
+			t.Cleanup(srv.Close)
+
+			got, err := srv.Client().VerifyAddress(context.Background(), postgrid.VerifyAddressRequest{Address: tt.address})
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, got.Status)
+			assert.Equal(t, tt.wantLine1, got.Line1)
+
+			reqs := srv.Requests()
+			require.Len(t, reqs, 1)
+			assert.Equal(t, "/addver/verifications", reqs[0].Path)
+			assert.Equal(t, []postgrid.Address{tt.address}, reqs[0].Addresses)
+		})
+	}
+}
+
+func TestServer_BatchVerifyAddresses(t *testing.T) {
+	dataset := GenerateAddresses(1, 10)
+	srv := NewServer()
+	t.Cleanup(srv.Close)
+	srv.AddAddresses(dataset...)
+
+	var req postgrid.BatchVerifyAddressesRequest
+	for _, v := range dataset {
+		req.Addresses = append(req.Addresses, postgrid.Address{Line1: v.Line1, Line2: v.Line2, City: v.City, ProvinceOrState: v.ProvinceOrState, PostalOrZip: v.PostalOrZip})
+	}
+	req.Addresses = append(req.Addresses, postgrid.Address{String: "nowhere"})
+
+	got, err := srv.Client().BatchVerifyAddresses(context.Background(), req)
+	require.NoError(t, err)
+	require.Len(t, got.Results, len(req.Addresses))
+	for i, v := range dataset {
+		assert.Equal(t, v.Fingerprint(), got.Results[i].VerifiedAddress.Fingerprint())
+		assert.Equal(t, v.GeocodeResult, got.Results[i].VerifiedAddress.GeocodeResult)
+	}
+	assert.Equal(t, postgrid.VerificationStatusFailed, got.Results[len(dataset)].VerifiedAddress.Status)
+}
+
+func TestServer_APIKey(t *testing.T) {
+	srv := NewServer(WithAPIKey("secret"))
+	t.Cleanup(srv.Close)
+
+	_, err := postgrid.NewClient("wrong", srv.URL(), postgrid.WithHTTPClient(srv.HTTPClient())).
+		VerifyAddress(context.Background(), postgrid.VerifyAddressRequest{Address: postgrid.Address{String: "a"}})
+	assert.ErrorContains(t, err, "Invalid API key")
+
+	_, err = srv.Client().VerifyAddress(context.Background(), postgrid.VerifyAddressRequest{Address: postgrid.Address{String: "a"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", srv.Requests()[1].APIKey)
+}
+
+func TestServer_InjectFaults(t *testing.T) {
+	srv := NewServer()
+	t.Cleanup(srv.Close)
+	faults := map[Fault]string{
+		FaultTimeout:           "postgrid timeout status 524",
+		FaultTooManyRequests:   "Too many requests",
+		FaultMalformedEnvelope: "error decoding response envelope",
+		FaultErrorEnvelope:     "Injected error",
+		FaultBadGateway:        "response status code 502",
+		FaultConnectionReset:   "EOF",
+		FaultTruncatedBody:     "error decoding response envelope",
+	}
+
+	client := srv.Client()
+	for fault, want := range faults {
+		srv.InjectFaults(fault)
+		_, err := client.VerifyAddress(context.Background(), postgrid.VerifyAddressRequest{Address: postgrid.Address{String: fault.String()}})
+		assert.ErrorContains(t, err, want, fault.String())
+	}
+
+	_, err := client.VerifyAddress(context.Background(), postgrid.VerifyAddressRequest{Address: postgrid.Address{String: "a"}})
+	assert.NoError(t, err)
+	assert.Len(t, srv.Requests(), len(faults)+1)
+}
+
+func TestServer_Completions(t *testing.T) {
+	dataset := GenerateAddresses(1, 50)
+	srv := NewServer(WithAddresses(dataset...))
+	t.Cleanup(srv.Close)
+
+	prefix := dataset[0].Line1[:len(dataset[0].Line1)-3]
+	req, err := http.NewRequest(http.MethodGet, srv.URL()+"/addver/completions?partialStreet="+strings.ReplaceAll(prefix, " ", "+"), nil)
+	require.NoError(t, err)
+	req.Header.Set("x-api-key", DefaultAPIKey)
+
+	resp, err := srv.HTTPClient().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var envelope postgrid.Response
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&envelope))
+	var completions []Completion
+	require.NoError(t, json.Unmarshal(envelope.Data, &completions))
+	require.NotEmpty(t, completions)
+	for _, c := range completions {
+		assert.True(t, strings.HasPrefix(c.Preview.Address, prefix))
+	}
+}
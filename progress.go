@@ -0,0 +1,277 @@
+package postgrid
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ProgressEventType identifies the kind of a ProgressEvent.
+type ProgressEventType string
+
+// All possible values for ProgressEventType.
+const (
+	// ProgressChunkStarted is emitted before a chunk of addresses is verified.
+	ProgressChunkStarted ProgressEventType = "chunk_started"
+	// ProgressChunkFinished is emitted after a chunk of addresses is verified, or failed with Err.
+	ProgressChunkFinished ProgressEventType = "chunk_finished"
+	// ProgressRetryScheduled is emitted when a failed request is about to be retried after Wait.
+	ProgressRetryScheduled ProgressEventType = "retry_scheduled"
+	// ProgressRateLimitWait is emitted when a request waited for the rate limiters for Wait.
+	ProgressRateLimitWait ProgressEventType = "rate_limit_wait"
+	// ProgressRateLimitReduced is emitted when postgrid throttles an adaptive client, with its new Limit and
+	// the Wait requested before the next request.
+	ProgressRateLimitReduced ProgressEventType = "rate_limit_reduced"
+)
+
+// ProgressEvent reports the progress of the client. Chunk events are emitted by BatchVerifyAddresses,
+// StreamVerifyAddresses and BatchJob; retry and rate limit events by every request.
+type ProgressEvent struct {
+	Type ProgressEventType
+	Time time.Time
+
+	// Chunk is the index of the chunk within its bulk operation, and Size its number of addresses.
+	Chunk int
+	Size  int
+	// Completed is the number of addresses of the bulk operation verified so far. Total is its number of
+	// addresses, or 0 when unknown as with StreamVerifyAddresses.
+	Completed int
+	Total     int
+	// StatusCounts counts the results of a finished chunk by VerifiedAddress.Status.
+	StatusCounts map[string]int
+
+	// Endpoint is the endpoint of the request being retried or rate limited, EndpointVerify or
+	// EndpointBatchVerify.
+	Endpoint string
+	// Attempt is the number of the attempt a retry is scheduled for, starting at 2.
+	Attempt int
+	// Wait is the time until a retry or the time spent waiting for the rate limiter.
+	Wait time.Duration
+	// Limit is the rate of the rate limiter in requests per second.
+	Limit rate.Limit
+
+	// Err is the error of a failed chunk or the error causing a retry.
+	Err error
+}
+
+// ProgressHook receives ProgressEvents. Hooks are called synchronously and may be called concurrently, so
+// they must be fast and safe for concurrent use.
+type ProgressHook func(ProgressEvent)
+
+// emit sends the event to the progress hooks.
+func (c *Client) emit(ev ProgressEvent) {
+	if len(c.progressHooks) == 0 {
+		return
+	}
+
+	ev.Time = time.Now()
+	for _, hook := range c.progressHooks {
+		hook(ev)
+	}
+}
+
+// bulkProgress tracks the chunks of a bulk operation.
+type bulkProgress struct {
+	mu        sync.Mutex
+	chunk     int
+	completed int
+	total     int
+}
+
+type bulkProgressKey struct{}
+
+// WithBulkProgress returns a copy of ctx under which the BatchVerifyAddresses calls of the client report
+// their chunk events as the chunks of a single bulk operation of total addresses, or 0 when unknown: chunks
+// are numbered and completed addresses counted across the calls. Callers verifying a large input in several
+// calls, such as csvverify, use it so that progress spans the whole input.
+func WithBulkProgress(ctx context.Context, total int) context.Context {
+	return context.WithValue(ctx, bulkProgressKey{}, &bulkProgress{total: total})
+}
+
+// bulkProgressFromContext returns the bulk operation set on ctx by WithBulkProgress, or a new bulk operation
+// of total addresses.
+func bulkProgressFromContext(ctx context.Context, total int) *bulkProgress {
+	if p, ok := ctx.Value(bulkProgressKey{}).(*bulkProgress); ok {
+		return p
+	}
+
+	return &bulkProgress{total: total}
+}
+
+// start claims the next chunk, returning its index and the number of addresses completed so far.
+func (p *bulkProgress) start() (int, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	chunk := p.chunk
+	p.chunk++
+
+	return chunk, p.completed
+}
+
+// finish adds the addresses of a verified chunk, returning the number of addresses completed so far.
+func (p *bulkProgress) finish(size int) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.completed += size
+
+	return p.completed
+}
+
+// verifyChunk verifies the next chunk of a bulk operation, emitting its chunk events.
+func (c *Client) verifyChunk(ctx context.Context, p *bulkProgress, req BatchVerifyAddressesRequest) (BatchVerifyAddressesResponse, error) {
+	size := len(req.Addresses)
+	chunk, completed := p.start()
+	c.emit(ProgressEvent{Type: ProgressChunkStarted, Chunk: chunk, Size: size, Completed: completed, Total: p.total})
+
+	resp, err := c.batchVerify(ctx, req)
+
+	ev := ProgressEvent{Type: ProgressChunkFinished, Chunk: chunk, Size: size, Completed: completed, Total: p.total, Err: err}
+	if err == nil {
+		ev.Completed = p.finish(size)
+		ev.StatusCounts = map[string]int{}
+		for _, result := range resp.Results {
+			ev.StatusCounts[result.VerifiedAddress.Status]++
+			c.metrics.ObserveResult(EndpointBatchVerify, result.VerifiedAddress.Status)
+		}
+	}
+	c.emit(ev)
+
+	return resp, err
+}
+
+// TerminalReporter renders ProgressEvents as a single updating status line, printing retries and failed
+// chunks on their own lines with their errors redacted by RedactedError. Register its Handle method
+// WithProgressHook.
+type TerminalReporter struct {
+	w     io.Writer
+	start time.Time
+
+	mu       sync.Mutex
+	counts   map[string]int
+	rateWait time.Duration
+}
+
+// NewTerminalReporter returns a TerminalReporter writing to w, usually os.Stderr.
+func NewTerminalReporter(w io.Writer) *TerminalReporter {
+	return &TerminalReporter{w: w, start: time.Now(), counts: map[string]int{}}
+}
+
+// Handle renders the event.
+func (r *TerminalReporter) Handle(ev ProgressEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch ev.Type {
+	case ProgressRateLimitWait:
+		r.rateWait += ev.Wait
+	case ProgressRateLimitReduced:
+		fmt.Fprintf(r.w, "\r\033[Krate limited by postgrid, reduced to %.2f requests/s\n", float64(ev.Limit))
+	case ProgressRetryScheduled:
+		fmt.Fprintf(r.w, "\r\033[Kretrying %s in %s (attempt %d): %s\n", ev.Endpoint, ev.Wait, ev.Attempt, RedactedError(ev.Err))
+	case ProgressChunkFinished:
+		if ev.Err != nil {
+			fmt.Fprintf(r.w, "\r\033[Kchunk %d failed: %s\n", ev.Chunk, RedactedError(ev.Err))
+			return
+		}
+		for status, n := range ev.StatusCounts {
+			r.counts[status] += n
+		}
+		r.render(ev)
+		if ev.Total > 0 && ev.Completed >= ev.Total {
+			fmt.Fprintln(r.w)
+		}
+	}
+}
+
+// render writes the status line: progress, counts by status, elapsed time and, when the total is known,
+// the estimated time remaining.
+func (r *TerminalReporter) render(ev ProgressEvent) {
+	elapsed := time.Since(r.start)
+
+	var b strings.Builder
+	if ev.Total > 0 {
+		fmt.Fprintf(&b, "%d/%d (%.1f%%)", ev.Completed, ev.Total, 100*float64(ev.Completed)/float64(ev.Total))
+	} else {
+		fmt.Fprintf(&b, "%d", ev.Completed)
+	}
+
+	statuses := make([]string, 0, len(r.counts))
+	for status := range r.counts {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		name := status
+		if name == "" {
+			name = "unknown"
+		}
+		fmt.Fprintf(&b, " | %s %d", name, r.counts[status])
+	}
+
+	fmt.Fprintf(&b, " | elapsed %s", elapsed.Round(time.Second))
+	if r.rateWait > 0 {
+		fmt.Fprintf(&b, " | rate limited %s", r.rateWait.Round(time.Second))
+	}
+	if ev.Total > 0 && ev.Completed > 0 && ev.Completed < ev.Total {
+		eta := time.Duration(float64(elapsed) * float64(ev.Total-ev.Completed) / float64(ev.Completed))
+		fmt.Fprintf(&b, " | eta %s", eta.Round(time.Second))
+	}
+
+	fmt.Fprintf(r.w, "\r\033[K%s", b.String())
+}
+
+// NewJSONProgressLog returns a ProgressHook writing every event to w as a line of JSON, with durations in
+// milliseconds, errors as strings redacted by RedactedError and an unlimited rate.Inf limit omitted. Write
+// errors are ignored.
+func NewJSONProgressLog(w io.Writer) ProgressHook {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+
+	return func(ev ProgressEvent) {
+		line := struct {
+			Type         ProgressEventType `json:"type"`
+			Time         time.Time         `json:"time"`
+			Chunk        int               `json:"chunk"`
+			Size         int               `json:"size,omitempty"`
+			Completed    int               `json:"completed,omitempty"`
+			Total        int               `json:"total,omitempty"`
+			StatusCounts map[string]int    `json:"statusCounts,omitempty"`
+			Endpoint     string            `json:"endpoint,omitempty"`
+			Attempt      int               `json:"attempt,omitempty"`
+			WaitMS       float64           `json:"waitMs,omitempty"`
+			Limit        float64           `json:"limit,omitempty"`
+			Error        string            `json:"error,omitempty"`
+		}{
+			Type:         ev.Type,
+			Time:         ev.Time,
+			Chunk:        ev.Chunk,
+			Size:         ev.Size,
+			Completed:    ev.Completed,
+			Total:        ev.Total,
+			StatusCounts: ev.StatusCounts,
+			Endpoint:     ev.Endpoint,
+			Attempt:      ev.Attempt,
+			WaitMS:       float64(ev.Wait) / float64(time.Millisecond),
+		}
+		// JSON has no infinity, so an unlimited rate is omitted.
+		if ev.Limit != rate.Inf {
+			line.Limit = float64(ev.Limit)
+		}
+		if ev.Err != nil {
+			line.Error = RedactedError(ev.Err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		_ = enc.Encode(line)
+	}
+}
@@ -0,0 +1,301 @@
+package postgrid
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+// recordEvents returns a hook recording the events and a function returning them.
+func recordEvents() (ProgressHook, func() []ProgressEvent) {
+	var mu sync.Mutex
+	var events []ProgressEvent
+	return func(ev ProgressEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, ev)
+		}, func() []ProgressEvent {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]ProgressEvent(nil), events...)
+		}
+}
+
+func TestClient_Retry(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		opts         []Option
+		wantErr      bool
+		wantCalls    int32
+		wantAttempts []int
+	}{
+		{
+			name:         "retried until success",
+			statuses:     []int{httpStatusPostgridTimeout, http.StatusServiceUnavailable, http.StatusOK},
+			opts:         []Option{WithRetry(3, time.Millisecond)},
+			wantCalls:    3,
+			wantAttempts: []int{2, 3},
+		},
+		{
+			name:         "attempts exhausted",
+			statuses:     []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusOK},
+			opts:         []Option{WithRetry(2, time.Millisecond)},
+			wantErr:      true,
+			wantCalls:    2,
+			wantAttempts: []int{2},
+		},
+		{
+			name:      "client errors are not retried",
+			statuses:  []int{http.StatusBadRequest, http.StatusOK},
+			opts:      []Option{WithRetry(3, time.Millisecond)},
+			wantErr:   true,
+			wantCalls: 1,
+		},
+		{
+			name:      "disabled by default",
+			statuses:  []int{httpStatusPostgridTimeout, http.StatusOK},
+			wantErr:   true,
+			wantCalls: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			// The base url has a path, like BaseURL.
+			srv := httptest.NewServer(http.StripPrefix("/v1", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tt.statuses[calls.Add(1)-1]
+				if status != http.StatusOK {
+					w.WriteHeader(status)
+					w.Write([]byte(`{"status":"error","message":"failed"}`))
+					return
+				}
+				require.NoError(t, r.ParseForm())
+				writeTestResponse(t, w, VerifiedAddress{Line1: r.PostForm.Get("address[line1]")})
+			})))
+			t.Cleanup(srv.Close)
+
+			hook, events := recordEvents()
+			opts := append([]Option{WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Inf, 0)), WithProgressHook(hook)}, tt.opts...)
+			client := NewClient("", srv.URL+"/v1", opts...)
+
+			got, err := client.VerifyAddress(context.Background(), VerifyAddressRequest{Address: Address{Line1: "A"}})
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				// The body is sent again on retries.
+				assert.Equal(t, "A", got.Line1)
+			}
+			assert.Equal(t, tt.wantCalls, calls.Load())
+
+			var attempts []int
+			for _, ev := range events() {
+				require.Equal(t, ProgressRetryScheduled, ev.Type)
+				assert.Equal(t, EndpointVerify, ev.Endpoint)
+				assert.Error(t, ev.Err)
+				attempts = append(attempts, ev.Attempt)
+			}
+			assert.Equal(t, tt.wantAttempts, attempts)
+		})
+	}
+}
+
+func TestClient_Retry_Cancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(srv.Close)
+
+	client := NewClient("", srv.URL, WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Inf, 0)), WithRetry(5, time.Hour))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := client.VerifyAddress(ctx, VerifyAddressRequest{Address: Address{Line1: "A"}})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestClient_Retry_CancelReadingBody(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"status":`))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	t.Cleanup(srv.Close)
+
+	var events atomic.Int32
+	hook := func(ProgressEvent) { events.Add(1) }
+	client := NewClient("", srv.URL, WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Inf, 0)),
+		WithRetry(5, time.Millisecond), WithProgressHook(hook))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := client.VerifyAddress(ctx, VerifyAddressRequest{Address: Address{Line1: "A"}})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, int32(0), events.Load(), "no retry is scheduled")
+}
+
+func TestRetryWait(t *testing.T) {
+	tests := []struct {
+		backoff time.Duration
+		attempt int
+		want    time.Duration
+	}{
+		{backoff: time.Second, attempt: 1, want: time.Second},
+		{backoff: time.Second, attempt: 3, want: 4 * time.Second},
+		{backoff: time.Second, attempt: 100, want: maxRetryBackoff},
+		{backoff: 2 * time.Hour, attempt: 100, want: 2 * time.Hour},
+		{backoff: 0, attempt: 100, want: 0},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, retryWait(tt.backoff, tt.attempt), "retryWait(%s, %d)", tt.backoff, tt.attempt)
+	}
+}
+
+func TestClient_RateLimitWaitEvent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeTestResponse(t, w, VerifiedAddress{})
+	}))
+	t.Cleanup(srv.Close)
+
+	hook, events := recordEvents()
+	client := NewClient("", srv.URL, WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Every(20*time.Millisecond), 1)), WithProgressHook(hook))
+
+	for _, line := range []string{"A", "B"} {
+		_, err := client.VerifyAddress(context.Background(), VerifyAddressRequest{Address: Address{Line1: line}})
+		require.NoError(t, err)
+	}
+
+	got := events()
+	require.Len(t, got, 1)
+	assert.Equal(t, ProgressRateLimitWait, got[0].Type)
+	assert.Greater(t, got[0].Wait, time.Duration(0))
+
+	// The deadline of the caller passes before the limiter would allow the request.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err := client.VerifyAddress(ctx, VerifyAddressRequest{Address: Address{Line1: "C"}})
+	assert.ErrorIs(t, err, ErrRateLimitDeadline)
+}
+
+func TestClient_ChunkEvents(t *testing.T) {
+	srv, _ := newBatchTestServer(t, false)
+	hook, events := recordEvents()
+	client := NewClient("", srv.URL, WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Inf, 0)), WithProgressHook(hook))
+
+	in := make(chan Address, 3)
+	for _, line := range []string{"A", "B", "C"} {
+		in <- Address{Line1: line}
+	}
+	close(in)
+	for range client.StreamVerifyAddresses(context.Background(), in, WithStreamChunkSize(2)) {
+	}
+
+	var got []ProgressEvent
+	for _, ev := range events() {
+		ev.Time = time.Time{}
+		got = append(got, ev)
+	}
+	assert.Equal(t, []ProgressEvent{
+		{Type: ProgressChunkStarted, Chunk: 0, Size: 2},
+		{Type: ProgressChunkFinished, Chunk: 0, Size: 2, Completed: 2, StatusCounts: map[string]int{VerificationStatusVerified: 2}},
+		{Type: ProgressChunkStarted, Chunk: 1, Size: 1, Completed: 2},
+		{Type: ProgressChunkFinished, Chunk: 1, Size: 1, Completed: 3, StatusCounts: map[string]int{VerificationStatusVerified: 1}},
+	}, got)
+}
+
+func TestClient_WithBulkProgress(t *testing.T) {
+	srv, _ := newBatchTestServer(t, false)
+	hook, events := recordEvents()
+	client := NewClient("", srv.URL, WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Inf, 0)), WithProgressHook(hook))
+
+	// The calls under a bulk progress are the chunks of a single operation.
+	ctx := WithBulkProgress(context.Background(), 3)
+	for _, chunk := range [][]Address{{{Line1: "A"}, {Line1: "B"}}, {{Line1: "C"}}} {
+		_, err := client.BatchVerifyAddresses(ctx, BatchVerifyAddressesRequest{Addresses: chunk})
+		require.NoError(t, err)
+	}
+	// Other calls are operations of their own.
+	_, err := client.BatchVerifyAddresses(context.Background(), BatchVerifyAddressesRequest{Addresses: []Address{{Line1: "D"}}})
+	require.NoError(t, err)
+
+	var got []ProgressEvent
+	for _, ev := range events() {
+		if ev.Type == ProgressChunkFinished {
+			ev.Time = time.Time{}
+			ev.StatusCounts = nil
+			got = append(got, ev)
+		}
+	}
+	assert.Equal(t, []ProgressEvent{
+		{Type: ProgressChunkFinished, Chunk: 0, Size: 2, Completed: 2, Total: 3},
+		{Type: ProgressChunkFinished, Chunk: 1, Size: 1, Completed: 3, Total: 3},
+		{Type: ProgressChunkFinished, Chunk: 0, Size: 1, Completed: 1, Total: 1},
+	}, got)
+}
+
+func TestTerminalReporter(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewTerminalReporter(&buf)
+
+	r.Handle(ProgressEvent{Type: ProgressChunkStarted, Size: 2, Total: 4})
+	r.Handle(ProgressEvent{Type: ProgressRateLimitWait, Wait: 2 * time.Second})
+	r.Handle(ProgressEvent{Type: ProgressChunkFinished, Size: 2, Completed: 2, Total: 4, StatusCounts: map[string]int{"verified": 1, "failed": 1}})
+	r.Handle(ProgressEvent{Type: ProgressRetryScheduled, Endpoint: EndpointBatchVerify, Attempt: 2, Wait: time.Second, Err: errors.New("boom")})
+	r.Handle(ProgressEvent{Type: ProgressChunkFinished, Chunk: 1, Size: 2, Completed: 4, Total: 4, StatusCounts: map[string]int{"verified": 2}})
+
+	lines := strings.Split(buf.String(), "\r\033[K")
+	require.Len(t, lines, 4)
+	assert.Contains(t, lines[1], "2/4 (50.0%) | failed 1 | verified 1 | elapsed 0s | rate limited 2s | eta 0s")
+	assert.Equal(t, "retrying /addver/verifications/batch in 1s (attempt 2): boom\n", lines[2])
+	assert.Contains(t, lines[3], "4/4 (100.0%) | failed 1 | verified 3")
+	assert.True(t, strings.HasSuffix(lines[3], "\n"))
+}
+
+func TestNewJSONProgressLog(t *testing.T) {
+	var buf bytes.Buffer
+	hook := NewJSONProgressLog(&buf)
+
+	hook(ProgressEvent{Type: ProgressRetryScheduled, Endpoint: EndpointVerify, Attempt: 2, Wait: 1500 * time.Microsecond, Err: errors.New("boom")})
+	hook(ProgressEvent{Type: ProgressChunkFinished, Chunk: 1, Size: 2, Completed: 4, Total: 4, StatusCounts: map[string]int{"verified": 2}})
+	hook(ProgressEvent{Type: ProgressRateLimitReduced, Limit: 2.5})
+	hook(ProgressEvent{Type: ProgressRateLimitReduced, Limit: rate.Inf})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 4)
+
+	var got map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &got))
+	assert.Equal(t, "retry_scheduled", got["type"])
+	assert.Equal(t, EndpointVerify, got["endpoint"])
+	assert.Equal(t, 1.5, got["waitMs"])
+	assert.Equal(t, "boom", got["error"])
+
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &got))
+	assert.Equal(t, map[string]any{"verified": float64(2)}, got["statusCounts"])
+
+	got = nil
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &got))
+	assert.Equal(t, 2.5, got["limit"])
+
+	got = nil
+	require.NoError(t, json.Unmarshal([]byte(lines[3]), &got))
+	assert.Equal(t, "rate_limit_reduced", got["type"])
+	assert.NotContains(t, got, "limit")
+}
@@ -0,0 +1,24 @@
+module github.com/bloomcredit/bloomcredit-postgrid-sdk/prompostgrid
+
+go 1.21.4
+
+require (
+	github.com/bloomcredit/bloomcredit-postgrid-sdk v0.0.0-20261018182310-6f19e3941552
+	github.com/prometheus/client_golang v1.19.1
+	github.com/stretchr/testify v1.8.4
+)
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/kr/text v0.2.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
+	golang.org/x/sys v0.17.0 // indirect
+	golang.org/x/time v0.5.0 // indirect
+	google.golang.org/protobuf v1.33.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,191 @@
+// Package prompostgrid reports the metrics of the postgrid client to Prometheus. It is a separate module so
+// that the postgrid module does not depend on the Prometheus client.
+//
+//	metrics := prompostgrid.NewMetrics()
+//	prometheus.MustRegister(metrics)
+//	client := postgrid.NewClient(apiKey, postgrid.BaseURL, postgrid.WithMetrics(metrics))
+//
+// The cache hit ratio is the rate of postgrid_cache_lookups_total{result="hit"} over the rate of
+// postgrid_cache_lookups_total.
+package prompostgrid
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	postgrid "github.com/bloomcredit/bloomcredit-postgrid-sdk"
+)
+
+// DefaultNamespace prefixes the metric names unless configured otherwise with WithNamespace.
+const DefaultNamespace = "postgrid"
+
+// Label values of the cache lookup counter.
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+// StatusError is the status code label of requests that received no response.
+const StatusError = "error"
+
+// Metrics is a postgrid.Metrics and prometheus.Collector. It collects:
+//
+//   - <namespace>_requests_total{endpoint,code}: http request attempts by endpoint and status code
+//   - <namespace>_request_duration_seconds{endpoint}: latency histogram of the http exchanges
+//   - <namespace>_rate_limit_wait_seconds{endpoint}: histogram of the time spent waiting for the rate limiter
+//   - <namespace>_results_total{endpoint,status}: verified addresses by VerifiedAddress.Status
+//   - <namespace>_cache_lookups_total{result}: cache lookups by result, hit or miss
+type Metrics struct {
+	postgrid.NopMetrics
+
+	requests     *prometheus.CounterVec
+	latency      *prometheus.HistogramVec
+	wait         *prometheus.HistogramVec
+	results      *prometheus.CounterVec
+	cacheLookups *prometheus.CounterVec
+}
+
+type options struct {
+	namespace   string
+	buckets     []float64
+	constLabels prometheus.Labels
+}
+
+// Option configures Metrics.
+type Option interface {
+	apply(*options)
+}
+
+type namespaceOption string
+
+func (n namespaceOption) apply(opts *options) {
+	opts.namespace = string(n)
+}
+
+// WithNamespace configures the prefix of the metric names. It defaults to DefaultNamespace.
+func WithNamespace(namespace string) Option {
+	return namespaceOption(namespace)
+}
+
+type bucketsOption []float64
+
+func (b bucketsOption) apply(opts *options) {
+	opts.buckets = b
+}
+
+// WithBuckets configures the buckets, in seconds, of the latency and rate limit wait histograms. They
+// default to prometheus.DefBuckets.
+func WithBuckets(buckets ...float64) Option {
+	return bucketsOption(buckets)
+}
+
+type constLabelsOption prometheus.Labels
+
+func (c constLabelsOption) apply(opts *options) {
+	opts.constLabels = prometheus.Labels(c)
+}
+
+// WithConstLabels configures labels added to every metric, e.g. to tell several clients apart.
+func WithConstLabels(labels prometheus.Labels) Option {
+	return constLabelsOption(labels)
+}
+
+// NewMetrics constructs Metrics. They must be registered with a prometheus.Registerer to be exported.
+func NewMetrics(opts ...Option) *Metrics {
+	options := options{
+		namespace: DefaultNamespace,
+		buckets:   prometheus.DefBuckets,
+	}
+	for _, opt := range opts {
+		opt.apply(&options)
+	}
+
+	return &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   options.namespace,
+			Name:        "requests_total",
+			Help:        "Number of http requests to the postgrid api by endpoint and status code.",
+			ConstLabels: options.constLabels,
+		}, []string{"endpoint", "code"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   options.namespace,
+			Name:        "request_duration_seconds",
+			Help:        "Duration of the http requests to the postgrid api.",
+			Buckets:     options.buckets,
+			ConstLabels: options.constLabels,
+		}, []string{"endpoint"}),
+		wait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   options.namespace,
+			Name:        "rate_limit_wait_seconds",
+			Help:        "Time the http requests to the postgrid api waited for the rate limiter.",
+			Buckets:     options.buckets,
+			ConstLabels: options.constLabels,
+		}, []string{"endpoint"}),
+		results: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   options.namespace,
+			Name:        "results_total",
+			Help:        "Number of verified addresses by endpoint and verification status.",
+			ConstLabels: options.constLabels,
+		}, []string{"endpoint", "status"}),
+		cacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   options.namespace,
+			Name:        "cache_lookups_total",
+			Help:        "Number of verification cache lookups by result.",
+			ConstLabels: options.constLabels,
+		}, []string{"result"}),
+	}
+}
+
+// ObserveRequest counts the request and observes its latency.
+func (m *Metrics) ObserveRequest(endpoint string, statusCode int, latency time.Duration) {
+	code := StatusError
+	if statusCode != 0 {
+		code = strconv.Itoa(statusCode)
+	}
+	m.requests.WithLabelValues(endpoint, code).Inc()
+	m.latency.WithLabelValues(endpoint).Observe(latency.Seconds())
+}
+
+// ObserveRateLimitWait observes the rate limiter wait of a request.
+func (m *Metrics) ObserveRateLimitWait(endpoint string, wait time.Duration) {
+	m.wait.WithLabelValues(endpoint).Observe(wait.Seconds())
+}
+
+// ObserveResult counts a verified address.
+func (m *Metrics) ObserveResult(endpoint string, status string) {
+	m.results.WithLabelValues(endpoint, status).Inc()
+}
+
+// ObserveCacheLookup counts a cache lookup.
+func (m *Metrics) ObserveCacheLookup(hit bool) {
+	result := CacheMiss
+	if hit {
+		result = CacheHit
+	}
+	m.cacheLookups.WithLabelValues(result).Inc()
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range m.collectors() {
+		c.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range m.collectors() {
+		c.Collect(ch)
+	}
+}
+
+func (m *Metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.requests, m.latency, m.wait, m.results, m.cacheLookups}
+}
+
+var (
+	_ postgrid.Metrics     = (*Metrics)(nil)
+	_ prometheus.Collector = (*Metrics)(nil)
+)
@@ -0,0 +1,73 @@
+package prompostgrid
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	postgrid "github.com/bloomcredit/bloomcredit-postgrid-sdk"
+	"github.com/bloomcredit/bloomcredit-postgrid-sdk/postgridtest"
+)
+
+func TestMetrics(t *testing.T) {
+	dataset := postgridtest.GenerateAddresses(1, 10)
+	srv := postgridtest.NewServer(postgridtest.WithAddresses(dataset...))
+	t.Cleanup(srv.Close)
+	srv.InjectFaults(postgridtest.FaultBadGateway)
+
+	metrics := NewMetrics(WithConstLabels(prometheus.Labels{"client": "test"}))
+	registry := prometheus.NewPedanticRegistry()
+	require.NoError(t, registry.Register(metrics))
+
+	client := srv.Client(postgrid.WithRetry(2, time.Millisecond), postgrid.WithCache(postgrid.NewLRUCache(10)), postgrid.WithMetrics(metrics))
+
+	v := dataset[0]
+	address := postgrid.Address{Line1: v.Line1, Line2: v.Line2, City: v.City, ProvinceOrState: v.ProvinceOrState, PostalOrZip: v.PostalOrZip}
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		_, err := client.VerifyAddress(ctx, postgrid.VerifyAddressRequest{Address: address})
+		require.NoError(t, err)
+	}
+	_, err := client.BatchVerifyAddresses(ctx, postgrid.BatchVerifyAddressesRequest{Addresses: []postgrid.Address{
+		{Line1: "1 nowhere rd", City: "Nowhere", ProvinceOrState: "ZZ"},
+	}})
+	require.NoError(t, err)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.requests.WithLabelValues("/addver/verifications", "502")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.requests.WithLabelValues("/addver/verifications", "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.requests.WithLabelValues("/addver/verifications/batch", "200")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.results.WithLabelValues("/addver/verifications", postgrid.VerificationStatusVerified)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.results.WithLabelValues("/addver/verifications/batch", postgrid.VerificationStatusFailed)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.cacheLookups.WithLabelValues(CacheHit)))
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.cacheLookups.WithLabelValues(CacheMiss)))
+
+	// One series per endpoint for each histogram.
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.latency, "postgrid_request_duration_seconds"))
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.wait, "postgrid_rate_limit_wait_seconds"))
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	var names []string
+	for _, family := range families {
+		names = append(names, family.GetName())
+	}
+	assert.Equal(t, []string{
+		"postgrid_cache_lookups_total",
+		"postgrid_rate_limit_wait_seconds",
+		"postgrid_request_duration_seconds",
+		"postgrid_requests_total",
+		"postgrid_results_total",
+	}, names)
+}
+
+func TestMetrics_ObserveRequest_NoResponse(t *testing.T) {
+	metrics := NewMetrics(WithNamespace("test"))
+	metrics.ObserveRequest("/addver/verifications", 0, time.Second)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.requests.WithLabelValues("/addver/verifications", StatusError)))
+}
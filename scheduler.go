@@ -0,0 +1,208 @@
+package postgrid
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Priority orders the requests waiting for a rate limiter: a request is only sent once no request of a
+// higher priority is waiting.
+type Priority int
+
+// All possible values for Priority, from the highest.
+const (
+	// PriorityInteractive is for user-facing flows. It is the default of VerifyAddress.
+	PriorityInteractive Priority = iota
+	// PriorityBackground is for bulk work. It is the default of BatchVerifyAddresses and the bulk operations
+	// built on it.
+	PriorityBackground
+
+	numPriorities = iota
+)
+
+type priorityKey struct{}
+
+// WithPriority returns a copy of ctx under which the requests of the client are scheduled with priority p,
+// overriding the default priority of their endpoint.
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// PriorityFromContext returns the priority set on ctx by WithPriority.
+func PriorityFromContext(ctx context.Context) (Priority, bool) {
+	p, ok := ctx.Value(priorityKey{}).(Priority)
+	if !ok || p < 0 || p >= numPriorities {
+		return 0, false
+	}
+
+	return p, true
+}
+
+// priority returns the priority of a request to the endpoint. A coalesced request has the highest priority
+// of the callers waiting for it.
+func priority(ctx context.Context, endpoint string) Priority {
+	if c, ok := flightFromContext(ctx); ok {
+		return c.priority()
+	}
+	if p, ok := PriorityFromContext(ctx); ok {
+		return p
+	}
+	if endpoint == EndpointBatchVerify {
+		return PriorityBackground
+	}
+
+	return PriorityInteractive
+}
+
+// scheduler hands out the tokens of a rate.Limiter to waiting requests by priority, then in order of
+// arrival.
+type scheduler struct {
+	limiter *rate.Limiter
+
+	mu      sync.Mutex
+	queues  [numPriorities][]*waiter
+	changed chan struct{}
+}
+
+// waiter is a request waiting for a token.
+type waiter struct {
+	priority Priority
+}
+
+func newScheduler(limiter *rate.Limiter) *scheduler {
+	return &scheduler{limiter: limiter, changed: make(chan struct{})}
+}
+
+// token is a token of a limiter granted to a request.
+type token struct {
+	r  *rate.Reservation
+	at time.Time
+}
+
+// release returns the token to its limiter when the request is not sent after all. The tokens the limiter
+// granted since are not handed out again.
+func (t token) release() {
+	if t.r != nil {
+		t.r.CancelAt(t.at)
+	}
+}
+
+// wait blocks until the request is granted a token of the limiter. It reports whether the request had to
+// wait. A coalesced request moves to the queue of the priority of its flight as callers join or leave it.
+func (s *scheduler) wait(ctx context.Context, p Priority) (token, bool, error) {
+	if s.limiter.Limit() != rate.Inf && s.limiter.Burst() < 1 {
+		return token{}, false, fmt.Errorf("rate: Wait(n=1) exceeds limiter's burst %d", s.limiter.Burst())
+	}
+
+	flight, coalesced := flightFromContext(ctx)
+	var reprioritized <-chan struct{}
+	if coalesced {
+		reprioritized = flight.reprioritized()
+	}
+	w := &waiter{priority: p}
+	s.mu.Lock()
+	s.queues[p] = append(s.queues[p], w)
+	for waited := false; ; waited = true {
+		var timer *time.Timer
+		var fired <-chan time.Time
+		if s.head() == w {
+			if t, ok := s.take(); ok {
+				s.remove(w)
+				s.mu.Unlock()
+				return t, waited, nil
+			}
+
+			delay := s.delay()
+			if deadline, ok := requestDeadline(ctx); ok && time.Until(deadline) < delay {
+				s.remove(w)
+				s.mu.Unlock()
+				return token{}, waited, fmt.Errorf("%w: wait of %s for a token", ErrRateLimitDeadline, delay)
+			}
+			timer = time.NewTimer(delay)
+			fired = timer.C
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		select {
+		case <-fired:
+		case <-changed:
+		case <-reprioritized:
+			reprioritized = flight.reprioritized()
+		case <-ctx.Done():
+			stopTimer(timer)
+			s.mu.Lock()
+			s.remove(w)
+			s.mu.Unlock()
+			return token{}, true, ctx.Err()
+		}
+		stopTimer(timer)
+		p := w.priority
+		if coalesced {
+			p = flight.priority()
+		}
+		s.mu.Lock()
+		if p != w.priority {
+			s.remove(w)
+			w.priority = p
+			s.queues[p] = append(s.queues[p], w)
+		}
+	}
+}
+
+// take takes a token of the limiter if one is available.
+func (s *scheduler) take() (token, bool) {
+	now := time.Now()
+	if s.limiter.Limit() != rate.Inf && s.limiter.TokensAt(now) < 1 {
+		return token{}, false
+	}
+
+	// A reservation acting right away can only be canceled as of the time it was made.
+	return token{r: s.limiter.ReserveN(now, 1), at: now}, true
+}
+
+func stopTimer(t *time.Timer) {
+	if t != nil {
+		t.Stop()
+	}
+}
+
+// head returns the waiter next in line for a token.
+func (s *scheduler) head() *waiter {
+	for _, queue := range s.queues {
+		if len(queue) > 0 {
+			return queue[0]
+		}
+	}
+
+	return nil
+}
+
+// remove removes w from its queue and wakes the other waiters, as the head of the line may have changed.
+func (s *scheduler) remove(w *waiter) {
+	queue := s.queues[w.priority]
+	for i := range queue {
+		if queue[i] == w {
+			s.queues[w.priority] = append(queue[:i:i], queue[i+1:]...)
+			break
+		}
+	}
+
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// delay returns the time until the limiter has a token.
+func (s *scheduler) delay() time.Duration {
+	limit := float64(s.limiter.Limit())
+	if limit <= 0 {
+		return math.MaxInt64
+	}
+
+	return time.Duration(math.Ceil((1 - s.limiter.Tokens()) / limit * float64(time.Second)))
+}
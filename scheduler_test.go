@@ -0,0 +1,152 @@
+package postgrid
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestPriority(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  context.Context
+		path string
+		want Priority
+	}{
+		{name: "verify defaults to interactive", ctx: context.Background(), path: EndpointVerify, want: PriorityInteractive},
+		{name: "batch defaults to background", ctx: context.Background(), path: EndpointBatchVerify, want: PriorityBackground},
+		{name: "set on context", ctx: WithPriority(context.Background(), PriorityInteractive), path: EndpointBatchVerify, want: PriorityInteractive},
+		{name: "invalid priority ignored", ctx: WithPriority(context.Background(), Priority(7)), path: EndpointBatchVerify, want: PriorityBackground},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, priority(tt.ctx, tt.path))
+		})
+	}
+}
+
+func TestScheduler_Priority(t *testing.T) {
+	s := newScheduler(rate.NewLimiter(rate.Every(20*time.Millisecond), 1))
+	_, waited, err := s.wait(context.Background(), PriorityBackground)
+	require.NoError(t, err)
+	assert.False(t, waited)
+
+	var mu sync.Mutex
+	var order []Priority
+	var wg sync.WaitGroup
+	start := func(p Priority) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, waited, err := s.wait(context.Background(), p)
+			assert.NoError(t, err)
+			assert.True(t, waited)
+			mu.Lock()
+			order = append(order, p)
+			mu.Unlock()
+		}()
+	}
+
+	// Background requests queue up before an interactive request, which is still granted the next token.
+	start(PriorityBackground)
+	start(PriorityBackground)
+	require.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.queues[PriorityBackground]) == 2
+	}, time.Second, time.Millisecond)
+	start(PriorityInteractive)
+	wg.Wait()
+
+	assert.Equal(t, []Priority{PriorityInteractive, PriorityBackground, PriorityBackground}, order)
+}
+
+func TestScheduler_Cancel(t *testing.T) {
+	s := newScheduler(rate.NewLimiter(rate.Every(20*time.Millisecond), 1))
+	_, _, err := s.wait(context.Background(), PriorityInteractive)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, _, err := s.wait(ctx, PriorityInteractive)
+		done <- err
+	}()
+	require.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.head() != nil
+	}, time.Second, time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	// The cancelled request no longer holds the head of the line.
+	_, _, err = s.wait(context.Background(), PriorityBackground)
+	assert.NoError(t, err)
+	assert.Nil(t, s.head())
+}
+
+func TestScheduler_ZeroBurst(t *testing.T) {
+	_, _, err := newScheduler(rate.NewLimiter(1, 0)).wait(context.Background(), PriorityInteractive)
+	assert.EqualError(t, err, "rate: Wait(n=1) exceeds limiter's burst 0")
+}
+
+func TestClient_WithEndpointRateLimiter(t *testing.T) {
+	// The base url has a path, like BaseURL.
+	srv := httptest.NewServer(http.StripPrefix("/v1", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == EndpointBatchVerify {
+			var req BatchVerifyAddressesRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			writeTestResponse(t, w, BatchVerifyAddressesResponse{Results: make([]VerifiedAddressResponse, len(req.Addresses))})
+			return
+		}
+		writeTestResponse(t, w, VerifiedAddress{})
+	})))
+	t.Cleanup(srv.Close)
+
+	client := NewClient("", srv.URL+"/v1", WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Inf, 0)),
+		WithEndpointRateLimiter(EndpointBatchVerify, rate.NewLimiter(rate.Every(time.Hour), 1)))
+
+	ctx := context.Background()
+	_, err := client.BatchVerifyAddresses(ctx, BatchVerifyAddressesRequest{Addresses: []Address{{Line1: "A"}}})
+	require.NoError(t, err)
+
+	// The batch budget is spent, which does not hold back the verify endpoint.
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = client.BatchVerifyAddresses(timeoutCtx, BatchVerifyAddressesRequest{Addresses: []Address{{Line1: "B"}}})
+	assert.ErrorIs(t, err, ErrRateLimitDeadline)
+
+	_, err = client.VerifyAddress(timeoutCtx, VerifyAddressRequest{Address: Address{Line1: "C"}})
+	assert.NoError(t, err)
+}
+
+func TestClient_WithEndpointRateLimiter_Release(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeTestResponse(t, w, VerifiedAddress{})
+	}))
+	t.Cleanup(srv.Close)
+
+	global := rate.NewLimiter(rate.Every(time.Hour), 1)
+	endpoint := rate.NewLimiter(rate.Every(time.Hour), 1)
+	client := NewClient("", srv.URL, WithHTTPClient(srv.Client()), WithRateLimiter(global),
+		WithEndpointRateLimiter(EndpointVerify, endpoint))
+	require.True(t, global.Allow())
+
+	// The wait for the client rate limit fails after the endpoint granted its token.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := client.VerifyAddress(ctx, VerifyAddressRequest{Address: Address{Line1: "A"}})
+	require.ErrorIs(t, err, ErrRateLimitDeadline)
+
+	// The endpoint token is still there for the next request.
+	assert.InDelta(t, 1, endpoint.Tokens(), 0.01)
+}
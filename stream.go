@@ -0,0 +1,163 @@
+package postgrid
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultStreamChunkSize is the number of addresses sent per batch by StreamVerifyAddresses.
+const DefaultStreamChunkSize = 500
+
+// StreamResult is a single result of StreamVerifyAddresses.
+type StreamResult struct {
+	Input           Address
+	VerifiedAddress VerifiedAddress
+	Err             error
+}
+
+type streamOptions struct {
+	chunkSize     int
+	flushInterval time.Duration
+}
+
+// StreamOption represents optional arguments for StreamVerifyAddresses.
+type StreamOption interface {
+	apply(*streamOptions)
+}
+
+type streamChunkSizeOption struct {
+	size int
+}
+
+func (s streamChunkSizeOption) apply(opts *streamOptions) {
+	opts.chunkSize = s.size
+}
+
+// WithStreamChunkSize configures the number of addresses sent per batch. It is capped at MaxBatchSize.
+func WithStreamChunkSize(size int) StreamOption {
+	return streamChunkSizeOption{size: size}
+}
+
+type streamFlushIntervalOption struct {
+	interval time.Duration
+}
+
+func (s streamFlushIntervalOption) apply(opts *streamOptions) {
+	opts.flushInterval = s.interval
+}
+
+// WithStreamFlushInterval configures how long a chunk waits for more addresses after its first address
+// arrives before it is sent partially filled. It defaults to DefaultBatchWindow, and chunks are only sent
+// once full or once the input is closed when it is not positive.
+func WithStreamFlushInterval(interval time.Duration) StreamOption {
+	return streamFlushIntervalOption{interval: interval}
+}
+
+// StreamVerifyAddresses verifies the addresses received from in, in chunks, through the Batch Verify
+// Addresses endpoint. Results are emitted in input order as each chunk completes, and at most one chunk is
+// held in memory: the next chunk is not read until the results of the previous one have been received. A
+// chunk is sent once full, or partially filled when no more addresses arrive within the flush interval, see
+// WithStreamFlushInterval.
+//
+// A chunk that fails emits a result with Err set for every address in it. The returned channel is closed
+// once in is closed and drained, or once ctx is done. When ctx is done first, the last result emitted has a
+// zero Input and Err set to the context's error, so that a cancellation can be told apart from the end of
+// the input; a result not yet received by then may be discarded to make room for it.
+func (c *Client) StreamVerifyAddresses(ctx context.Context, in <-chan Address, opts ...StreamOption) <-chan StreamResult {
+	options := streamOptions{
+		chunkSize:     DefaultStreamChunkSize,
+		flushInterval: DefaultBatchWindow,
+	}
+
+	for _, opt := range opts {
+		opt.apply(&options)
+	}
+	chunkSize := min(max(options.chunkSize, 1), MaxBatchSize)
+
+	// The buffer holds the error of ctx when the receiver is not ready for it, see streamCanceled.
+	out := make(chan StreamResult, 1)
+	go func() {
+		defer close(out)
+
+		chunk := make([]Address, 0, chunkSize)
+		progress := &bulkProgress{}
+		for {
+			var more bool
+			chunk, more = readChunk(ctx, in, chunk[:0], options.flushInterval)
+			if len(chunk) > 0 && !c.streamChunk(ctx, progress, chunk, out) || ctx.Err() != nil {
+				streamCanceled(ctx, out)
+				return
+			}
+			if !more {
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// streamCanceled emits the error of ctx as the last result of out without blocking, discarding the result
+// waiting in the buffer of out when the receiver has not taken it yet.
+func streamCanceled(ctx context.Context, out chan StreamResult) {
+	for {
+		select {
+		case out <- StreamResult{Err: ctx.Err()}:
+			return
+		case <-out:
+		}
+	}
+}
+
+// readChunk appends addresses from in to chunk until it is full, in is closed, ctx is done or the flush
+// interval has passed since the first address arrived. It reports whether more addresses may follow.
+func readChunk(ctx context.Context, in <-chan Address, chunk []Address, flushInterval time.Duration) ([]Address, bool) {
+	var timer *time.Timer
+	var flush <-chan time.Time
+	defer func() { stopTimer(timer) }()
+
+	for len(chunk) < cap(chunk) {
+		select {
+		case a, ok := <-in:
+			if !ok {
+				return chunk, false
+			}
+			chunk = append(chunk, a)
+			if timer == nil && flushInterval > 0 {
+				timer = time.NewTimer(flushInterval)
+				flush = timer.C
+			}
+		case <-flush:
+			return chunk, true
+		case <-ctx.Done():
+			return chunk, false
+		}
+	}
+
+	return chunk, true
+}
+
+// streamChunk verifies a chunk and emits its results, reporting false when ctx is done before all results
+// are received.
+func (c *Client) streamChunk(ctx context.Context, p *bulkProgress, chunk []Address, out chan<- StreamResult) bool {
+	resp, err := c.verifyChunk(ctx, p, BatchVerifyAddressesRequest{Addresses: chunk})
+	if err == nil && len(resp.Results) != len(chunk) {
+		err = fmt.Errorf("postgrid error: expected %d batch results, received %d", len(chunk), len(resp.Results))
+	}
+
+	for i, a := range chunk {
+		res := StreamResult{Input: a, Err: err}
+		if err == nil {
+			res.VerifiedAddress = resp.Results[i].VerifiedAddress
+		}
+
+		select {
+		case out <- res:
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,175 @@
+package postgrid
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestClient_StreamVerifyAddresses(t *testing.T) {
+	tests := []struct {
+		name      string
+		count     int
+		opts      []StreamOption
+		wantSizes []int
+	}{
+		{
+			name:      "default chunk size",
+			count:     3,
+			wantSizes: []int{3},
+		},
+		{
+			name:      "split into chunks",
+			count:     7,
+			opts:      []StreamOption{WithStreamChunkSize(3)},
+			wantSizes: []int{3, 3, 1},
+		},
+		{
+			name:  "empty input",
+			count: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, sizes := newBatchTestServer(t, false)
+			client := NewClient("", srv.URL, WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Inf, 0)))
+
+			in := make(chan Address)
+			go func() {
+				defer close(in)
+				for i := 0; i < tt.count; i++ {
+					in <- Address{Line1: fmt.Sprint(i)}
+				}
+			}()
+
+			var got []string
+			for res := range client.StreamVerifyAddresses(context.Background(), in, tt.opts...) {
+				require.NoError(t, res.Err)
+				assert.Equal(t, res.Input.Line1, res.VerifiedAddress.Line1)
+				got = append(got, res.VerifiedAddress.Line1)
+			}
+
+			require.Len(t, got, tt.count)
+			for i, line := range got {
+				assert.Equal(t, fmt.Sprint(i), line)
+			}
+			assert.Equal(t, tt.wantSizes, sizes())
+		})
+	}
+}
+
+func TestClient_StreamVerifyAddresses_Flush(t *testing.T) {
+	srv, sizes := newBatchTestServer(t, false)
+	client := NewClient("", srv.URL, WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Inf, 0)))
+
+	// A slow producer sends the next address only once the previous one is verified, which never fills a
+	// chunk.
+	in := make(chan Address)
+	defer close(in)
+	out := client.StreamVerifyAddresses(context.Background(), in, WithStreamFlushInterval(10*time.Millisecond))
+	for _, line := range []string{"A", "B"} {
+		in <- Address{Line1: line}
+		select {
+		case res := <-out:
+			require.NoError(t, res.Err)
+			assert.Equal(t, line, res.VerifiedAddress.Line1)
+		case <-time.After(time.Second):
+			t.Fatal("partial chunk was not sent")
+		}
+	}
+	assert.Equal(t, []int{1, 1}, sizes())
+}
+
+func TestClient_StreamVerifyAddresses_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"status":"error","message":"boom"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	client := NewClient("", srv.URL, WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Inf, 0)))
+	in := make(chan Address, 2)
+	in <- Address{Line1: "A"}
+	in <- Address{Line1: "B"}
+	close(in)
+
+	var inputs []string
+	for res := range client.StreamVerifyAddresses(context.Background(), in) {
+		assert.ErrorContains(t, res.Err, "boom")
+		inputs = append(inputs, res.Input.Line1)
+	}
+	assert.Equal(t, []string{"A", "B"}, inputs)
+}
+
+func TestClient_StreamVerifyAddresses_Cancel(t *testing.T) {
+	srv, sizes := newBatchTestServer(t, false)
+	client := NewClient("", srv.URL, WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Inf, 0)))
+
+	// An endless producer; the consumer stops after the first chunk.
+	in := make(chan Address)
+	stop := make(chan struct{})
+	t.Cleanup(func() { close(stop) })
+	go func() {
+		for {
+			select {
+			case in <- Address{Line1: "A"}:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	out := client.StreamVerifyAddresses(ctx, in, WithStreamChunkSize(2))
+	<-out
+	cancel()
+	var last StreamResult
+	for res := range out {
+		last = res
+	}
+
+	assert.LessOrEqual(t, len(sizes()), 2)
+	assert.ErrorIs(t, last.Err, context.Canceled)
+	assert.Equal(t, Address{}, last.Input)
+}
+
+func TestClient_StreamVerifyAddresses_CancelUnread(t *testing.T) {
+	srv, _ := newBatchTestServer(t, false)
+	client := NewClient("", srv.URL, WithHTTPClient(srv.Client()), WithRateLimiter(rate.NewLimiter(rate.Inf, 0)))
+
+	in := make(chan Address, 4)
+	for i := 0; i < 4; i++ {
+		in <- Address{Line1: "A"}
+	}
+	close(in)
+
+	// The receiver stops reading before canceling: the stream still terminates.
+	ctx, cancel := context.WithCancel(context.Background())
+	out := client.StreamVerifyAddresses(ctx, in, WithStreamChunkSize(4))
+	require.NoError(t, (<-out).Err)
+	// The next result fills the buffer, so the stream is blocked on the receiver when it is canceled.
+	require.Eventually(t, func() bool { return len(out) == cap(out) }, time.Second, time.Millisecond)
+	cancel()
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		var last StreamResult
+		for res := range out {
+			last = res
+		}
+		assert.ErrorIs(t, last.Err, context.Canceled)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("stream did not terminate after cancellation")
+	}
+}
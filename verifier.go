@@ -0,0 +1,13 @@
+package postgrid
+
+import "context"
+
+// AddressVerifier is the address verification api implemented by Client. Depend on it rather than on
+// *Client to substitute a fake in tests, such as postgridtest.MockVerifier. Methods are added as the client
+// supports more lookups, so implementations outside this module should embed an AddressVerifier.
+type AddressVerifier interface {
+	VerifyAddress(ctx context.Context, req VerifyAddressRequest) (VerifiedAddress, error)
+	BatchVerifyAddresses(ctx context.Context, req BatchVerifyAddressesRequest) (BatchVerifyAddressesResponse, error)
+}
+
+var _ AddressVerifier = (*Client)(nil)